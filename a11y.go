@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// unlabeledInputs are input types that need no label.
+var unlabeledInputs = map[string]bool{
+	"hidden": true, "submit": true, "button": true, "image": true, "reset": true,
+}
+
+// a11yAnalyzer detects accessibility problems that can be seen in
+// the markup: images without alt text, form controls without a
+// label, links and buttons without text and duplicate ids.
+type a11yAnalyzer struct {
+	issues   []finding
+	ids      map[string]int
+	labelFor map[string]bool
+	controls []string // ids of controls outside of a label
+	nlabel   int      // open label elements
+	cur      textCapture
+	curKind  string // a or button
+	curName  string // accessible name from attributes
+	curDesc  string
+}
+
+func newA11yAnalyzer() *a11yAnalyzer {
+	return &a11yAnalyzer{
+		ids:      make(map[string]int),
+		labelFor: make(map[string]bool),
+	}
+}
+
+func (a *a11yAnalyzer) add(kind, detail string) {
+	a.issues = append(a.issues, finding{Kind: kind, Detail: detail})
+}
+
+// accessibleName returns the accessible name given by the attributes of t.
+func accessibleName(t *html.Token) string {
+	for _, k := range []string{"aria-label", "aria-labelledby", "title"} {
+		if v, ok := attr(t, k); ok && strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (a *a11yAnalyzer) token(t *html.Token) {
+	if a.cur.active() {
+		if t.Data == "img" && (t.Type == html.StartTagToken || t.Type == html.SelfClosingTagToken) {
+			if alt, _ := attr(t, "alt"); alt != "" {
+				a.curName += alt
+			}
+		}
+		if a.cur.token(t) && a.cur.String() == "" && strings.TrimSpace(a.curName) == "" {
+			kind := "a11y-empty-link"
+			if a.curKind == "button" {
+				kind = "a11y-empty-button"
+			}
+			a.add(kind, a.curDesc)
+		}
+	}
+	if t.Type == html.EndTagToken && t.Data == "label" && a.nlabel > 0 {
+		a.nlabel--
+	}
+	if t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken {
+		return
+	}
+	if id, ok := attr(t, "id"); ok && id != "" {
+		a.ids[id]++
+	}
+	switch t.Data {
+	case "img":
+		if _, ok := attr(t, "alt"); !ok {
+			src, _ := attr(t, "src")
+			a.add("a11y-missing-alt", src)
+		}
+	case "label":
+		if t.Type == html.StartTagToken {
+			a.nlabel++
+		}
+		if f, ok := attr(t, "for"); ok {
+			a.labelFor[f] = true
+		}
+	case "input", "select", "textarea":
+		if typ, _ := attr(t, "type"); t.Data == "input" && unlabeledInputs[strings.ToLower(typ)] {
+			return
+		}
+		if a.nlabel > 0 || accessibleName(t) != "" {
+			return
+		}
+		id, _ := attr(t, "id")
+		name, _ := attr(t, "name")
+		desc := fmt.Sprintf("%s %s", t.Data, strings.TrimSpace(id+" "+name))
+		if id == "" {
+			a.add("a11y-missing-label", strings.TrimSpace(desc))
+			return
+		}
+		a.controls = append(a.controls, id)
+	case "a", "button":
+		if a.cur.active() || t.Type != html.StartTagToken {
+			return
+		}
+		if t.Data == "a" {
+			if _, ok := attr(t, "href"); !ok {
+				return
+			}
+		}
+		a.curKind = t.Data
+		a.curName = accessibleName(t)
+		a.curDesc, _ = attr(t, "href")
+		if t.Data == "button" {
+			a.curDesc, _ = attr(t, "id")
+		}
+		a.cur.start(t.Data)
+	}
+}
+
+func (a *a11yAnalyzer) finish(res *result) {
+	for _, id := range a.controls {
+		if !a.labelFor[id] {
+			a.add("a11y-missing-label", "#"+id)
+		}
+	}
+	dups := make([]string, 0)
+	for id, n := range a.ids {
+		if n > 1 {
+			dups = append(dups, id)
+		}
+	}
+	sort.Strings(dups)
+	for _, id := range dups {
+		a.add("a11y-duplicate-id", fmt.Sprintf("%s (%d times)", id, a.ids[id]))
+	}
+	res.A11y = a.issues
+}
+
+// checkAccessibility adds, for each page, a finding for each type
+// of accessibility problem found with its count and examples.
+func checkAccessibility(rep *report) {
+	for _, res := range rep.sorted() {
+		if !res.page() {
+			continue
+		}
+		if res.Lang == "" {
+			rep.add("a11y-missing-lang", res.URL, "")
+		}
+		rep.addIssues(res.URL, res.A11y)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	nurl "net/url"
+	"strings"
+)
+
+// redirectsTo returns true if resp is a redirect to host.
+func redirectsTo(resp *response, host string) bool {
+	if resp.status < 300 || resp.status >= 400 {
+		return false
+	}
+	loc, err := nurl.Parse(resp.header.Get("Location"))
+	return err == nil && loc.Host == host
+}
+
+// hostVariants returns the URLs that should redirect to url:
+// url on http if it is on https and, with alias, the same on
+// the www alias of its host.
+func hostVariants(url string, alias bool) []string {
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return nil
+	}
+	hosts := []string{u.Host}
+	if alias {
+		hosts = append(hosts, aliasHost(u.Host))
+	}
+	schemes := []string{u.Scheme}
+	if u.Scheme == "https" {
+		schemes = append(schemes, "http")
+	}
+	var vs []string
+	for _, h := range hosts {
+		for _, s := range schemes {
+			if h == u.Host && s == u.Scheme {
+				continue
+			}
+			v := *u
+			v.Scheme, v.Host = s, h
+			vs = append(vs, v.String())
+		}
+	}
+	return vs
+}
+
+// checkAliases verifies that the variants of the crawled pages
+// on http, when the site is on https, and on the www alias of
+// the host, or the bare host if www was crawled, redirect in a
+// single hop to the crawled page. Only the first sample pages
+// are checked, all of them if sample is zero. The alias is left
+// out if it does not answer at all.
+func checkAliases(ctx context.Context, fetch *fetcher, rep *report, sample int) {
+	base, err := nurl.Parse(rep.base)
+	if err != nil {
+		return
+	}
+	alias := false
+	if net.ParseIP(base.Hostname()) == nil && base.Hostname() != "localhost" {
+		root := *base
+		root.Host = aliasHost(base.Host)
+		root.Path, root.RawQuery = "/", ""
+		resp, err := fetch.getDirect(ctx, root.String())
+		if err != nil {
+			slog.Info("alias host not reachable", "host", root.Host, "err", err)
+		} else {
+			alias = true
+			if !redirectsTo(resp, base.Host) {
+				rep.add("alias-host", root.String(), fmt.Sprintf("status %d, no redirect to %s", resp.status, base.Host))
+			}
+		}
+	}
+	n := 0
+	for _, res := range rep.sorted() {
+		if !res.page() {
+			continue
+		}
+		if sample > 0 && n >= sample {
+			break
+		}
+		n++
+		for _, v := range hostVariants(res.URL, alias) {
+			checkVariant(ctx, fetch, rep, v, res.URL)
+		}
+	}
+}
+
+// checkVariant adds a finding if the URL variant does not
+// redirect to url in a single hop.
+func checkVariant(ctx context.Context, fetch *fetcher, rep *report, variant, url string) {
+	resp, err := fetch.get(ctx, variant)
+	if err != nil {
+		rep.add("variant-error", variant, err.Error())
+		return
+	}
+	hops := resp.redirects
+	if len(hops) == 0 {
+		rep.add("variant-not-redirected", variant, fmt.Sprintf("status %d instead of a redirect to %s", resp.status, url))
+		return
+	}
+	if to := hops[len(hops)-1].To; !sameURL(to, url) {
+		rep.add("variant-wrong-target", variant, fmt.Sprintf("redirects to %s instead of %s", to, url))
+		return
+	}
+	if len(hops) > 1 {
+		chain := []string{variant}
+		for _, h := range hops {
+			chain = append(chain, fmt.Sprintf("%d %s", h.Status, h.To))
+		}
+		rep.add("variant-redirect-chain", variant, fmt.Sprintf("%d hops: %s", len(hops), strings.Join(chain, " -> ")))
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"sort"
+	"strings"
+)
+
+// genericAnchors are anchor texts that say nothing about the page
+// they link to.
+var genericAnchors = map[string]bool{
+	"click here": true, "here": true, "click": true, "this": true,
+	"this page": true, "link": true, "read more": true, "more": true,
+	"learn more": true, "see more": true, "more info": true,
+	"more information": true, "details": true, "continue": true,
+	"continue reading": true, "go": true, "go here": true, "view": true,
+	"view more": true, "find out more": true, "website": true,
+}
+
+// anchorTextKind returns "generic" if text does not describe the
+// target of the link, "empty" if there is no text, or "".
+func anchorTextKind(text, href, url string) string {
+	t := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	t = strings.Trim(t, ".:!»›>…→ ")
+	switch {
+	case t == "":
+		return "empty"
+	case genericAnchors[t]:
+		return "generic"
+	case t == strings.ToLower(href) || t == strings.ToLower(url):
+		return "generic"
+	case strings.HasPrefix(t, "http://") || strings.HasPrefix(t, "https://") || strings.HasPrefix(t, "www."):
+		return "generic"
+	}
+	return ""
+}
+
+// checkAnchorText adds a "generic-anchor-text" or "empty-anchor-text"
+// finding for each internal page linked with such texts, naming the
+// texts and the pages using them.
+func checkAnchorText(rep *report) {
+	type target struct {
+		texts   map[string]int
+		sources []string
+		seen    map[string]bool
+	}
+	targets := map[string]map[string]*target{"generic": {}, "empty": {}}
+	for _, res := range rep.sorted() {
+		src, err := nurl.Parse(res.URL)
+		if err != nil {
+			continue
+		}
+		for _, a := range res.Anchors {
+			u, err := nurl.Parse(a.URL)
+			if err != nil || (u.Host != src.Host && u.Host != aliasHost(src.Host)) {
+				continue
+			}
+			kind := anchorTextKind(a.Text, a.Href, a.URL)
+			if kind == "" {
+				continue
+			}
+			u.Host = src.Host
+			u.Fragment = ""
+			url := strings.TrimSuffix(u.String(), "/")
+			t := targets[kind][url]
+			if t == nil {
+				t = &target{texts: make(map[string]int), seen: make(map[string]bool)}
+				targets[kind][url] = t
+			}
+			t.texts[strings.Join(strings.Fields(a.Text), " ")]++
+			if !t.seen[res.URL] {
+				t.seen[res.URL] = true
+				t.sources = append(t.sources, res.URL)
+			}
+		}
+	}
+	for _, kind := range []string{"generic", "empty"} {
+		urls := make([]string, 0, len(targets[kind]))
+		for url := range targets[kind] {
+			urls = append(urls, url)
+		}
+		sort.Strings(urls)
+		for _, url := range urls {
+			t := targets[kind][url]
+			var n int
+			for _, c := range t.texts {
+				n += c
+			}
+			detail := fmt.Sprintf("%d links, %s", n, linkedFrom(t.sources))
+			if kind == "generic" {
+				texts := make([]string, 0, len(t.texts))
+				for text := range t.texts {
+					texts = append(texts, text)
+				}
+				sort.Slice(texts, func(i, j int) bool {
+					if t.texts[texts[i]] != t.texts[texts[j]] {
+						return t.texts[texts[i]] > t.texts[texts[j]]
+					}
+					return texts[i] < texts[j]
+				})
+				detail = fmt.Sprintf("%s in %s", quoteAll(texts), detail)
+			}
+			rep.add(kind+"-anchor-text", url, detail)
+		}
+	}
+}
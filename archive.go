@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+const (
+	archiveDir   = "bodies/"
+	archiveIndex = archiveDir + "index.jsonl"
+)
+
+// archiveEntry describes a stored response body.
+type archiveEntry struct {
+	URL    string      `json:"url"`
+	File   string      `json:"file"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Gzip   bool        `json:"gzip,omitempty"`
+}
+
+// bodyArchive stores response bodies in a storage, so that
+// pages can be inspected or analyzed again later without
+// fetching them. It is safe for concurrent use.
+type bodyArchive struct {
+	st       storage
+	compress bool
+	mux      sync.Mutex
+	entries  []archiveEntry
+}
+
+func newBodyArchive(st storage, compress bool) *bodyArchive {
+	return &bodyArchive{st: st, compress: compress}
+}
+
+// archiveFile returns the name of the file for url.
+func archiveFile(url string, compress bool) string {
+	h := sha1.Sum([]byte(url))
+	name := archiveDir + hex.EncodeToString(h[:]) + ".html"
+	if compress {
+		name += ".gz"
+	}
+	return name
+}
+
+// store saves the body of resp, fetched from url.
+func (a *bodyArchive) store(url string, resp *response) error {
+	data := resp.body
+	if a.compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	e := archiveEntry{
+		URL:    url,
+		File:   archiveFile(url, a.compress),
+		Status: resp.status,
+		Header: resp.header,
+		Gzip:   a.compress,
+	}
+	if err := a.st.put(e.File, data); err != nil {
+		return err
+	}
+	a.mux.Lock()
+	a.entries = append(a.entries, e)
+	a.mux.Unlock()
+	return nil
+}
+
+// close writes the index of all stored bodies.
+func (a *bodyArchive) close() error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	sort.Slice(a.entries, func(i, j int) bool {
+		return a.entries[i].URL < a.entries[j].URL
+	})
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range a.entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return a.st.put(archiveIndex, buf.Bytes())
+}
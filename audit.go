@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	nurl "net/url"
+	"os"
+	"strings"
+)
+
+// auditCmd implements the "audit" subcommand: fetch a single
+// page and print everything found on it, without crawling.
+func auditCmd(opts *options, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: seopeo [flags] audit URL\n")
+		return 2
+	}
+	u, err := nurl.Parse(args[0])
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		slog.Error("not an absolute HTTP URL", "url", args[0])
+		return 2
+	}
+	fetch, err := optionsFetcher(opts)
+	if err != nil {
+		slog.Error("cannot start fetcher", "err", err)
+		return 1
+	}
+	res, err := audit(fetch, u)
+	if err != nil {
+		slog.Error("cannot audit page", "url", u.String(), "err", err)
+		return 1
+	}
+	writeAudit(os.Stdout, res)
+	return 0
+}
+
+// audit fetches and analyzes the page at u.
+func audit(fetch *fetcher, u *nurl.URL) (*result, error) {
+	resp, err := fetch.get(context.Background(), u.String())
+	if err != nil {
+		return nil, err
+	}
+	// The page audited is the one the redirects end at.
+	res := &result{URL: u.String()}
+	if n := len(resp.redirects); n > 0 {
+		res.URL = resp.redirects[n-1].To
+		resp.redirects = nil
+	}
+	if err := analyze(res, resp, slog.With("url", res.URL)); err != nil {
+		return nil, err
+	}
+	res.Findings = pageFindings(res)
+	return res, nil
+}
+
+// writeAudit prints all on-page data of res in sections.
+func writeAudit(w io.Writer, res *result) {
+	field := func(name, value string) {
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(w, "%-12s %s\n", name, value)
+	}
+	field("URL", res.URL)
+	field("Status", fmt.Sprintf("%d", res.Status))
+	field("Title", res.Title)
+	field("Description", res.Description)
+	field("Canonical", strings.Join(res.Canonicals, ", "))
+	field("Robots", res.Robots)
+	field("X-Robots-Tag", res.XRobotsTag)
+
+	fmt.Fprintf(w, "\nMeta tags (%d)\n", len(res.Metas))
+	for _, m := range res.Metas {
+		fmt.Fprintf(w, "\t%s\t%s\n", m.Name, m.Content)
+	}
+
+	fmt.Fprintf(w, "\nHeadings (%d)\n", len(res.Headings))
+	for _, h := range res.Headings {
+		fmt.Fprintf(w, "\t%sh%d %s\n", strings.Repeat("  ", h.Level-1), h.Level, h.Text)
+	}
+
+	fmt.Fprintf(w, "\nLinks (%d, %d internal)\n", len(res.Anchors), len(res.Links))
+	for _, a := range res.Anchors {
+		line := fmt.Sprintf("\t%s\t%q", a.URL, a.Text)
+		if a.Rel != "" {
+			line += "\trel=" + a.Rel
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintf(w, "\nFindings (%d)\n", len(res.Findings))
+	for _, f := range res.Findings {
+		line := "\t" + f.Kind
+		if f.Detail != "" {
+			line += "\t" + f.Detail
+		}
+		fmt.Fprintln(w, line)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fakeSite serves a generated site of pages numbered from 0,
+// each linking to the next branch pages of a tree and back to
+// the home page. Which pages fail is fixed by their number.
+type fakeSite struct {
+	pages     int
+	branch    int
+	latency   time.Duration
+	errorRate float64
+}
+
+// fails returns true if page n answers with an error.
+func (s *fakeSite) fails(n int) bool {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d", n)
+	return float64(h.Sum32()%1000)/1000 < s.errorRate
+}
+
+func (s *fakeSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.latency > 0 {
+		select {
+		case <-time.After(s.latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	n := 0
+	if r.URL.Path != "/" {
+		var err error
+		n, err = strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/p/"))
+		if err != nil || n <= 0 || n >= s.pages {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	if n > 0 && s.fails(n) {
+		http.Error(w, "synthetic error", http.StatusInternalServerError)
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "<html><head><title>Page %d</title></head><body><h1>Page %d</h1>\n<a href=\"/\">Home</a>\n", n, n)
+	for i := n*s.branch + 1; i <= n*s.branch+s.branch && i < s.pages; i++ {
+		fmt.Fprintf(&b, "<a href=\"/p/%d\">Page %d</a>\n", i, i)
+	}
+	b.WriteString("</body></html>\n")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, b.String())
+}
+
+// benchCmd implements the "bench" subcommand: crawl a fake site
+// served in-process and report the throughput.
+func benchCmd(opts *options, args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	site := &fakeSite{}
+	fs.IntVar(&site.pages, "pages", 1000, "number of pages of the site")
+	fs.IntVar(&site.branch, "branch", 10, "links from each page to new pages")
+	fs.DurationVar(&site.latency, "latency", 10*time.Millisecond, "time to answer each request")
+	fs.Float64Var(&site.errorRate, "error-rate", 0.01, "share of pages, from 0 to 1, that fail with status 500")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: seopeo [flags] bench [-pages N] [-branch N] [-latency D] [-error-rate R]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if site.pages < 1 || site.branch < 1 || site.errorRate < 0 || site.errorRate > 1 {
+		slog.Error("pages and branch must be at least 1, error-rate between 0 and 1")
+		return 2
+	}
+	srv := httptest.NewServer(site)
+	defer srv.Close()
+	fetch, err := optionsFetcher(opts)
+	if err != nil {
+		slog.Error("cannot start fetcher", "err", err)
+		return 1
+	}
+	filter, err := newURLFilter(opts.include, opts.exclude)
+	if err != nil {
+		slog.Error("invalid URL filter", "err", err)
+		return 1
+	}
+	c, err := newCrawler(context.Background(), []string{srv.URL}, opts.workers, fetch, filter)
+	if err != nil {
+		slog.Error("cannot start crawler", "err", err)
+		return 1
+	}
+	start := time.Now()
+	c.start()
+	c.wait()
+	elapsed := time.Since(start)
+	failed := 0
+	for _, res := range c.results {
+		if res.Err != nil || res.Status != http.StatusOK {
+			failed++
+		}
+	}
+	fmt.Printf("pages %d, failed %d, workers %d, elapsed %s, %.1f pages/s\n",
+		len(c.results), failed, opts.workers, elapsed.Round(time.Millisecond),
+		float64(len(c.results))/elapsed.Seconds())
+	return 0
+}
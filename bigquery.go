@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	bigqueryEndpoint  = "https://bigquery.googleapis.com/bigquery/v2/projects/%s/datasets/%s/tables"
+	bigqueryBatchSize = 500
+)
+
+type bigqueryField struct {
+	Name   string          `json:"name"`
+	Type   string          `json:"type"`
+	Mode   string          `json:"mode,omitempty"`
+	Fields []bigqueryField `json:"fields,omitempty"`
+}
+
+// bigquerySchema mirrors doc; new fields can be appended,
+// existing tables are patched to include them.
+var bigquerySchema = []bigqueryField{
+	{Name: "url", Type: "STRING", Mode: "REQUIRED"},
+	{Name: "schema_version", Type: "INTEGER"},
+	{Name: "host", Type: "STRING"},
+	{Name: "path", Type: "STRING"},
+	{Name: "crawled_at", Type: "TIMESTAMP"},
+	{Name: "outlinks", Type: "INTEGER"},
+	{Name: "crux", Type: "RECORD", Fields: []bigqueryField{
+		{Name: "lcp", Type: "FLOAT"},
+		{Name: "cls", Type: "FLOAT"},
+		{Name: "inp", Type: "FLOAT"},
+		{Name: "origin", Type: "BOOLEAN"},
+	}},
+	{Name: "gsc", Type: "RECORD", Fields: []bigqueryField{
+		{Name: "clicks", Type: "FLOAT"},
+		{Name: "impressions", Type: "FLOAT"},
+		{Name: "coverage", Type: "STRING"},
+		{Name: "verdict", Type: "STRING"},
+	}},
+	{Name: "findings", Type: "RECORD", Mode: "REPEATED", Fields: []bigqueryField{
+		{Name: "kind", Type: "STRING"},
+		{Name: "detail", Type: "STRING"},
+	}},
+	{Name: "readability", Type: "RECORD", Fields: []bigqueryField{
+		{Name: "words", Type: "INTEGER"},
+		{Name: "sentences", Type: "INTEGER"},
+		{Name: "ease", Type: "FLOAT"},
+		{Name: "grade", Type: "FLOAT"},
+	}},
+	{Name: "status", Type: "INTEGER"},
+	{Name: "error", Type: "STRING"},
+	{Name: "depth", Type: "INTEGER"},
+	{Name: "title", Type: "STRING"},
+	{Name: "description", Type: "STRING"},
+	{Name: "lang", Type: "STRING"},
+	{Name: "canonical", Type: "STRING"},
+	{Name: "robots", Type: "STRING"},
+	{Name: "x_robots_tag", Type: "STRING"},
+	{Name: "hash", Type: "STRING"},
+	{Name: "protocol", Type: "STRING"},
+	{Name: "remote_addr", Type: "STRING"},
+	{Name: "ip_family", Type: "STRING"},
+	{Name: "redirects", Type: "INTEGER"},
+}
+
+type bigqueryTable struct {
+	TableReference struct {
+		ProjectID string `json:"projectId"`
+		DatasetID string `json:"datasetId"`
+		TableID   string `json:"tableId"`
+	} `json:"tableReference"`
+	Schema struct {
+		Fields []bigqueryField `json:"fields"`
+	} `json:"schema"`
+}
+
+type bigqueryRow struct {
+	InsertID string `json:"insertId"`
+	JSON     *doc   `json:"json"`
+}
+
+// bigquerySink streams results into a BigQuery table using
+// an OAuth2 access token, creating the table if needed.
+type bigquerySink struct {
+	project, dataset, table string
+	token                   string
+	started                 time.Time
+	// run tells apart the rows of each run, also when started is
+	// fixed in deterministic mode.
+	run    int64
+	client *http.Client
+	rows   []bigqueryRow
+}
+
+// newBigquerySink parses table as project.dataset.table.
+func newBigquerySink(table, token string) (*bigquerySink, error) {
+	parts := strings.Split(table, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("table %s is not in the form project.dataset.table", table)
+	}
+	bq := &bigquerySink{
+		project: parts[0],
+		dataset: parts[1],
+		table:   parts[2],
+		token:   token,
+		started: now(),
+		run:     time.Now().UnixNano(),
+		client:  http.DefaultClient,
+		rows:    make([]bigqueryRow, 0, bigqueryBatchSize),
+	}
+	if err := bq.ensureTable(); err != nil {
+		return nil, err
+	}
+	return bq, nil
+}
+
+func (bq *bigquerySink) do(method, path string, req, resp interface{}) (int, error) {
+	var body bytes.Buffer
+	if req != nil {
+		if err := json.NewEncoder(&body).Encode(req); err != nil {
+			return 0, err
+		}
+	}
+	endpoint := fmt.Sprintf(bigqueryEndpoint, bq.project, bq.dataset) + path
+	hreq, err := http.NewRequest(method, endpoint, &body)
+	if err != nil {
+		return 0, err
+	}
+	hreq.Header.Set("Authorization", "Bearer "+bq.token)
+	hreq.Header.Set("Content-Type", "application/json")
+	hresp, err := bq.client.Do(hreq)
+	if err != nil {
+		return 0, fmt.Errorf("cannot reach BigQuery: %s", err)
+	}
+	defer hresp.Body.Close()
+	if hresp.StatusCode != http.StatusOK {
+		return hresp.StatusCode, fmt.Errorf("BigQuery returned %s", hresp.Status)
+	}
+	if resp == nil {
+		return hresp.StatusCode, nil
+	}
+	if err := json.NewDecoder(hresp.Body).Decode(resp); err != nil {
+		return hresp.StatusCode, fmt.Errorf("cannot decode BigQuery response: %s", err)
+	}
+	return hresp.StatusCode, nil
+}
+
+// ensureTable creates the table, or adds to it the columns
+// from bigquerySchema that it is missing.
+func (bq *bigquerySink) ensureTable() error {
+	var t bigqueryTable
+	status, err := bq.do("GET", "/"+bq.table, nil, &t)
+	if status == http.StatusNotFound {
+		t.TableReference.ProjectID = bq.project
+		t.TableReference.DatasetID = bq.dataset
+		t.TableReference.TableID = bq.table
+		t.Schema.Fields = bigquerySchema
+		if _, err := bq.do("POST", "", &t, nil); err != nil {
+			return fmt.Errorf("cannot create table: %s", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	have := make(map[string]bool)
+	for _, f := range t.Schema.Fields {
+		have[f.Name] = true
+	}
+	fields := t.Schema.Fields
+	for _, f := range bigquerySchema {
+		if !have[f.Name] {
+			fields = append(fields, f)
+		}
+	}
+	if len(fields) == len(t.Schema.Fields) {
+		return nil
+	}
+	t.Schema.Fields = fields
+	if _, err := bq.do("PATCH", "/"+bq.table, &t, nil); err != nil {
+		return fmt.Errorf("cannot update table schema: %s", err)
+	}
+	return nil
+}
+
+func (bq *bigquerySink) put(res *result) error {
+	bq.rows = append(bq.rows, bigqueryRow{
+		InsertID: fmt.Sprintf("%d-%x", bq.run, sha1.Sum([]byte(res.URL))),
+		JSON:     newDoc(res, bq.started),
+	})
+	if len(bq.rows) >= bigqueryBatchSize {
+		return bq.flush()
+	}
+	return nil
+}
+
+type bigqueryInsertResponse struct {
+	InsertErrors []struct {
+		Index  int `json:"index"`
+		Errors []struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"insertErrors"`
+}
+
+// flush streams the buffered rows with insertAll.
+func (bq *bigquerySink) flush() error {
+	if len(bq.rows) == 0 {
+		return nil
+	}
+	req := map[string]interface{}{
+		"kind": "bigquery#tableDataInsertAllRequest",
+		"rows": bq.rows,
+	}
+	var resp bigqueryInsertResponse
+	_, err := bq.do("POST", "/"+bq.table+"/insertAll", req, &resp)
+	bq.rows = bq.rows[:0]
+	if err != nil {
+		return err
+	}
+	for _, ie := range resp.InsertErrors {
+		for _, e := range ie.Errors {
+			return fmt.Errorf("cannot insert row %d: %s: %s", ie.Index, e.Reason, e.Message)
+		}
+	}
+	return nil
+}
+
+func (bq *bigquerySink) close() error {
+	return bq.flush()
+}
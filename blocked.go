@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	nurl "net/url"
+	"sort"
+	"strings"
+)
+
+// maxLinkedFrom is how many linking pages a finding names.
+const maxLinkedFrom = 5
+
+// inbound returns, for each linked URL, the crawled pages
+// linking to it, in report order.
+func (r *report) inbound() map[string][]string {
+	in := make(map[string][]string)
+	for _, res := range r.sorted() {
+		seen := make(map[string]bool)
+		for _, l := range res.Links {
+			if !seen[l] {
+				seen[l] = true
+				in[l] = append(in[l], res.URL)
+			}
+		}
+	}
+	return in
+}
+
+// pageList names the first pages for a finding.
+func pageList(pages []string) string {
+	if len(pages) <= maxLinkedFrom {
+		return strings.Join(pages, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(pages[:maxLinkedFrom], ", "), len(pages)-maxLinkedFrom)
+}
+
+// linkedFrom describes the pages in sources for a finding.
+func linkedFrom(sources []string) string {
+	return "linked from " + pageList(sources)
+}
+
+// checkNoindexLinks adds a "noindex-but-linked" finding for each
+// noindex page that still gets internal links.
+func checkNoindexLinks(rep *report) {
+	in := rep.inbound()
+	for _, res := range rep.sorted() {
+		if sources := in[res.URL]; len(sources) > 0 && res.noindex() {
+			rep.add("noindex-but-linked", res.URL, linkedFrom(sources))
+		}
+	}
+}
+
+// checkDisallowedLinks adds a "disallowed-but-linked" finding
+// for each linked URL that robots.txt forbids agent to fetch.
+func checkDisallowedLinks(rep *report, rt *robotsTxt, agent string) {
+	in := rep.inbound()
+	urls := make([]string, 0, len(in))
+	for url := range in {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	for _, url := range urls {
+		ok, rule := rt.test(agent, url)
+		if ok {
+			continue
+		}
+		why := "robots.txt disallows everything"
+		if rule != nil {
+			why = fmt.Sprintf("Disallow: %s (line %d)", rule.pattern, rule.line)
+		}
+		rep.add("disallowed-but-linked", url, why+", "+linkedFrom(in[url]))
+	}
+}
+
+// checkBlockedResources adds a "blocked-resource" finding for each
+// stylesheet, script or image used by the crawled pages that the
+// robots.txt of its site forbids agent to fetch; robots.txt of
+// other sites is downloaded as needed. It returns the blocked
+// URLs.
+func checkBlockedResources(ctx context.Context, fetch *fetcher, rep *report, agent string) map[string]bool {
+	used := make(map[string][]string)
+	kinds := make(map[string]string)
+	for _, res := range rep.sorted() {
+		rs := res.Resources
+		for _, img := range res.Images {
+			rs = append(rs, resource{Kind: "image", URL: img.URL})
+		}
+		for _, r := range rs {
+			if kinds[r.URL] == "" {
+				kinds[r.URL] = r.Kind
+			}
+			if u := used[r.URL]; len(u) == 0 || u[len(u)-1] != res.URL {
+				used[r.URL] = append(u, res.URL)
+			}
+		}
+	}
+	urls := make([]string, 0, len(used))
+	for url := range used {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	robots := make(map[string]*robotsTxt)
+	blocked := make(map[string]bool)
+	for _, url := range urls {
+		u, err := nurl.Parse(url)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		site := u.Scheme + "://" + u.Host
+		rt, ok := robots[site]
+		if !ok {
+			if rt, _, err = fetchRobots(ctx, fetch, u); err != nil {
+				slog.Warn("cannot fetch robots.txt", "site", site, "err", err)
+			}
+			robots[site] = rt
+		}
+		if rt == nil {
+			continue
+		}
+		allowed, rule := rt.test(agent, url)
+		if allowed {
+			continue
+		}
+		why := "robots.txt disallows everything"
+		if rule != nil {
+			why = fmt.Sprintf("Disallow: %s (line %d)", rule.pattern, rule.line)
+		}
+		rep.add("blocked-resource", url, fmt.Sprintf("%s, %s, used by %s", kinds[url], why, pageList(used[url])))
+		blocked[url] = true
+	}
+	return blocked
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// breadcrumbs are the trails of a page, as URLs from the top of
+// the site down: from BreadcrumbList markup and from the visible
+// breadcrumb navigation. The last element of the markup trail can
+// be empty, meaning the page itself.
+type breadcrumbs struct {
+	Markup  []string
+	Visible []string
+}
+
+// ldPosition returns the position of a ListItem.
+func ldPosition(li map[string]interface{}) float64 {
+	switch p := li["position"].(type) {
+	case float64:
+		return p
+	case string:
+		n, _ := strconv.ParseFloat(p, 64)
+		return n
+	}
+	return 0
+}
+
+// breadcrumbAnalyzer collects the trails of the page: the first
+// BreadcrumbList of its JSON-LD and the links inside the first
+// element whose class or ARIA label mentions breadcrumbs.
+type breadcrumbAnalyzer struct {
+	p       *page
+	tag     string // of the breadcrumb element, while inside it
+	depth   int
+	done    bool
+	visible []string
+}
+
+func isBreadcrumb(t *html.Token) bool {
+	for _, key := range []string{"class", "aria-label", "id"} {
+		if v, ok := attr(t, key); ok && strings.Contains(strings.ToLower(v), "breadcrumb") {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *breadcrumbAnalyzer) token(t *html.Token) {
+	if a.done {
+		return
+	}
+	if a.depth == 0 {
+		if t.Type == html.StartTagToken && isBreadcrumb(t) {
+			a.tag, a.depth = t.Data, 1
+		}
+		return
+	}
+	switch t.Type {
+	case html.StartTagToken:
+		if t.Data == a.tag {
+			a.depth++
+		}
+		if href, ok := attr(t, "href"); ok && t.Data == "a" {
+			a.visible = append(a.visible, a.p.resolve(href))
+		}
+	case html.EndTagToken:
+		if t.Data == a.tag {
+			a.depth--
+			a.done = a.depth == 0
+		}
+	}
+}
+
+func (a *breadcrumbAnalyzer) finish(res *result) {
+	var markup []string
+	for _, v := range a.p.ld {
+		if markup != nil {
+			break
+		}
+		findTyped(v, func(o map[string]interface{}) {
+			if markup != nil {
+				return
+			}
+			items := ldObjects(o["itemListElement"])
+			sort.SliceStable(items, func(i, j int) bool { return ldPosition(items[i]) < ldPosition(items[j]) })
+			markup = make([]string, len(items))
+			for i, li := range items {
+				if s := jsonString(li["item"]); s != "" {
+					markup[i] = a.p.resolve(s)
+				}
+			}
+		}, "BreadcrumbList")
+	}
+	if markup != nil || a.visible != nil {
+		res.Breadcrumbs = &breadcrumbs{Markup: markup, Visible: a.visible}
+	}
+}
+
+// isAncestor returns true if the path of url is above or the
+// same as the path of page.
+func isAncestor(url, page string) bool {
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return false
+	}
+	p, err := nurl.Parse(page)
+	if err != nil || u.Host != p.Host {
+		return false
+	}
+	up := strings.TrimSuffix(u.Path, "/")
+	return up == "" || p.Path == up || strings.HasPrefix(p.Path, up+"/")
+}
+
+// checkBreadcrumbs adds findings for breadcrumb trails that lead
+// to broken pages ("breadcrumb-dead-link"), to pages not above
+// the page in the path hierarchy ("breadcrumb-hierarchy"), whose
+// markup does not match the visible navigation
+// ("breadcrumb-mismatch"), and for pages without breadcrumbs in
+// directories where most pages have them ("breadcrumb-missing").
+func checkBreadcrumbs(rep *report) {
+	type dirCount struct{ pages, with int }
+	dirs := make(map[string]*dirCount)
+	var pages []*result
+	for _, res := range rep.sorted() {
+		if !res.page() || urlPath(res.URL) == "/" {
+			continue
+		}
+		pages = append(pages, res)
+		d := dirs[directory(res.URL)]
+		if d == nil {
+			d = &dirCount{}
+			dirs[directory(res.URL)] = d
+		}
+		d.pages++
+		if res.Breadcrumbs != nil {
+			d.with++
+		}
+	}
+	for _, res := range pages {
+		bc := res.Breadcrumbs
+		if bc == nil {
+			if d := dirs[directory(res.URL)]; d.pages > 1 && 2*d.with > d.pages {
+				rep.add("breadcrumb-missing", res.URL, fmt.Sprintf("%d of %d pages under %s have breadcrumbs", d.with, d.pages, directory(res.URL)))
+			}
+			continue
+		}
+		trail := bc.Markup
+		if trail == nil {
+			trail = bc.Visible
+		}
+		for i, url := range trail {
+			if url == "" || sameURL(url, res.URL) {
+				continue
+			}
+			if t := rep.lookup(url); t != nil && t.broken() {
+				rep.add("breadcrumb-dead-link", res.URL, fmt.Sprintf("item %d %s: %s", i+1, url, statusString(t)))
+			} else if !isAncestor(url, res.URL) {
+				rep.add("breadcrumb-hierarchy", res.URL, fmt.Sprintf("item %d %s is not above the page", i+1, url))
+			}
+		}
+		if bc.Markup != nil && bc.Visible != nil {
+			markup := bc.Markup
+			// The page itself is often left out of the links.
+			if n := len(markup); n > 0 && (markup[n-1] == "" || sameURL(markup[n-1], res.URL)) {
+				markup = markup[:n-1]
+			}
+			visible := bc.Visible
+			if n := len(visible); n > 0 && sameURL(visible[n-1], res.URL) {
+				visible = visible[:n-1]
+			}
+			if !sameTrail(markup, visible) {
+				rep.add("breadcrumb-mismatch", res.URL, fmt.Sprintf("markup %s, visible %s", strings.Join(markup, " > "), strings.Join(visible, " > ")))
+			}
+		}
+	}
+}
+
+func sameTrail(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameURL(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxBudgetChunk is the most bytes read at once from a throttled
+// response, so that waits are spread over the transfer.
+const maxBudgetChunk = 16 << 10
+
+// byteBudget limits the bytes read from responses by all
+// workers: their rate, by making readers wait, and their total,
+// which the crawler checks to stop scheduling.
+type byteBudget struct {
+	rate  float64 // bytes per second, 0 for no limit
+	max   int64   // total bytes, 0 for no limit
+	mux   sync.Mutex
+	next  time.Time // when the bytes read so far are paid for
+	total int64
+}
+
+func newByteBudget(rate float64, max int64) *byteBudget {
+	return &byteBudget{rate: rate, max: max}
+}
+
+// wait accounts for n bytes just read and waits as long as
+// the rate requires, or until ctx is done.
+func (b *byteBudget) wait(ctx context.Context, n int) error {
+	b.mux.Lock()
+	b.total += int64(n)
+	var d time.Duration
+	if b.rate > 0 {
+		t := time.Now()
+		if b.next.Before(t) {
+			b.next = t
+		}
+		b.next = b.next.Add(time.Duration(float64(n) / b.rate * float64(time.Second)))
+		d = b.next.Sub(t)
+	}
+	b.mux.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// exceeded returns true once the total has been read.
+func (b *byteBudget) exceeded() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.max > 0 && b.total >= b.max
+}
+
+// budgetReader reads from r within budget b.
+type budgetReader struct {
+	ctx context.Context
+	r   io.Reader
+	b   *byteBudget
+}
+
+func (br *budgetReader) Read(p []byte) (int, error) {
+	if len(p) > maxBudgetChunk {
+		p = p[:maxBudgetChunk]
+	}
+	n, err := br.r.Read(p)
+	if n > 0 {
+		if werr := br.b.wait(br.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
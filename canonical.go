@@ -0,0 +1,126 @@
+package main
+
+import (
+	"io"
+	"strings"
+)
+
+// sameURL compares two URLs ignoring a trailing slash.
+func sameURL(a, b string) bool {
+	return strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/")
+}
+
+// lookup returns the result for url, also when it was crawled
+// with or without a trailing slash, or nil.
+func (r *report) lookup(url string) *result {
+	for _, u := range []string{url, strings.TrimSuffix(url, "/"), url + "/"} {
+		if res, ok := r.results[u]; ok {
+			return res
+		}
+	}
+	return nil
+}
+
+// canonicalTarget returns the crawled page res declares as its
+// canonical, or nil if there is none or res is its own canonical.
+func (r *report) canonicalTarget(res *result) *result {
+	if res.Canonical == "" || sameURL(res.Canonical, res.URL) {
+		return nil
+	}
+	return r.lookup(res.Canonical)
+}
+
+// checkCanonicals follows canonicals from page to page and adds
+// a "canonical-chain" finding for pages whose canonical declares
+// a canonical again, and a "canonical-loop" finding for pages
+// whose canonicals lead back to a page already seen.
+func checkCanonicals(rep *report) {
+	for _, res := range rep.sorted() {
+		path := []string{res.URL}
+		seen := map[*result]bool{res: true}
+		cur := res
+		for {
+			next := rep.canonicalTarget(cur)
+			if next == nil {
+				break
+			}
+			path = append(path, next.URL)
+			if seen[next] {
+				rep.add("canonical-loop", res.URL, strings.Join(path, " -> "))
+				path = nil
+				break
+			}
+			seen[next] = true
+			cur = next
+		}
+		if len(path) > 2 {
+			rep.add("canonical-chain", res.URL, strings.Join(path, " -> "))
+		}
+	}
+}
+
+// canonicalRoot follows the canonicals from res to the last
+// crawled page, stopping at loops.
+func (r *report) canonicalRoot(res *result) *result {
+	seen := map[*result]bool{res: true}
+	for {
+		next := r.canonicalTarget(res)
+		if next == nil || seen[next] {
+			return res
+		}
+		seen[next] = true
+		res = next
+	}
+}
+
+// variants maps each result of list to the page it is a variant
+// of: its canonical or, for pages with identical content, the one
+// with the shortest URL. Results that are nobody's variant map to
+// themselves.
+func (r *report) variants(list []*result) map[*result]*result {
+	roots := make(map[*result]*result, len(list))
+	byHash := make(map[string]*result)
+	for _, res := range list {
+		root := r.canonicalRoot(res)
+		roots[res] = root
+		if !root.loaded() || root.Hash == "" {
+			continue
+		}
+		if cur, ok := byHash[root.Hash]; !ok || len(root.URL) < len(cur.URL) ||
+			(len(root.URL) == len(cur.URL) && root.URL < cur.URL) {
+			byHash[root.Hash] = root
+		}
+	}
+	for res, root := range roots {
+		if same, ok := byHash[root.Hash]; ok && root.Hash != "" {
+			roots[res] = same
+		}
+	}
+	return roots
+}
+
+// writeFolded prints the results that are not variants of
+// another page, each followed by its variants.
+func (r *report) writeFolded(w io.Writer, list []*result) error {
+	roots := r.variants(list)
+	folded := make(map[*result][]*result)
+	for _, res := range list {
+		if root := roots[res]; root != res {
+			folded[root] = append(folded[root], res)
+		}
+	}
+	for _, res := range list {
+		if roots[res] != res {
+			continue
+		}
+		if err := writeResult(w, "", res); err != nil {
+			return err
+		}
+		for _, v := range folded[res] {
+			if err := writeResult(w, "\t", v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	nurl "net/url"
+	"strings"
+	"time"
+)
+
+// hostCert is the certificate a host presented.
+type hostCert struct {
+	host    string
+	expires time.Time
+	issuer  string
+	names   []string
+	chain   int // certificates sent, including the leaf
+	err     error
+}
+
+// fetchCert connects to host, a host with optional port, with the
+// dialer of f and returns the certificates it sends, without
+// verifying them.
+func fetchCert(ctx context.Context, f *fetcher, host string) ([]*x509.Certificate, error) {
+	addr := host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		addr = net.JoinHostPort(host, "443")
+	}
+	dial := f.httpTransport().DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: dialTimeout}).DialContext
+	}
+	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect: %s", err)
+	}
+	defer conn.Close()
+	tc := tls.Client(conn, &tls.Config{ServerName: hostname(host), InsecureSkipVerify: true})
+	if err := tc.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("cannot complete TLS handshake: %s", err)
+	}
+	certs := tc.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate sent")
+	}
+	return certs, nil
+}
+
+// chainProblem returns the finding kind and detail if the chain
+// of leaf, with the intermediates sent, does not lead to a
+// trusted root, or empty strings.
+func chainProblem(leaf *x509.Certificate, sent []*x509.Certificate) (string, string) {
+	pool := x509.NewCertPool()
+	issued := false
+	for _, c := range sent {
+		pool.AddCert(c)
+		if c.Subject.String() == leaf.Issuer.String() {
+			issued = true
+		}
+	}
+	// Expiry is checked on its own, verify the chain as it was
+	// when the leaf was still valid.
+	at := time.Now()
+	if at.After(leaf.NotAfter) {
+		at = leaf.NotAfter
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{Intermediates: pool, CurrentTime: at})
+	var unknown x509.UnknownAuthorityError
+	switch {
+	case err == nil:
+		return "", ""
+	case errors.As(err, &unknown) && leaf.Issuer.String() == leaf.Subject.String():
+		return "cert-untrusted", "self-signed"
+	case errors.As(err, &unknown) && !issued:
+		return "cert-chain-incomplete", "intermediate for " + leaf.Issuer.CommonName + " not sent"
+	}
+	return "cert-untrusted", err.Error()
+}
+
+// checkCertificates fetches the certificates of the crawled HTTPS
+// hosts and reports those that are expired or expire within warn,
+// do not cover the host name or lack intermediates to a trusted
+// root. It returns what was found for each host.
+func checkCertificates(ctx context.Context, f *fetcher, rep *report, warn time.Duration) []*hostCert {
+	hosts := make(map[string]bool)
+	for url := range rep.results {
+		if u, err := nurl.Parse(url); err == nil && u.Scheme == "https" {
+			hosts[u.Host] = true
+		}
+	}
+	var list []*hostCert
+	now := time.Now()
+	for _, host := range sortedKeys(hosts) {
+		hc := &hostCert{host: host}
+		list = append(list, hc)
+		url := "https://" + host
+		certs, err := fetchCert(ctx, f, host)
+		if err != nil {
+			slog.Error("cannot fetch certificate", "host", host, "err", err)
+			hc.err = err
+			rep.add("cert-error", url, err.Error())
+			continue
+		}
+		leaf := certs[0]
+		hc.expires = leaf.NotAfter
+		hc.issuer = leaf.Issuer.CommonName
+		hc.names = leaf.DNSNames
+		hc.chain = len(certs)
+		for i, c := range certs {
+			what := "certificate"
+			if i > 0 {
+				what = "intermediate " + c.Subject.CommonName
+			}
+			switch left := c.NotAfter.Sub(now); {
+			case left < 0:
+				rep.add("cert-expired", url, fmt.Sprintf("%s expired on %s", what, c.NotAfter.Format("2006-01-02")))
+			case left < warn:
+				rep.add("cert-expiring", url, fmt.Sprintf("%s expires in %d days on %s", what, int(left.Hours()/24), c.NotAfter.Format("2006-01-02")))
+			}
+		}
+		if err := leaf.VerifyHostname(hostname(host)); err != nil {
+			rep.add("cert-name-mismatch", url, fmt.Sprintf("%s not in %s", hostname(host), strings.Join(leaf.DNSNames, ", ")))
+		}
+		if kind, detail := chainProblem(leaf, certs[1:]); kind != "" {
+			rep.add(kind, url, detail)
+		}
+	}
+	return list
+}
+
+// writeCerts prints the certificate of each host: its expiry,
+// issuer, the length of the chain sent and the names it covers.
+func (r *report) writeCerts(w io.Writer) error {
+	for _, hc := range r.certs {
+		if hc.err != nil {
+			if _, err := fmt.Fprintf(w, "%s\terror: %s\n", hc.host, hc.err); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s\texpires %s\tissuer %s\tchain %d\t%s\n",
+			hc.host, hc.expires.Format("2006-01-02"), hc.issuer, hc.chain, strings.Join(hc.names, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sort"
+)
+
+const (
+	checkpointName = "checkpoint.json"
+	// exitInterrupted is the exit code of a crawl stopped by a signal.
+	exitInterrupted = 130
+)
+
+// checkpoint is the state of an interrupted crawl: its frontier,
+// the depths found and the results so far, enough to resume it
+// without visiting pages twice and to report on all pages at
+// the end.
+type checkpoint struct {
+	Seeds   []string            `json:"seeds"`
+	Visited []string            `json:"visited"`
+	Queue   []string            `json:"queue"`
+	Depths  map[string]int      `json:"depths,omitempty"`
+	Results []*checkpointResult `json:"results,omitempty"`
+}
+
+// checkpointResult is a result as stored in a checkpoint, with
+// its error as text.
+type checkpointResult struct {
+	*result
+	Err string `json:",omitempty"`
+}
+
+func (r *checkpointResult) UnmarshalJSON(data []byte) error {
+	// The embedded result must exist for its fields to be set.
+	type plain checkpointResult
+	r.result = &result{}
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+// checkpoint returns the state of c. It must only be called
+// once the crawler has finished.
+func (c *crawler) checkpoint() *checkpoint {
+	cp := &checkpoint{
+		Seeds:   []string{c.base},
+		Visited: make([]string, 0, len(c.results)),
+		Queue:   make([]string, 0),
+		Depths:  c.depths,
+		Results: make([]*checkpointResult, 0, len(c.results)),
+	}
+	for url, scheduled := range c.urls {
+		if scheduled {
+			cp.Visited = append(cp.Visited, url)
+		} else {
+			cp.Queue = append(cp.Queue, url)
+		}
+	}
+	sort.Strings(cp.Visited)
+	sort.Strings(cp.Queue)
+	for _, url := range cp.Visited {
+		res, ok := c.results[url]
+		if !ok {
+			continue
+		}
+		r := &checkpointResult{result: res}
+		if res.Err != nil {
+			r.Err = res.Err.Error()
+		}
+		cp.Results = append(cp.Results, r)
+	}
+	return cp
+}
+
+// restore loads the frontier, the depths and the results from cp
+// before the crawl starts.
+func (c *crawler) restore(cp *checkpoint) {
+	for _, url := range cp.Visited {
+		c.urls[url] = true
+	}
+	for _, url := range cp.Queue {
+		c.urls[url] = false
+	}
+	for url, d := range cp.Depths {
+		c.depths[url] = d
+	}
+	for _, r := range cp.Results {
+		if r.Err != "" {
+			r.result.Err = errors.New(r.Err)
+			c.nerrors++
+		}
+		c.results[r.URL] = r.result
+	}
+}
+
+func loadCheckpoint(name string) (*checkpoint, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	if len(cp.Seeds) == 0 {
+		return nil, errors.New("checkpoint has no seeds")
+	}
+	return &cp, nil
+}
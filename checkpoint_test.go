@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCheckpointRestore(t *testing.T) {
+	c, err := newCrawler(context.Background(), []string{"http://example.test/"}, 1, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.urls = map[string]bool{
+		"http://example.test/":  true,
+		"http://example.test/a": true,
+		"http://example.test/b": false,
+	}
+	c.depths = map[string]int{"http://example.test/a": 1, "http://example.test/b": 2}
+	c.results = map[string]*result{
+		"http://example.test/": {URL: "http://example.test/", Status: 200, Title: "Home",
+			Links: []string{"http://example.test/a"}, Findings: []finding{{Kind: "missing-h1", URL: "http://example.test/"}}},
+		"http://example.test/a": {URL: "http://example.test/a", Depth: 1, Err: errors.New("timeout")},
+	}
+	data, err := json.Marshal(c.checkpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		t.Fatal(err)
+	}
+	r, err := newCrawler(context.Background(), cp.Seeds, 1, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.restore(&cp)
+	if !reflect.DeepEqual(r.urls, c.urls) {
+		t.Errorf("got URLs %v, want %v", r.urls, c.urls)
+	}
+	if !reflect.DeepEqual(r.depths, c.depths) {
+		t.Errorf("got depths %v, want %v", r.depths, c.depths)
+	}
+	if len(r.results) != 2 {
+		t.Fatalf("got %d results, want 2", len(r.results))
+	}
+	home := r.results["http://example.test/"]
+	if !reflect.DeepEqual(home, c.results["http://example.test/"]) {
+		t.Errorf("got %+v, want %+v", home, c.results["http://example.test/"])
+	}
+	if res := r.results["http://example.test/a"]; res.Err == nil || res.Err.Error() != "timeout" || res.Depth != 1 {
+		t.Errorf("got error %v at depth %d, want timeout at 1", res.Err, res.Depth)
+	}
+	if r.nerrors != 1 {
+		t.Errorf("got %d errors, want 1", r.nerrors)
+	}
+}
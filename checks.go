@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxTitleLength       = 60
+	maxDescriptionLength = 160
+	// minTitleH1Overlap is the least share of the words of the
+	// shorter of title and h1 that the other must also have.
+	minTitleH1Overlap = 0.3
+)
+
+// checkPages adds the on-page findings of all pages to rep.
+func checkPages(rep *report) {
+	for _, res := range rep.sorted() {
+		for _, f := range pageFindings(res) {
+			rep.add(f.Kind, f.URL, f.Detail)
+		}
+	}
+}
+
+// pageFindings returns the issues found on a single page.
+// Only pages that loaded correctly are checked.
+func pageFindings(res *result) []finding {
+	var fs []finding
+	add := func(kind, detail string) {
+		fs = append(fs, finding{Kind: kind, URL: res.URL, Detail: detail})
+	}
+	if !res.page() {
+		return fs
+	}
+	switch n := len([]rune(res.Title)); {
+	case n == 0:
+		add("missing-title", "")
+	case n > maxTitleLength:
+		add("title-too-long", fmt.Sprintf("%d characters", n))
+	}
+	switch n := len([]rune(res.Description)); {
+	case n == 0:
+		add("missing-description", "")
+	case n > maxDescriptionLength:
+		add("description-too-long", fmt.Sprintf("%d characters", n))
+	}
+	if n := len(res.Titles); n > 1 {
+		add("multiple-titles", fmt.Sprintf("%d title elements: %s", n, quoteAll(res.Titles)))
+	}
+	var h1 *heading
+	var h1s []string
+	for i, h := range res.Headings {
+		if h.Level != 1 {
+			continue
+		}
+		if h1 == nil {
+			h1 = &res.Headings[i]
+		}
+		h1s = append(h1s, h.Text)
+	}
+	if n := len(h1s); n > 1 {
+		add("multiple-h1", fmt.Sprintf("%d h1 elements: %s", n, quoteAll(h1s)))
+	}
+	switch {
+	case h1 == nil:
+		add("missing-h1", "")
+	case res.Title != "" && wordOverlap(res.Title, h1.Text) < minTitleH1Overlap:
+		add("title-h1-mismatch", fmt.Sprintf("title %q, h1 %q", res.Title, h1.Text))
+	}
+	if res.noindex() {
+		add("noindex", strings.Trim(res.Robots+", "+res.XRobotsTag, ", "))
+	}
+	if res.Canonical != "" && strings.TrimSuffix(res.Canonical, "/") != strings.TrimSuffix(res.URL, "/") {
+		add("canonical-elsewhere", res.Canonical)
+	}
+	return fs
+}
+
+// quoteAll quotes each of ss, separated by commas.
+func quoteAll(ss []string) string {
+	qs := make([]string, len(ss))
+	for i, s := range ss {
+		qs[i] = fmt.Sprintf("%q", s)
+	}
+	return strings.Join(qs, ", ")
+}
+
+// words returns the set of lowercase words of s. Scripts written
+// without spaces between words count each character as a word.
+func words(s string) map[string]bool {
+	set := make(map[string]bool)
+	var w strings.Builder
+	flush := func() {
+		if w.Len() > 0 {
+			set[w.String()] = true
+			w.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unspaced(r):
+			flush()
+			set[string(r)] = true
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			w.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return set
+}
+
+// unspaced returns true if r is of a script that does not put
+// spaces between words.
+func unspaced(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana,
+		unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar)
+}
+
+// wordOverlap returns the share of the words of the shorter of
+// a and b that are also in the other, from 0 to 1.
+func wordOverlap(a, b string) float64 {
+	wa, wb := words(a), words(b)
+	if len(wa) > len(wb) {
+		wa, wb = wb, wa
+	}
+	if len(wa) == 0 {
+		return 0
+	}
+	n := 0
+	for w := range wa {
+		if wb[w] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(wa))
+}
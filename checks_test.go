@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestWordOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"Blue shoes for sale", "Blue shoes", 1},
+		{"Blue shoes | Shop", "Red hats", 0},
+		{"About us", "About the company", 0.5},
+		{"", "Home", 0},
+		{"東京の天気予報", "東京の天気", 1},
+		{"東京の天気", "大阪の天気", 0.6},
+		{"ข่าววันนี้", "ข่าว", 1},
+		{"Café 2024", "café", 1},
+	}
+	for _, tt := range tests {
+		if got := wordOverlap(tt.a, tt.b); got != tt.want {
+			t.Errorf("wordOverlap(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
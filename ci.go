@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// exitCIFailed is the exit code when CI thresholds are exceeded.
+const exitCIFailed = 3
+
+// isNoindex returns true if a robots directive forbids indexing.
+func isNoindex(directives string) bool {
+	for _, d := range strings.Split(strings.ToLower(directives), ",") {
+		// Directives can be scoped to a user agent: "googlebot: noindex".
+		if i := strings.LastIndex(d, ":"); i >= 0 {
+			d = d[i+1:]
+		}
+		switch strings.TrimSpace(d) {
+		case "noindex", "none":
+			return true
+		}
+	}
+	return false
+}
+
+// noindex returns true if the page must not be indexed.
+func (res *result) noindex() bool {
+	return isNoindex(res.Robots) || isNoindex(res.XRobotsTag)
+}
+
+// broken returns true if the page could not be loaded.
+func (res *result) broken() bool {
+	return res.Err != nil || res.Status >= 400
+}
+
+// checkLinks adds a finding for each link to a broken page.
+func checkLinks(rep *report) {
+	for _, res := range rep.sorted() {
+		for _, link := range res.Links {
+			target, ok := rep.results[link]
+			if !ok || !target.broken() {
+				continue
+			}
+			detail := link
+			if target.Err != nil {
+				detail += " (fetch error)"
+			} else {
+				detail += fmt.Sprintf(" (status %d)", target.Status)
+			}
+			rep.add("broken-link", res.URL, detail)
+		}
+	}
+}
+
+// ciThresholds decide whether a crawl passes in CI mode.
+type ciThresholds struct {
+	max5xx    int
+	maxBroken int
+	noindex   []*regexp.Regexp
+	// regressions makes any regression since the last crawl fail.
+	regressions bool
+}
+
+func newCIThresholds(max5xx, maxBroken int, noindex []string) (*ciThresholds, error) {
+	res, err := compileAll(noindex)
+	if err != nil {
+		return nil, err
+	}
+	return &ciThresholds{max5xx: max5xx, maxBroken: maxBroken, noindex: res}, nil
+}
+
+// check writes a summary of the thresholds to w and returns
+// false if any of them was exceeded.
+func (t *ciThresholds) check(rep *report, w io.Writer) bool {
+	var n5xx, broken int
+	noindex := make([]string, 0)
+	for _, res := range rep.sorted() {
+		if res.Status >= 500 {
+			n5xx++
+		}
+		if res.noindex() {
+			for _, re := range t.noindex {
+				if re.MatchString(res.URL) {
+					noindex = append(noindex, res.URL)
+					break
+				}
+			}
+		}
+	}
+	for _, f := range rep.findings {
+		if f.Kind == "broken-link" {
+			broken++
+		}
+	}
+	pass := n5xx <= t.max5xx && broken <= t.maxBroken && len(noindex) == 0
+	if t.regressions && len(rep.regressions) > 0 {
+		pass = false
+	}
+	verdict := "PASS"
+	if !pass {
+		verdict = "FAIL"
+	}
+	fmt.Fprintf(w, "ci: %s\n", verdict)
+	fmt.Fprintf(w, "  pages with 5xx status: %d (max %d)\n", n5xx, t.max5xx)
+	fmt.Fprintf(w, "  links to broken pages: %d (max %d)\n", broken, t.maxBroken)
+	fmt.Fprintf(w, "  noindex key pages: %d (max 0)\n", len(noindex))
+	for _, url := range noindex {
+		fmt.Fprintf(w, "    %s\n", url)
+	}
+	if t.regressions {
+		fmt.Fprintf(w, "  regressions since the previous crawl: %d (max 0)\n", len(rep.regressions))
+	}
+	return pass
+}
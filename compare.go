@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	nurl "net/url"
+	"os"
+	"sort"
+)
+
+// hostPath is the part of url that is compared across hosts:
+// path and query, with the root always being "/".
+func hostPath(url string) string {
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return url
+	}
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+	return p
+}
+
+// mapURL returns url relative to host if it is on host,
+// so that URLs of two hosts can be compared.
+func mapURL(url, host string) string {
+	u, err := nurl.Parse(url)
+	if err != nil || u.Host != host {
+		return url
+	}
+	return hostPath(url)
+}
+
+// byPath indexes the results of a crawl by hostPath.
+func byPath(results map[string]*result) map[string]*result {
+	m := make(map[string]*result)
+	for url, res := range results {
+		m[hostPath(url)] = res
+	}
+	return m
+}
+
+// linkSet returns the links of res mapped to paths.
+func linkSet(res *result) map[string]bool {
+	set := make(map[string]bool)
+	for _, l := range res.Links {
+		set[hostPath(l)] = true
+	}
+	return set
+}
+
+func statusString(res *result) string {
+	if res.Err != nil {
+		return "error"
+	}
+	return fmt.Sprintf("%d", res.Status)
+}
+
+// compareCrawls writes the differences between the crawls of
+// two hosts and returns how many were found.
+func compareCrawls(w io.Writer, a, b *crawler) int {
+	pa, pb := byPath(a.results), byPath(b.results)
+	paths := make([]string, 0, len(pa)+len(pb))
+	for p := range pa {
+		paths = append(paths, p)
+	}
+	for p := range pb {
+		if _, ok := pa[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	var ndiff int
+	diff := func(path, what, format string, args ...interface{}) {
+		ndiff++
+		fmt.Fprintf(w, "%s\t%s\t%s\n", path, what, fmt.Sprintf(format, args...))
+	}
+	for _, p := range paths {
+		ra, oka := pa[p]
+		rb, okb := pb[p]
+		switch {
+		case !oka:
+			diff(p, "missing", "only on %s", b.baseurl.Host)
+			continue
+		case !okb:
+			diff(p, "missing", "only on %s", a.baseurl.Host)
+			continue
+		}
+		if sa, sb := statusString(ra), statusString(rb); sa != sb {
+			diff(p, "status", "%s -> %s", sa, sb)
+		}
+		if ra.Title != rb.Title {
+			diff(p, "title", "%q -> %q", ra.Title, rb.Title)
+		}
+		ca, cb := mapURL(ra.Canonical, a.baseurl.Host), mapURL(rb.Canonical, b.baseurl.Host)
+		if ca != cb {
+			diff(p, "canonical", "%q -> %q", ca, cb)
+		}
+		la, lb := linkSet(ra), linkSet(rb)
+		var added, removed []string
+		for l := range lb {
+			if !la[l] {
+				added = append(added, l)
+			}
+		}
+		for l := range la {
+			if !lb[l] {
+				removed = append(removed, l)
+			}
+		}
+		sort.Strings(added)
+		sort.Strings(removed)
+		for _, l := range removed {
+			diff(p, "link", "-%s", l)
+		}
+		for _, l := range added {
+			diff(p, "link", "+%s", l)
+		}
+	}
+	return ndiff
+}
+
+// compareCmd implements the "compare" subcommand: crawl two
+// hosts with the same settings and print what differs. Like
+// diff(1), it exits with status 1 if there are differences.
+func compareCmd(opts *options, args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: seopeo [flags] compare URL-A URL-B\n")
+		return 2
+	}
+	filter, err := newURLFilter(opts.include, opts.exclude)
+	if err != nil {
+		slog.Error("invalid URL filter", "err", err)
+		return 2
+	}
+	crawlers := make([]*crawler, len(args))
+	for i, seed := range args {
+		// Each crawl has its own fetcher, so that byte budgets
+		// and rate limits apply to each.
+		fetch, err := optionsFetcher(opts)
+		if err != nil {
+			slog.Error("cannot start fetcher", "err", err)
+			return 2
+		}
+		c, err := newCrawler(context.Background(), []string{seed}, opts.workers, fetch, filter)
+		if err != nil {
+			slog.Error("cannot crawl", "url", seed, "err", err)
+			return 2
+		}
+		if err := c.configure(opts); err != nil {
+			slog.Error("cannot crawl", "url", seed, "err", err)
+			return 2
+		}
+		crawlers[i] = c
+		c.start()
+	}
+	for _, c := range crawlers {
+		c.wait()
+	}
+	if compareCrawls(os.Stdout, crawlers[0], crawlers[1]) > 0 {
+		return 1
+	}
+	return 0
+}
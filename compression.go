@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"mime"
+	"net/http"
+	nurl "net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// minCompressSize is the smallest body worth compressing.
+	minCompressSize = 1024
+	// maxCompressRatio is the largest share of its size that a
+	// compressed text body should still take.
+	maxCompressRatio = 0.9
+)
+
+// gunzip decompresses a gzip encoded body.
+func gunzip(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return ioutil.ReadAll(zr)
+}
+
+// compressible returns the media type of ctype if it is text
+// that should be sent compressed, or an empty string.
+func compressible(ctype string) string {
+	mt, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(mt, "text/"),
+		mt == "application/javascript", mt == "application/json",
+		mt == "application/xml", mt == "image/svg+xml",
+		strings.HasSuffix(mt, "+json"), strings.HasSuffix(mt, "+xml"):
+		return mt
+	}
+	return ""
+}
+
+// transfer is a response as checked for compression.
+type transfer struct {
+	url      string
+	ctype    string
+	size     int
+	wire     int
+	encoding string
+}
+
+// siteResources fetches the stylesheets and scripts of the site
+// used by the crawled pages.
+func siteResources(ctx context.Context, fetch *fetcher, rep *report) []transfer {
+	base, err := nurl.Parse(rep.base)
+	if err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var urls []string
+	for _, res := range rep.sorted() {
+		for _, r := range res.Resources {
+			u, err := nurl.Parse(r.URL)
+			if err != nil || seen[r.URL] || (u.Host != base.Host && u.Host != aliasHost(base.Host)) {
+				continue
+			}
+			seen[r.URL] = true
+			urls = append(urls, r.URL)
+		}
+	}
+	sort.Strings(urls)
+	var ts []transfer
+	for _, url := range urls {
+		resp, err := fetch.get(ctx, url)
+		if err != nil {
+			slog.Warn("cannot fetch resource", "url", url, "err", err)
+			continue
+		}
+		if resp.status != http.StatusOK {
+			continue
+		}
+		ts = append(ts, transfer{
+			url:      url,
+			ctype:    resp.header.Get("Content-Type"),
+			size:     len(resp.body),
+			wire:     resp.wire,
+			encoding: resp.encoding,
+		})
+	}
+	return ts
+}
+
+// compressionGroup is the responses of a type in a directory
+// with the same compression problem.
+type compressionGroup struct {
+	kind, ctype, dir string
+	urls             []string
+	bytes            int
+}
+
+// checkCompression adds an "uncompressed-response" finding for
+// each content type and directory with text responses sent
+// without compression, and a "poorly-compressed-response" one
+// where compression saves less than a tenth. Stylesheets and
+// scripts of the site are fetched to be checked as well.
+func checkCompression(ctx context.Context, fetch *fetcher, rep *report) {
+	var ts []transfer
+	for _, res := range rep.sorted() {
+		if !res.loaded() || res.WireSize == 0 {
+			continue
+		}
+		ts = append(ts, transfer{
+			url:      res.URL,
+			ctype:    res.ContentType,
+			size:     res.Size,
+			wire:     res.WireSize,
+			encoding: res.Encoding,
+		})
+	}
+	ts = append(ts, siteResources(ctx, fetch, rep)...)
+	groups := make(map[string]*compressionGroup)
+	var keys []string
+	for _, t := range ts {
+		mt := compressible(t.ctype)
+		if mt == "" || t.size < minCompressSize {
+			continue
+		}
+		var kind string
+		switch {
+		case t.encoding == "" || t.encoding == "identity":
+			kind = "uncompressed-response"
+		case float64(t.wire) > maxCompressRatio*float64(t.size):
+			kind = "poorly-compressed-response"
+		default:
+			continue
+		}
+		dir := directory(t.url)
+		key := kind + " " + mt + " " + dir
+		g, ok := groups[key]
+		if !ok {
+			g = &compressionGroup{kind: kind, ctype: mt, dir: dir}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.urls = append(g.urls, t.url)
+		g.bytes += t.wire
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		g := groups[k]
+		rep.add(g.kind, g.urls[0], fmt.Sprintf("%s under %s: %d responses, %d bytes sent", g.ctype, g.dir, len(g.urls), g.bytes))
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configEntry is one value read from a configuration file.
+// Keys of nested sections are joined with a dash, so that
+// user under an "auth:" section, or [auth] in TOML, sets the
+// flag -auth-user.
+type configEntry struct {
+	key   string
+	value string
+	line  int
+}
+
+// loadConfig reads the file name and sets on fs all flags
+// that were not explicitly set on the command line.
+func loadConfig(name string, fs *flag.FlagSet) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	var entries []configEntry
+	switch filepath.Ext(name) {
+	case ".toml":
+		entries, err = parseTOML(string(data))
+	default:
+		entries, err = parseYAML(string(data))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, e := range entries {
+		if fs.Lookup(e.key) == nil || e.key == "config" {
+			return fmt.Errorf("%s:%d: unknown option %s", name, e.line, e.key)
+		}
+		if set[e.key] {
+			continue
+		}
+		if err := fs.Set(e.key, e.value); err != nil {
+			return fmt.Errorf("%s:%d: %s: %s", name, e.line, e.key, err)
+		}
+	}
+	return nil
+}
+
+// configCmd implements the "config" subcommand.
+func configCmd(args []string) int {
+	if len(args) != 2 || args[0] != "validate" {
+		fmt.Fprintf(os.Stderr, "usage: seopeo config validate FILE\n")
+		return 2
+	}
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	opts := newOptions(fs)
+	if err := loadConfig(args[1], fs); err != nil {
+		slog.Error("cannot load config", "file", args[1], "err", err)
+		return 1
+	}
+	if err := opts.validate(); err != nil {
+		slog.Error("invalid config", "file", args[1], "err", err)
+		return 1
+	}
+	fmt.Printf("%s: ok\n", args[1])
+	return 0
+}
+
+// stripComment removes a trailing "# comment" outside of quotes.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquote returns the value of a scalar, removing quotes.
+func unquote(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 {
+		switch {
+		case v[0] == '"' && v[len(v)-1] == '"':
+			return strconv.Unquote(v)
+		case v[0] == '\'' && v[len(v)-1] == '\'':
+			return strings.Replace(v[1:len(v)-1], "''", "'", -1), nil
+		}
+	}
+	return v, nil
+}
+
+// splitList splits the inside of an inline "[a, b]" list at the
+// commas outside of quotes.
+func splitList(v string) ([]string, error) {
+	v = strings.TrimSpace(v[1 : len(v)-1])
+	if v == "" {
+		return nil, nil
+	}
+	var (
+		items []string
+		quote byte
+		start int
+	)
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, v[start:i])
+			start = i + 1
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %s", v)
+	}
+	items = append(items, v[start:])
+	var vals []string
+	for _, item := range items {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		s, err := unquote(item)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, s)
+	}
+	return vals, nil
+}
+
+// appendValue adds the entries for key, expanding inline lists.
+func appendValue(entries []configEntry, key, v string, line int) ([]configEntry, error) {
+	v = strings.TrimSpace(v)
+	if strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") {
+		vals, err := splitList(v)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		for _, s := range vals {
+			entries = append(entries, configEntry{key: key, value: s, line: line})
+		}
+		return entries, nil
+	}
+	s, err := unquote(v)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %s", line, err)
+	}
+	return append(entries, configEntry{key: key, value: s, line: line}), nil
+}
+
+// isMapping returns true if the list item s is a "key: value"
+// mapping rather than a scalar, which may be quoted.
+func isMapping(s string) bool {
+	if strings.HasPrefix(s, "\"") || strings.HasPrefix(s, "'") {
+		return false
+	}
+	return strings.Contains(s, ": ") || strings.HasSuffix(s, ":")
+}
+
+type yamlFrame struct {
+	indent int
+	key    string
+}
+
+// parseYAML understands the subset of YAML needed for options:
+// nested mappings, lists of scalars and inline lists.
+func parseYAML(data string) ([]configEntry, error) {
+	var (
+		entries []configEntry
+		stack   []yamlFrame
+		err     error
+	)
+	for n, line := range strings.Split(data, "\n") {
+		n++
+		line = strings.TrimRight(stripComment(line), " \t\r")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", n)
+		}
+		indent := len(line) - len(trimmed)
+		if trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+			for len(stack) > 0 && stack[len(stack)-1].indent > indent {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: list item without a key", n)
+			}
+			item := strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+			if isMapping(item) {
+				return nil, fmt.Errorf("line %d: lists of mappings are not supported", n)
+			}
+			if entries, err = appendValue(entries, yamlKey(stack), item, n); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		i := strings.Index(trimmed, ":")
+		if i <= 0 || (i+1 < len(trimmed) && trimmed[i+1] != ' ') {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", n)
+		}
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		key, val := strings.TrimSpace(trimmed[:i]), strings.TrimSpace(trimmed[i+1:])
+		if val == "" {
+			stack = append(stack, yamlFrame{indent: indent, key: key})
+			continue
+		}
+		k := key
+		if len(stack) > 0 {
+			k = yamlKey(stack) + "-" + key
+		}
+		if entries, err = appendValue(entries, k, val, n); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func yamlKey(stack []yamlFrame) string {
+	keys := make([]string, len(stack))
+	for i, f := range stack {
+		keys[i] = f.key
+	}
+	return strings.Join(keys, "-")
+}
+
+// parseTOML understands the subset of TOML needed for options:
+// tables, dotted keys, scalars and single-line arrays.
+func parseTOML(data string) ([]configEntry, error) {
+	var (
+		entries []configEntry
+		prefix  string
+		err     error
+	)
+	for n, line := range strings.Split(data, "\n") {
+		n++
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: invalid table header", n)
+			}
+			prefix = strings.Replace(strings.TrimSpace(line[1:len(line)-1]), ".", "-", -1) + "-"
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: expected \"key = value\"", n)
+		}
+		key := strings.Replace(strings.TrimSpace(line[:i]), ".", "-", -1)
+		if entries, err = appendValue(entries, prefix+key, line[i+1:], n); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
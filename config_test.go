@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// keyValues returns the keys and values of entries as "key=value".
+func keyValues(entries []configEntry) []string {
+	var kvs []string
+	for _, e := range entries {
+		kvs = append(kvs, e.key+"="+e.value)
+	}
+	return kvs
+}
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+		err  bool
+	}{
+		{"scalars", "workers: 4\nrate: 2.5\n", []string{"workers=4", "rate=2.5"}, false},
+		{"comments", "# crawl\nworkers: 4 # more\n---\n", []string{"workers=4"}, false},
+		{"quoted", "user: \"a # b\"\npassword: 'it''s'\n", []string{"user=a # b", "password=it's"}, false},
+		{"nested", "auth:\n  user: x\n  password: y\n", []string{"auth-user=x", "auth-password=y"}, false},
+		{"list", "exclude:\n  - /a\n  - '/b'\n", []string{"exclude=/a", "exclude=/b"}, false},
+		{"quoted list item with colon", "header:\n  - \"X-Foo: bar\"\n  - 'Y-Foo: baz'\n", []string{"header=X-Foo: bar", "header=Y-Foo: baz"}, false},
+		{"inline list", "exclude: [/a, \"/b\"]\n", []string{"exclude=/a", "exclude=/b"}, false},
+		{"inline list with quoted comma", "exclude: [\"/a/\\\\d{1,3}\", '/b,c']\n", []string{`exclude=/a/\d{1,3}`, "exclude=/b,c"}, false},
+		{"empty inline list", "exclude: []\n", nil, false},
+		{"back to top level", "auth:\n  user: x\nworkers: 2\n", []string{"auth-user=x", "workers=2"}, false},
+		{"list of mappings", "header:\n  - name: x\n", nil, true},
+		{"list without key", "- x\n", nil, true},
+		{"missing space", "workers:4\n", nil, true},
+		{"tab indentation", "auth:\n\tuser: x\n", nil, true},
+		{"unterminated quote in list", "exclude: [\"/a, /b]\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := parseYAML(tt.data)
+			if (err != nil) != tt.err {
+				t.Fatalf("error %v, want error %v", err, tt.err)
+			}
+			if got := keyValues(entries); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+		err  bool
+	}{
+		{"scalars", "workers = 4\nuser = \"x\"\n", []string{"workers=4", "user=x"}, false},
+		{"comments", "# crawl\nworkers = 4 # more\n", []string{"workers=4"}, false},
+		{"table", "[auth]\nuser = \"x\"\n", []string{"auth-user=x"}, false},
+		{"dotted", "auth.user = 'x'\n", []string{"auth-user=x"}, false},
+		{"nested table", "[a.b]\nc = 1\n", []string{"a-b-c=1"}, false},
+		{"array", "exclude = [\"/a\", '/b']\n", []string{"exclude=/a", "exclude=/b"}, false},
+		{"array with quoted comma", "exclude = [\"/a/\\\\d{1,3}\"]\n", []string{`exclude=/a/\d{1,3}`}, false},
+		{"array with colon", "header = [\"X-Foo: bar\"]\n", []string{"header=X-Foo: bar"}, false},
+		{"array of tables", "[[auth]]\n", nil, true},
+		{"bad table", "[auth\n", nil, true},
+		{"no value", "workers\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := parseTOML(tt.data)
+			if (err != nil) != tt.err {
+				t.Fatalf("error %v, want error %v", err, tt.err)
+			}
+			if got := keyValues(entries); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// minContentWords is the length from which the main content
+	// of two pages being equal makes them duplicates.
+	minContentWords = 50
+	// maxLinkDensity is the largest share of the words of a block
+	// that can be link text before it counts as navigation.
+	maxLinkDensity = 0.5
+)
+
+// boilerplateTags are elements around navigation and other text
+// repeated on all pages of a site.
+var boilerplateTags = map[string]bool{
+	"nav": true, "aside": true, "form": true, "menu": true, "dialog": true,
+}
+
+// boilerplateRoles are the ARIA roles of boilerplate regions.
+var boilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true, "search": true,
+}
+
+// boilerplateNames match the classes and ids of boilerplate regions.
+var boilerplateNames = regexp.MustCompile(`(?i)\b(nav|navbar|menu|sidebar|breadcrumbs?|cookies?|consent|banner|comments?|share|sharing|social|related|widget|promo|newsletter|ads?|advert)\b`)
+
+// blockTags are elements that start a new block of text.
+var blockTags = map[string]bool{
+	"p": true, "div": true, "li": true, "ul": true, "ol": true, "td": true, "th": true,
+	"tr": true, "table": true, "dd": true, "dt": true, "dl": true, "blockquote": true,
+	"pre": true, "section": true, "br": true, "hr": true, "figure": true, "figcaption": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"main": true, "article": true, "header": true, "footer": true, "body": true,
+}
+
+// contentFrame is an open element for mainContent.
+type contentFrame struct {
+	name        string
+	boilerplate bool
+	main        bool
+}
+
+// mainContent extracts the main content of a page, leaving out
+// navigation, headers, footers, sidebars and blocks that are
+// mostly links. When the page marks its content with main or
+// article elements, only their text counts.
+type mainContent struct {
+	open      []contentFrame
+	skip      int // open boilerplate elements
+	inMain    int // open main elements
+	inLink    int
+	block     strings.Builder
+	links     int // words of link text in block
+	main      strings.Builder
+	rest      strings.Builder
+	foundMain bool
+}
+
+// isBoilerplate returns true if the element of t, inside the main
+// content or not, is boilerplate.
+func isBoilerplate(t *html.Token, inMain bool) bool {
+	if boilerplateTags[t.Data] {
+		return true
+	}
+	// Headers and footers of articles are part of them.
+	if (t.Data == "header" || t.Data == "footer") && !inMain {
+		return true
+	}
+	if role, _ := attr(t, "role"); boilerplateRoles[strings.ToLower(role)] {
+		return true
+	}
+	// Sites put all kinds of classes on the whole document.
+	if t.Data == "html" || t.Data == "body" || isMain(t) {
+		return false
+	}
+	id, _ := attr(t, "id")
+	class, _ := attr(t, "class")
+	return boilerplateNames.MatchString(id + " " + class)
+}
+
+// isMain returns true if t starts the main content of the page.
+func isMain(t *html.Token) bool {
+	if t.Data == "main" || t.Data == "article" {
+		return true
+	}
+	role, _ := attr(t, "role")
+	return strings.EqualFold(role, "main")
+}
+
+// flush ends the current block, keeping its text unless it is
+// boilerplate or mostly links.
+func (c *mainContent) flush() {
+	text := c.block.String()
+	links := c.links
+	c.block.Reset()
+	c.links = 0
+	words := len(strings.Fields(text))
+	if words == 0 || float64(links) > maxLinkDensity*float64(words) {
+		return
+	}
+	b := &c.rest
+	if c.inMain > 0 {
+		b = &c.main
+	}
+	b.WriteString(strings.Join(strings.Fields(text), " "))
+	b.WriteString("\n")
+}
+
+func (c *mainContent) token(t *html.Token) {
+	switch t.Type {
+	case html.StartTagToken, html.SelfClosingTagToken:
+		if t.Data == "a" && t.Type == html.StartTagToken {
+			c.inLink++
+			return
+		}
+		if blockTags[t.Data] || t.Type == html.StartTagToken && (boilerplateTags[t.Data] || isMain(t)) {
+			c.flush()
+		}
+		if t.Type == html.SelfClosingTagToken || voidElements[t.Data] {
+			return
+		}
+		f := contentFrame{name: t.Data, boilerplate: isBoilerplate(t, c.inMain > 0), main: isMain(t)}
+		if f.boilerplate {
+			c.skip++
+		}
+		if f.main {
+			c.inMain++
+			c.foundMain = true
+		}
+		c.open = append(c.open, f)
+	case html.EndTagToken:
+		if t.Data == "a" {
+			if c.inLink > 0 {
+				c.inLink--
+			}
+			return
+		}
+		i := len(c.open) - 1
+		for i >= 0 && c.open[i].name != t.Data {
+			i--
+		}
+		if i < 0 {
+			return
+		}
+		c.flush()
+		for _, f := range c.open[i:] {
+			if f.boilerplate {
+				c.skip--
+			}
+			if f.main {
+				c.inMain--
+			}
+		}
+		c.open = c.open[:i]
+	case html.TextToken:
+		if c.skip > 0 {
+			return
+		}
+		c.block.WriteString(t.Data)
+		c.block.WriteString(" ")
+		if c.inLink > 0 {
+			c.links += len(strings.Fields(t.Data))
+		}
+	}
+}
+
+// String returns the main content, or all the text that is not
+// boilerplate if the page does not mark its main content.
+func (c *mainContent) String() string {
+	c.flush()
+	if c.foundMain {
+		return c.main.String()
+	}
+	return c.rest.String()
+}
+
+// mainContentHash returns the hash of the main content text, or an
+// empty string if it is too short to compare.
+func mainContentHash(text string) string {
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) < minContentWords {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(words, " "))))
+}
+
+// checkDuplicateContent adds a "duplicate-content" finding for each
+// page with the same main content as another that does not declare
+// the same canonical, naming the page with the shortest URL.
+func checkDuplicateContent(rep *report) {
+	byHash := make(map[string][]*result)
+	for _, res := range rep.sorted() {
+		if res.page() && res.ContentHash != "" {
+			byHash[res.ContentHash] = append(byHash[res.ContentHash], res)
+		}
+	}
+	for _, pages := range byHash {
+		sort.Slice(pages, func(i, j int) bool {
+			if len(pages[i].URL) != len(pages[j].URL) {
+				return len(pages[i].URL) < len(pages[j].URL)
+			}
+			return pages[i].URL < pages[j].URL
+		})
+	}
+	for _, res := range rep.sorted() {
+		pages := byHash[res.ContentHash]
+		if len(pages) < 2 || res.ContentHash == "" {
+			continue
+		}
+		first := pages[0]
+		if res == first || rep.canonicalRoot(res) == rep.canonicalRoot(first) {
+			continue
+		}
+		rep.add("duplicate-content", res.URL, "same main content as "+first.URL)
+	}
+}
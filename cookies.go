@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxCookieSize is the largest cookie, name and value, that all
+// browsers store.
+const maxCookieSize = 4096
+
+// seenCookie is a cookie set by the site, the first time it was
+// set for its domain and path.
+type seenCookie struct {
+	name, domain, path string
+	size               int
+	secure, httpOnly   bool
+	sameSite           string
+	https              bool   // set over https
+	url                string // where it was first set
+	count              int    // how many responses set it
+}
+
+// flags describes the attributes of c.
+func (c *seenCookie) flags() string {
+	var fs []string
+	if c.secure {
+		fs = append(fs, "Secure")
+	}
+	if c.httpOnly {
+		fs = append(fs, "HttpOnly")
+	}
+	if c.sameSite != "" {
+		fs = append(fs, "SameSite="+c.sameSite)
+	}
+	if len(fs) == 0 {
+		return "-"
+	}
+	return strings.Join(fs, " ")
+}
+
+// cookieLog records the cookies set by the responses to the
+// crawler, redirects included.
+type cookieLog struct {
+	mux     sync.Mutex
+	cookies map[string]*seenCookie
+}
+
+func newCookieLog() *cookieLog {
+	return &cookieLog{cookies: make(map[string]*seenCookie)}
+}
+
+// sameSite returns the SameSite attribute of the raw Set-Cookie
+// header, which net/http does not tell apart from a missing one
+// when it is empty or unknown.
+func sameSite(raw string) string {
+	for _, attr := range strings.Split(raw, ";")[1:] {
+		kv := strings.SplitN(strings.TrimSpace(attr), "=", 2)
+		if len(kv) == 2 && strings.EqualFold(kv[0], "samesite") {
+			return strings.TrimSpace(kv[1])
+		}
+	}
+	return ""
+}
+
+// record adds the cookies set by resp.
+func (l *cookieLog) record(resp *http.Response) {
+	cookies := resp.Cookies()
+	if len(cookies) == 0 {
+		return
+	}
+	u := resp.Request.URL
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	for _, c := range cookies {
+		domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+		if domain == "" {
+			domain = u.Hostname()
+		}
+		p := c.Path
+		if p == "" || !strings.HasPrefix(p, "/") {
+			// The default path of a cookie is the directory of the URL.
+			if p = path.Dir(u.Path); p == "." {
+				p = "/"
+			}
+		}
+		key := domain + p + "\x00" + c.Name
+		if sc, ok := l.cookies[key]; ok {
+			sc.count++
+			continue
+		}
+		l.cookies[key] = &seenCookie{
+			name:     c.Name,
+			domain:   domain,
+			path:     p,
+			size:     len(c.Name) + len(c.Value),
+			secure:   c.Secure,
+			httpOnly: c.HttpOnly,
+			sameSite: sameSite(c.Raw),
+			https:    u.Scheme == "https",
+			url:      u.String(),
+			count:    1,
+		}
+	}
+}
+
+// middleware returns a middleware recording the cookies of all
+// responses in l.
+func (l *cookieLog) middleware() middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err == nil {
+				l.record(resp)
+			}
+			return resp, err
+		})
+	}
+}
+
+// list returns the recorded cookies by domain, path and name.
+func (l *cookieLog) list() []*seenCookie {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	keys := make([]string, 0, len(l.cookies))
+	for k := range l.cookies {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	cs := make([]*seenCookie, len(keys))
+	for i, k := range keys {
+		cs[i] = l.cookies[k]
+	}
+	return cs
+}
+
+// checkCookies adds a finding for each cookie of the crawl that
+// is too large ("cookie-oversized") or, set over https, lacks
+// the Secure flag ("cookie-not-secure"); and for each one that
+// lacks HttpOnly ("cookie-not-httponly") or SameSite
+// ("cookie-no-samesite").
+func checkCookies(rep *report) {
+	for _, c := range rep.cookies {
+		what := fmt.Sprintf("%s on %s%s", c.name, c.domain, c.path)
+		if c.size > maxCookieSize {
+			rep.add("cookie-oversized", c.url, fmt.Sprintf("%s: %d bytes", what, c.size))
+		}
+		if c.https && !c.secure {
+			rep.add("cookie-not-secure", c.url, what)
+		}
+		if !c.httpOnly {
+			rep.add("cookie-not-httponly", c.url, what)
+		}
+		if c.sameSite == "" {
+			rep.add("cookie-no-samesite", c.url, what)
+		}
+	}
+}
+
+// writeCookies prints the cookies set during the crawl: domain,
+// path, name, size, flags, how many responses set it and where
+// it was first set.
+func (r *report) writeCookies(w io.Writer) error {
+	for _, c := range r.cookies {
+		_, err := fmt.Fprintf(w, "%s\t%s\t%s\t%d bytes\t%s\t%d responses\t%s\n",
+			c.domain, c.path, c.name, c.size, c.flags(), c.count, c.url)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
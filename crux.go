@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	nurl "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	cruxEndpoint = "https://chromeuxreport.googleapis.com/v1/records:queryRecord"
+	// cruxTimeout is how long a query may take.
+	cruxTimeout = 30 * time.Second
+)
+
+// cruxRecord holds the 75th percentile of the Core Web Vitals
+// as reported by real Chrome users.
+type cruxRecord struct {
+	LCP    float64 // milliseconds
+	CLS    float64
+	INP    float64 // milliseconds
+	Origin bool    // data is for the whole origin, not the URL
+}
+
+func (r *cruxRecord) String() string {
+	s := fmt.Sprintf("LCP %.0fms CLS %.2f INP %.0fms", r.LCP, r.CLS, r.INP)
+	if r.Origin {
+		s += " (origin)"
+	}
+	return s
+}
+
+type cruxClient struct {
+	key        string
+	formFactor string
+	client     *http.Client
+}
+
+func newCruxClient(key, formFactor string) *cruxClient {
+	return &cruxClient{
+		key:        key,
+		formFactor: formFactor,
+		client:     &http.Client{Timeout: cruxTimeout},
+	}
+}
+
+type cruxQuery struct {
+	URL        string `json:"url,omitempty"`
+	Origin     string `json:"origin,omitempty"`
+	FormFactor string `json:"formFactor,omitempty"`
+}
+
+type cruxMetric struct {
+	Percentiles struct {
+		P75 json.RawMessage `json:"p75"`
+	} `json:"percentiles"`
+}
+
+type cruxResponse struct {
+	Record struct {
+		Metrics map[string]cruxMetric `json:"metrics"`
+	} `json:"record"`
+}
+
+// p75 returns the 75th percentile of metric name. The API
+// encodes some values (CLS) as strings and others as numbers.
+func (r *cruxResponse) p75(name string) float64 {
+	m, ok := r.Record.Metrics[name]
+	if !ok {
+		return 0
+	}
+	v, _ := strconv.ParseFloat(strings.Trim(string(m.Percentiles.P75), `"`), 64)
+	return v
+}
+
+// query fetches the record for q. A nil record and no error
+// are returned when CrUX has no data for the URL or origin.
+func (cc *cruxClient) query(q cruxQuery) (*cruxRecord, error) {
+	q.FormFactor = cc.formFactor
+	body, err := json.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cc.client.Post(cruxEndpoint+"?key="+nurl.QueryEscape(cc.key), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("cannot query CrUX: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CrUX returned %s", resp.Status)
+	}
+	var cr cruxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cr); err != nil {
+		return nil, fmt.Errorf("cannot decode CrUX response: %s", err)
+	}
+	return &cruxRecord{
+		LCP:    cr.p75("largest_contentful_paint"),
+		CLS:    cr.p75("cumulative_layout_shift"),
+		INP:    cr.p75("interaction_to_next_paint"),
+		Origin: q.Origin != "",
+	}, nil
+}
+
+// annotate attaches CrUX data to each page. URLs without enough
+// traffic to have their own record get the origin's.
+func (cc *cruxClient) annotate(rep *report) error {
+	base, err := nurl.Parse(rep.base)
+	if err != nil {
+		return err
+	}
+	origin, err := cc.query(cruxQuery{Origin: base.Scheme + "://" + base.Host})
+	if err != nil {
+		return err
+	}
+	for _, res := range rep.sorted() {
+		if !res.page() {
+			continue
+		}
+		rec, err := cc.query(cruxQuery{URL: res.URL})
+		if err != nil {
+			slog.Warn("cannot fetch CrUX data", "url", res.URL, "err", err)
+			continue
+		}
+		if rec == nil {
+			rec = origin
+		}
+		res.CrUX = rec
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"strings"
+)
+
+// cspPolicy is a parsed Content-Security-Policy: the sources of
+// each directive.
+type cspPolicy map[string][]string
+
+// parseCSP parses a single policy.
+func parseCSP(s string) cspPolicy {
+	p := make(cspPolicy)
+	for _, d := range strings.Split(s, ";") {
+		fields := strings.Fields(d)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		// Only the first of a repeated directive counts.
+		if _, ok := p[name]; ok {
+			continue
+		}
+		p[name] = fields[1:]
+	}
+	return p
+}
+
+// cspFallbacks are the directives used in turn when one is missing.
+var cspFallbacks = map[string][]string{
+	"script-src": {"script-src", "default-src"},
+	"style-src":  {"style-src", "default-src"},
+	"img-src":    {"img-src", "default-src"},
+	"frame-src":  {"frame-src", "child-src", "default-src"},
+}
+
+// sources returns the sources the policy allows for directive,
+// and false if it does not restrict it.
+func (p cspPolicy) sources(directive string) ([]string, bool) {
+	for _, d := range cspFallbacks[directive] {
+		if srcs, ok := p[d]; ok {
+			return srcs, true
+		}
+	}
+	return nil, false
+}
+
+// hasKeyword returns true if srcs have one of the quoted keywords,
+// or a nonce or hash if nonce is set.
+func hasKeyword(srcs []string, nonce bool, keywords ...string) bool {
+	for _, s := range srcs {
+		s = strings.ToLower(s)
+		if nonce && (strings.HasPrefix(s, "'nonce-") || strings.HasPrefix(s, "'sha")) {
+			return true
+		}
+		for _, k := range keywords {
+			if s == k {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cspAllows returns true if one of srcs matches u, loaded by the
+// page at page.
+func cspAllows(srcs []string, u, page *nurl.URL) bool {
+	for _, s := range srcs {
+		ls := strings.ToLower(s)
+		switch {
+		case ls == "'self'":
+			if u.Scheme == page.Scheme && u.Host == page.Host {
+				return true
+			}
+		case ls == "*":
+			if u.Scheme == "http" || u.Scheme == "https" {
+				return true
+			}
+		case strings.HasPrefix(ls, "'"):
+			// Other keywords, nonces and hashes match no URL.
+		case strings.HasSuffix(ls, ":") && !strings.Contains(ls, "/"):
+			if u.Scheme+":" == ls || (ls == "http:" && u.Scheme == "https") {
+				return true
+			}
+		default:
+			if matchCSPHost(ls, u, page) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchCSPHost returns true if the host source src, like
+// "https://*.example.com:443/path/", matches u.
+func matchCSPHost(src string, u, page *nurl.URL) bool {
+	scheme := ""
+	if i := strings.Index(src, "://"); i >= 0 {
+		scheme, src = src[:i], src[i+3:]
+	}
+	path := ""
+	if i := strings.Index(src, "/"); i >= 0 {
+		src, path = src[:i], src[i:]
+	}
+	host, port := src, ""
+	if i := strings.LastIndex(src, ":"); i >= 0 {
+		host, port = src[:i], src[i+1:]
+	}
+	switch {
+	case scheme == "" && page.Scheme == "https" && u.Scheme != "https":
+		return false
+	case scheme == "" && u.Scheme != "http" && u.Scheme != "https":
+		return false
+	case scheme != "" && scheme != u.Scheme && !(scheme == "http" && u.Scheme == "https"):
+		return false
+	}
+	h := strings.ToLower(u.Hostname())
+	if strings.HasPrefix(host, "*.") {
+		if !strings.HasSuffix(h, host[1:]) {
+			return false
+		}
+	} else if h != host {
+		return false
+	}
+	if port != "*" {
+		up := u.Port()
+		if up == "" {
+			up = defaultPort(u.Scheme)
+		}
+		if port == "" {
+			port = defaultPort(u.Scheme)
+		}
+		if up != port {
+			return false
+		}
+	}
+	if path == "" || path == "/" {
+		return true
+	}
+	if strings.HasSuffix(path, "/") {
+		return strings.HasPrefix(u.Path, path)
+	}
+	return u.Path == path
+}
+
+// defaultPort returns the port used by scheme when none is given.
+func defaultPort(scheme string) string {
+	if scheme == "http" {
+		return "80"
+	}
+	return "443"
+}
+
+// checkCSP adds a "csp-missing" finding for each page without a
+// Content-Security-Policy, "csp-unsafe-inline" and "csp-unsafe-eval"
+// ones for policies allowing them for scripts or styles, and a
+// "csp-violation" one for each resource the page loads that one of
+// its policies forbids. Scripts are not checked against policies
+// using nonces, hashes or 'strict-dynamic': which of them apply
+// is not known.
+func checkCSP(rep *report) {
+	for _, res := range rep.sorted() {
+		if !res.page() {
+			continue
+		}
+		page, err := nurl.Parse(res.URL)
+		if err != nil {
+			continue
+		}
+		var policies []cspPolicy
+		for _, s := range res.CSP {
+			policies = append(policies, parseCSP(s))
+		}
+		for _, m := range res.Metas {
+			if strings.EqualFold(m.Name, "content-security-policy") {
+				policies = append(policies, parseCSP(m.Content))
+			}
+		}
+		if len(policies) == 0 {
+			rep.add("csp-missing", res.URL, "")
+			continue
+		}
+		unsafe := make(map[string]bool)
+		for _, p := range policies {
+			for _, d := range []string{"script-src", "style-src"} {
+				srcs, ok := p.sources(d)
+				if !ok {
+					continue
+				}
+				if hasKeyword(srcs, false, "'unsafe-inline'") && !hasKeyword(srcs, true) && !unsafe["inline "+d] {
+					unsafe["inline "+d] = true
+					rep.add("csp-unsafe-inline", res.URL, d)
+				}
+				if d == "script-src" && hasKeyword(srcs, false, "'unsafe-eval'") && !unsafe["eval"] {
+					unsafe["eval"] = true
+					rep.add("csp-unsafe-eval", res.URL, d)
+				}
+			}
+		}
+		type load struct{ directive, url string }
+		var loads []load
+		for _, r := range res.Resources {
+			d := "script-src"
+			if r.Kind == "css" {
+				d = "style-src"
+			}
+			loads = append(loads, load{d, r.URL})
+		}
+		for _, img := range res.Images {
+			loads = append(loads, load{"img-src", img.URL})
+		}
+		for _, f := range res.Frames {
+			loads = append(loads, load{"frame-src", f})
+		}
+		var violations []string
+		for _, l := range loads {
+			u, err := nurl.Parse(l.url)
+			if err != nil {
+				continue
+			}
+			for _, p := range policies {
+				srcs, ok := p.sources(l.directive)
+				if !ok || (l.directive == "script-src" && hasKeyword(srcs, true, "'strict-dynamic'")) {
+					continue
+				}
+				if !cspAllows(srcs, u, page) {
+					violations = append(violations, fmt.Sprintf("%s %s", l.directive, l.url))
+					break
+				}
+			}
+		}
+		if len(violations) > 0 {
+			rep.add("csp-violation", res.URL, fmt.Sprintf("%d: %s", len(violations), pageList(violations)))
+		}
+	}
+}
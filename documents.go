@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	nurl "net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// documentTypes are the extensions of downloadable documents.
+var documentTypes = map[string]bool{
+	"pdf": true, "doc": true, "docx": true, "xls": true, "xlsx": true,
+	"ppt": true, "pptx": true, "odt": true, "ods": true, "odp": true,
+	"rtf": true, "csv": true, "epub": true, "zip": true, "rar": true,
+	"7z": true, "tar": true, "gz": true, "tgz": true,
+}
+
+// document is a downloadable document linked from the site.
+type document struct {
+	url   string
+	typ   string // extension
+	pages []string
+	// status, size and ctype are known once the document was
+	// fetched or checked, err if that failed.
+	status int
+	size   int64
+	ctype  string
+	err    error
+}
+
+// documentType returns the extension of url if it links to a
+// document, or an empty string.
+func documentType(url string) string {
+	u, err := nurl.Parse(url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), "."))
+	if !documentTypes[ext] {
+		return ""
+	}
+	return ext
+}
+
+// documents returns the documents linked from the crawled pages,
+// in the order first found, with what the crawl learned about
+// those it fetched.
+func (r *report) documents() []*document {
+	var docs []*document
+	byURL := make(map[string]*document)
+	for _, res := range r.sorted() {
+		for _, a := range res.Anchors {
+			typ := documentType(a.URL)
+			if typ == "" {
+				continue
+			}
+			u, _ := nurl.Parse(a.URL)
+			u.Fragment = ""
+			url := u.String()
+			d, ok := byURL[url]
+			if !ok {
+				d = &document{url: url, typ: typ}
+				if t := r.lookup(url); t != nil {
+					d.status, d.size, d.ctype, d.err = t.Status, int64(t.Size), t.ContentType, t.Err
+				}
+				byURL[url] = d
+				docs = append(docs, d)
+			}
+			if n := len(d.pages); n == 0 || d.pages[n-1] != res.URL {
+				d.pages = append(d.pages, res.URL)
+			}
+		}
+	}
+	return docs
+}
+
+// headDocuments sends a HEAD request for each of docs the crawl
+// did not fetch, or a GET if the server does not allow HEAD.
+func headDocuments(ctx context.Context, f *fetcher, docs []*document) {
+	for _, d := range docs {
+		if d.status != 0 || d.err != nil {
+			continue
+		}
+		resp, err := f.head(ctx, d.url)
+		if err == nil && (resp.status == http.StatusMethodNotAllowed || resp.status == http.StatusNotImplemented) {
+			resp, err = f.get(ctx, d.url)
+		}
+		if err != nil {
+			slog.Warn("cannot check document", "url", d.url, "err", err)
+			d.err = err
+			continue
+		}
+		d.status = resp.status
+		d.ctype = resp.header.Get("Content-Type")
+		d.size, _ = strconv.ParseInt(resp.header.Get("Content-Length"), 10, 64)
+		if len(resp.body) > 0 {
+			d.size = int64(len(resp.body))
+		}
+	}
+}
+
+// checkDocuments adds a "document-broken" finding for each of docs
+// that could not be fetched and a "document-not-document" finding
+// for those served as HTML, often an error page.
+func checkDocuments(rep *report, docs []*document) {
+	for _, d := range docs {
+		switch {
+		case d.err != nil:
+			rep.add("document-broken", d.url, fmt.Sprintf("%s, %s", d.err, linkedFrom(d.pages)))
+		case d.status >= 400:
+			rep.add("document-broken", d.url, fmt.Sprintf("status %d, %s", d.status, linkedFrom(d.pages)))
+		case d.status == 0:
+		default:
+			if mt, _, _ := mime.ParseMediaType(d.ctype); mt == "text/html" {
+				rep.add("document-not-document", d.url, fmt.Sprintf("%s served as %s, %s", d.typ, mt, linkedFrom(d.pages)))
+			}
+		}
+	}
+}
+
+// writeDocuments prints the documents by type with status, size
+// and content type when known, and the pages linking to them.
+func writeDocuments(w io.Writer, docs []*document) error {
+	docs = append([]*document(nil), docs...)
+	sort.SliceStable(docs, func(i, j int) bool { return docs[i].typ < docs[j].typ })
+	for _, d := range docs {
+		status, size, ctype := "-", "-", d.ctype
+		switch {
+		case d.err != nil:
+			status = "error"
+		case d.status != 0:
+			status = strconv.Itoa(d.status)
+		}
+		if d.size > 0 {
+			size = fmt.Sprintf("%d bytes", d.size)
+		}
+		if ctype == "" {
+			ctype = "-"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", d.typ, d.url, status, size, ctype, pageList(d.pages)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
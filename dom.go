@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxDOMNodes and maxDOMDepth are where Lighthouse starts
+	// warning about an excessive DOM size.
+	maxDOMNodes = 1500
+	maxDOMDepth = 32
+	// minTextRatio is the least share of the bytes of a page
+	// that should be visible text.
+	minTextRatio = 0.1
+)
+
+// voidElements have no end tag and so no children.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// domStats measure the markup of a page.
+type domStats struct {
+	Nodes int // elements
+	Depth int // deepest nesting of elements
+	Text  int // bytes of visible text
+}
+
+// domAnalyzer counts the elements of the page, how deep they nest
+// and how much of the page is visible text.
+type domAnalyzer struct {
+	stats  domStats
+	depth  int
+	hidden int // open hidden elements
+}
+
+func (a *domAnalyzer) token(t *html.Token) {
+	switch t.Type {
+	case html.StartTagToken, html.SelfClosingTagToken:
+		a.stats.Nodes++
+		if a.depth+1 > a.stats.Depth {
+			a.stats.Depth = a.depth + 1
+		}
+		if t.Type == html.SelfClosingTagToken || voidElements[t.Data] {
+			return
+		}
+		a.depth++
+		if hiddenText[t.Data] {
+			a.hidden++
+		}
+	case html.EndTagToken:
+		if voidElements[t.Data] {
+			return
+		}
+		if a.depth > 0 {
+			a.depth--
+		}
+		if hiddenText[t.Data] && a.hidden > 0 {
+			a.hidden--
+		}
+	case html.TextToken:
+		if a.hidden == 0 {
+			a.stats.Text += len(strings.TrimSpace(t.Data))
+		}
+	}
+}
+
+func (a *domAnalyzer) finish(res *result) {
+	res.DOM = &a.stats
+}
+
+// textRatio returns the share of the body of res that is visible text.
+func (res *result) textRatio() float64 {
+	if res.DOM == nil || res.Size == 0 {
+		return 0
+	}
+	return float64(res.DOM.Text) / float64(res.Size)
+}
+
+// checkDOM adds a "dom-too-large", "dom-too-deep" or "low-text-ratio"
+// finding for each page whose markup is bloated.
+func checkDOM(rep *report) {
+	for _, res := range rep.sorted() {
+		if !res.page() || res.DOM == nil {
+			continue
+		}
+		if n := res.DOM.Nodes; n > maxDOMNodes {
+			rep.add("dom-too-large", res.URL, fmt.Sprintf("%d elements", n))
+		}
+		if n := res.DOM.Depth; n > maxDOMDepth {
+			rep.add("dom-too-deep", res.URL, fmt.Sprintf("%d levels", n))
+		}
+		if r := res.textRatio(); r < minTextRatio {
+			rep.add("low-text-ratio", res.URL, fmt.Sprintf("%.1f%% text, %d of %d bytes", 100*r, res.DOM.Text, res.Size))
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	nurl "net/url"
+	"strings"
+	"time"
+)
+
+const elasticBulkSize = 500
+
+// elasticMapping is used when creating the index, so that URLs
+// and finding kinds can be aggregated on in Kibana.
+const elasticMapping = `{
+  "mappings": {
+    "properties": {
+      "url":        {"type": "keyword"},
+      "schema_version": {"type": "integer"},
+      "host":       {"type": "keyword"},
+      "path":       {"type": "keyword", "fields": {"text": {"type": "text"}}},
+      "crawled_at": {"type": "date"},
+      "outlinks":   {"type": "integer"},
+      "status":     {"type": "integer"},
+      "error":      {"type": "text"},
+      "depth":      {"type": "integer"},
+      "title":      {"type": "text", "fields": {"keyword": {"type": "keyword", "ignore_above": 512}}},
+      "description": {"type": "text"},
+      "lang":       {"type": "keyword"},
+      "canonical":  {"type": "keyword"},
+      "robots":     {"type": "keyword"},
+      "x_robots_tag": {"type": "keyword"},
+      "hash":       {"type": "keyword"},
+      "protocol":   {"type": "keyword"},
+      "remote_addr": {"type": "keyword"},
+      "ip_family":  {"type": "keyword"},
+      "redirects":  {"type": "integer"},
+      "crux": {
+        "properties": {
+          "lcp":    {"type": "float"},
+          "cls":    {"type": "float"},
+          "inp":    {"type": "float"},
+          "origin": {"type": "boolean"}
+        }
+      },
+      "gsc": {
+        "properties": {
+          "clicks":      {"type": "float"},
+          "impressions": {"type": "float"},
+          "coverage":    {"type": "keyword"},
+          "verdict":     {"type": "keyword"}
+        }
+      },
+      "findings": {
+        "type": "nested",
+        "properties": {
+          "kind":   {"type": "keyword"},
+          "detail": {"type": "text"}
+        }
+      },
+      "readability": {
+        "properties": {
+          "words":     {"type": "integer"},
+          "sentences": {"type": "integer"},
+          "ease":      {"type": "float"},
+          "grade":     {"type": "float"}
+        }
+      }
+    }
+  }
+}`
+
+// elasticSink bulk-indexes one document per URL into an
+// Elasticsearch or OpenSearch index. Credentials can be
+// passed as user info in the server URL.
+type elasticSink struct {
+	server  string
+	index   string
+	started time.Time
+	// run tells apart the documents of each run, also when
+	// started is fixed in deterministic mode.
+	run    int64
+	client *http.Client
+	buf    bytes.Buffer
+	n      int
+}
+
+func newElasticSink(server, index string) (*elasticSink, error) {
+	es := &elasticSink{
+		server:  strings.TrimSuffix(server, "/"),
+		index:   index,
+		started: now(),
+		run:     time.Now().UnixNano(),
+		client:  http.DefaultClient,
+	}
+	if err := es.createIndex(); err != nil {
+		return nil, err
+	}
+	return es, nil
+}
+
+func (es *elasticSink) do(method, path, ctype string, body io.Reader) ([]byte, int, error) {
+	req, err := http.NewRequest(method, es.server+path, body)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", ctype)
+	resp, err := es.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot reach Elasticsearch: %s", err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot read from Elasticsearch: %s", err)
+	}
+	return data, resp.StatusCode, nil
+}
+
+// createIndex creates the index with our mapping unless it exists.
+func (es *elasticSink) createIndex() error {
+	data, status, err := es.do("PUT", "/"+nurl.PathEscape(es.index), "application/json", strings.NewReader(elasticMapping))
+	if err != nil {
+		return err
+	}
+	if status == http.StatusOK || bytes.Contains(data, []byte("resource_already_exists_exception")) {
+		return nil
+	}
+	return fmt.Errorf("cannot create index %s: %s", es.index, data)
+}
+
+func (es *elasticSink) put(res *result) error {
+	// Each run adds its own documents, so that pages can be
+	// followed over time. URLs can be longer than the 512 bytes
+	// allowed for an _id.
+	id := fmt.Sprintf("%d-%x", es.run, sha1.Sum([]byte(res.URL)))
+	action := map[string]map[string]string{
+		"index": {"_index": es.index, "_id": id},
+	}
+	enc := json.NewEncoder(&es.buf)
+	if err := enc.Encode(action); err != nil {
+		return err
+	}
+	if err := enc.Encode(newDoc(res, es.started)); err != nil {
+		return err
+	}
+	es.n++
+	if es.n >= elasticBulkSize {
+		return es.flush()
+	}
+	return nil
+}
+
+type elasticBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// flush sends all buffered documents in one bulk request.
+func (es *elasticSink) flush() error {
+	if es.n == 0 {
+		return nil
+	}
+	data, status, err := es.do("POST", "/_bulk", "application/x-ndjson", &es.buf)
+	es.buf.Reset()
+	es.n = 0
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("bulk request failed: %s", data)
+	}
+	var br elasticBulkResponse
+	if err := json.Unmarshal(data, &br); err != nil {
+		return fmt.Errorf("cannot decode bulk response: %s", err)
+	}
+	if !br.Errors {
+		return nil
+	}
+	for _, item := range br.Items {
+		for _, r := range item {
+			if r.Error != nil {
+				return fmt.Errorf("cannot index document: %s", r.Error)
+			}
+		}
+	}
+	return nil
+}
+
+func (es *elasticSink) close() error {
+	return es.flush()
+}
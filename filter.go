@@ -0,0 +1,52 @@
+package main
+
+import "regexp"
+
+// urlFilter decides which discovered URLs are followed.
+type urlFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+func compileAll(exprs []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(exprs))
+	for _, e := range exprs {
+		re, err := regexp.Compile(e)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func newURLFilter(include, exclude []string) (*urlFilter, error) {
+	inc, err := compileAll(include)
+	if err != nil {
+		return nil, err
+	}
+	exc, err := compileAll(exclude)
+	if err != nil {
+		return nil, err
+	}
+	return &urlFilter{include: inc, exclude: exc}, nil
+}
+
+// allow returns true if url matches at least one include
+// pattern (when there are any) and no exclude pattern.
+func (f *urlFilter) allow(url string) bool {
+	for _, re := range f.exclude {
+		if re.MatchString(url) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		if re.MatchString(url) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"strings"
+)
+
+// skipFragment returns true for fragments that are not
+// meant to point to an element: the top of the page and
+// client-side routes.
+func skipFragment(frag string) bool {
+	return frag == "" || frag == "top" || strings.HasPrefix(frag, "!") || strings.HasPrefix(frag, "/")
+}
+
+// checkFragments adds a "broken-fragment" finding for each link
+// with a fragment to a crawled page that has no element with
+// that id, nor an anchor with that name.
+func checkFragments(rep *report) {
+	ids := make(map[*result]map[string]bool)
+	for _, res := range rep.sorted() {
+		for _, a := range res.Anchors {
+			u, err := nurl.Parse(a.URL)
+			if err != nil || skipFragment(u.Fragment) {
+				continue
+			}
+			frag := u.Fragment
+			u.Fragment = ""
+			target := rep.lookup(u.String())
+			if target == nil || !target.page() {
+				continue
+			}
+			if ids[target] == nil {
+				ids[target] = make(map[string]bool)
+				for _, id := range target.IDs {
+					ids[target][id] = true
+				}
+			}
+			if !ids[target][frag] {
+				rep.add("broken-fragment", res.URL, fmt.Sprintf("%s#%s", target.URL, frag))
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+)
+
+// churn is how often the content of pages changed across the
+// crawls of a history: of the pairs of consecutive crawls that
+// fetched a page, in how many its content hash differed.
+type churn struct {
+	pairs, changes int
+}
+
+func (c churn) rate() float64 {
+	if c.pairs == 0 {
+		return 0
+	}
+	return float64(c.changes) / float64(c.pairs)
+}
+
+// pageChurn returns the churn of each URL fetched by at least two
+// consecutive crawls of snaps.
+func pageChurn(snaps []*Report) map[string]*churn {
+	pages := make(map[string]*churn)
+	prev := make(map[string]string)
+	for _, s := range snaps {
+		cur := make(map[string]string)
+		for _, r := range s.Results {
+			if r.Error != "" || r.Status != http.StatusOK || r.Hash == "" {
+				continue
+			}
+			cur[r.URL] = r.Hash
+			old, ok := prev[r.URL]
+			if !ok {
+				continue
+			}
+			c := pages[r.URL]
+			if c == nil {
+				c = &churn{}
+				pages[r.URL] = c
+			}
+			c.pairs++
+			if old != r.Hash {
+				c.changes++
+			}
+		}
+		prev = cur
+	}
+	return pages
+}
+
+// writeFreshness prints, for each directory of the site, how many
+// pages were seen changing, how many never changed and the share
+// of crawls in which a page changed, churn-heavy directories
+// first; then the same for each page.
+func writeFreshness(w io.Writer, snaps []*Report) error {
+	pages := pageChurn(snaps)
+	urls := make([]string, 0, len(pages))
+	for url := range pages {
+		urls = append(urls, url)
+	}
+	type section struct {
+		churn
+		dir            string
+		npages, stable int
+	}
+	byDir := make(map[string]*section)
+	var sections []*section
+	for _, url := range urls {
+		dir := directory(url)
+		s := byDir[dir]
+		if s == nil {
+			s = &section{dir: dir}
+			byDir[dir] = s
+			sections = append(sections, s)
+		}
+		c := pages[url]
+		s.npages++
+		s.pairs += c.pairs
+		s.changes += c.changes
+		if c.changes == 0 {
+			s.stable++
+		}
+	}
+	sort.Slice(sections, func(i, j int) bool {
+		if ri, rj := sections[i].rate(), sections[j].rate(); ri != rj {
+			return ri > rj
+		}
+		return sections[i].dir < sections[j].dir
+	})
+	sort.Slice(urls, func(i, j int) bool {
+		if ri, rj := pages[urls[i]].rate(), pages[urls[j]].rate(); ri != rj {
+			return ri > rj
+		}
+		return urls[i] < urls[j]
+	})
+	if _, err := fmt.Fprintf(w, "%d crawls\n\nsection\tpages\tnever changed\tchange rate\n", len(snaps)); err != nil {
+		return err
+	}
+	for _, s := range sections {
+		if _, err := fmt.Fprintf(w, "%s\t%d\t%d\t%.0f%%\n", s.dir, s.npages, s.stable, 100*s.rate()); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(w, "\npage\tcrawls compared\tchanges\tchange rate"); err != nil {
+		return err
+	}
+	for _, url := range urls {
+		c := pages[url]
+		if _, err := fmt.Fprintf(w, "%s\t%d\t%d\t%.0f%%\n", url, c.pairs, c.changes, 100*c.rate()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// freshnessCmd implements the "freshness" subcommand: how often
+// pages changed across the crawls kept with -history.
+func freshnessCmd(opts *options, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: seopeo freshness DIR\n")
+		return 2
+	}
+	snaps, err := loadSnapshots(args[0])
+	if err != nil {
+		slog.Error("cannot read history", "err", err)
+		return 1
+	}
+	if len(snaps) < 2 {
+		slog.Error("freshness needs at least two crawls", "dir", args[0], "crawls", len(snaps))
+		return 1
+	}
+	if err := writeFreshness(os.Stdout, snaps); err != nil {
+		slog.Error("cannot write freshness", "err", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"container/heap"
+	"math/rand"
+	nurl "net/url"
+	"sort"
+)
+
+// queued is a URL waiting in the frontier, with what orders it
+// worked out once when it was queued.
+type queued struct {
+	url   string
+	host  string
+	prio  int
+	depth int
+	index int // in the heap of the host
+}
+
+// strategies compare two queued URLs for each crawl order.
+// Ties are broken by URL, so that crawls are repeatable.
+var strategies = map[string]func(a, b *queued) bool{
+	// bfs crawls shallow pages first, so that the depth found
+	// for a page is the length of its shortest path.
+	"bfs": func(a, b *queued) bool {
+		if a.depth != b.depth {
+			return a.depth < b.depth
+		}
+		return a.url < b.url
+	},
+	// dfs crawls the deepest pages first, following a path to
+	// its end before going back.
+	"dfs": func(a, b *queued) bool {
+		if a.depth != b.depth {
+			return a.depth > b.depth
+		}
+		return a.url < b.url
+	},
+}
+
+// hostQueue is a heap of the queued URLs of a host, in the order
+// given by the priorities and the strategy of the crawl.
+type hostQueue struct {
+	items []*queued
+	less  func(a, b *queued) bool
+}
+
+func (q *hostQueue) Len() int { return len(q.items) }
+
+func (q *hostQueue) Less(i, j int) bool {
+	a, b := q.items[i], q.items[j]
+	if a.prio != b.prio {
+		return a.prio > b.prio
+	}
+	return q.less(a, b)
+}
+
+func (q *hostQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+	q.items[i].index = i
+	q.items[j].index = j
+}
+
+func (q *hostQueue) Push(x interface{}) {
+	item := x.(*queued)
+	item.index = len(q.items)
+	q.items = append(q.items, item)
+}
+
+func (q *hostQueue) Pop() interface{} {
+	n := len(q.items) - 1
+	item := q.items[n]
+	q.items[n] = nil
+	q.items = q.items[:n]
+	return item
+}
+
+// frontier holds the URLs still to visit. It takes them from each
+// host in turn, keeping their order within the host, so that no
+// host gets many requests in a row. The order of the hosts in each
+// turn is random.
+type frontier struct {
+	less  func(a, b *queued) bool
+	rand  *rand.Rand
+	hosts map[string]*hostQueue
+	queue map[string]*queued
+	turn  []string // hosts left in the current turn
+}
+
+func newFrontier(less func(a, b *queued) bool, rand *rand.Rand) *frontier {
+	return &frontier{
+		less:  less,
+		rand:  rand,
+		hosts: make(map[string]*hostQueue),
+		queue: make(map[string]*queued),
+	}
+}
+
+// len returns how many URLs are queued.
+func (f *frontier) len() int {
+	return len(f.queue)
+}
+
+// push queues url with priority prio, found at depth.
+func (f *frontier) push(url string, prio, depth int) {
+	if _, ok := f.queue[url]; ok {
+		return
+	}
+	host := ""
+	if u, err := nurl.Parse(url); err == nil {
+		host = u.Host
+	}
+	item := &queued{url: url, host: host, prio: prio, depth: depth}
+	q, ok := f.hosts[host]
+	if !ok {
+		q = &hostQueue{less: f.less}
+		f.hosts[host] = q
+	}
+	heap.Push(q, item)
+	f.queue[url] = item
+}
+
+// fix moves url, if it is queued, to where it goes now that it was
+// found at a lower depth.
+func (f *frontier) fix(url string, depth int) {
+	item, ok := f.queue[url]
+	if !ok {
+		return
+	}
+	item.depth = depth
+	heap.Fix(f.hosts[item.host], item.index)
+}
+
+// pop returns the next URL to visit, or false if none is queued.
+func (f *frontier) pop() (string, bool) {
+	if len(f.queue) == 0 {
+		return "", false
+	}
+	for {
+		if len(f.turn) == 0 {
+			f.next()
+		}
+		host := f.turn[0]
+		f.turn = f.turn[1:]
+		q := f.hosts[host]
+		if q == nil || q.Len() == 0 {
+			continue
+		}
+		item := heap.Pop(q).(*queued)
+		if q.Len() == 0 {
+			delete(f.hosts, host)
+		}
+		delete(f.queue, item.url)
+		return item.url, true
+	}
+}
+
+// next starts a turn over all hosts with queued URLs.
+func (f *frontier) next() {
+	hosts := make([]string, 0, len(f.hosts))
+	for host := range f.hosts {
+		hosts = append(hosts, host)
+	}
+	if len(hosts) > 1 {
+		sort.Strings(hosts)
+		f.rand.Shuffle(len(hosts), func(i, j int) {
+			hosts[i], hosts[j] = hosts[j], hosts[i]
+		})
+	}
+	f.turn = hosts
+}
+
+// enqueue adds url to the URLs still to visit.
+func (c *crawler) enqueue(url string) {
+	c.urls[url] = false
+	if c.queue != nil {
+		c.queue.push(url, c.priority(url), c.depths[url])
+	}
+}
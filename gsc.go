@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	nurl "net/url"
+	"strings"
+	"time"
+)
+
+const (
+	gscAnalyticsEndpoint  = "https://www.googleapis.com/webmasters/v3/sites/%s/searchAnalytics/query"
+	gscInspectionEndpoint = "https://searchconsole.googleapis.com/v1/urlInspection/index:inspect"
+	gscRowLimit           = 25000
+)
+
+// gscData is what Search Console knows about a crawled URL.
+type gscData struct {
+	Clicks      float64
+	Impressions float64
+	Coverage    string // only set when URLs are inspected
+	Verdict     string
+}
+
+// gscClient talks to the Search Console API using an OAuth2
+// access token (e.g. from "gcloud auth print-access-token").
+type gscClient struct {
+	site    string
+	token   string
+	days    int
+	inspect bool
+	client  *http.Client
+}
+
+func newGscClient(site, token string, days int, inspect bool) *gscClient {
+	return &gscClient{
+		site:    site,
+		token:   token,
+		days:    days,
+		inspect: inspect,
+		client:  http.DefaultClient,
+	}
+}
+
+func (g *gscClient) post(endpoint string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	hreq, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	hreq.Header.Set("Authorization", "Bearer "+g.token)
+	hreq.Header.Set("Content-Type", "application/json")
+	hresp, err := g.client.Do(hreq)
+	if err != nil {
+		return fmt.Errorf("cannot query Search Console: %s", err)
+	}
+	defer hresp.Body.Close()
+	if hresp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Search Console returned %s", hresp.Status)
+	}
+	if err := json.NewDecoder(hresp.Body).Decode(resp); err != nil {
+		return fmt.Errorf("cannot decode Search Console response: %s", err)
+	}
+	return nil
+}
+
+type gscAnalyticsQuery struct {
+	StartDate  string   `json:"startDate"`
+	EndDate    string   `json:"endDate"`
+	Dimensions []string `json:"dimensions"`
+	RowLimit   int      `json:"rowLimit"`
+	StartRow   int      `json:"startRow"`
+}
+
+type gscAnalyticsResponse struct {
+	Rows []struct {
+		Keys        []string `json:"keys"`
+		Clicks      float64  `json:"clicks"`
+		Impressions float64  `json:"impressions"`
+	} `json:"rows"`
+}
+
+// pages returns all pages that appeared in search results
+// in the last g.days days, keyed by URL.
+func (g *gscClient) pages() (map[string]*gscData, error) {
+	end := time.Now()
+	q := gscAnalyticsQuery{
+		StartDate:  end.AddDate(0, 0, -g.days).Format("2006-01-02"),
+		EndDate:    end.Format("2006-01-02"),
+		Dimensions: []string{"page"},
+		RowLimit:   gscRowLimit,
+	}
+	endpoint := fmt.Sprintf(gscAnalyticsEndpoint, nurl.PathEscape(g.site))
+	pages := make(map[string]*gscData)
+	for {
+		var resp gscAnalyticsResponse
+		if err := g.post(endpoint, q, &resp); err != nil {
+			return nil, err
+		}
+		for _, row := range resp.Rows {
+			if len(row.Keys) == 0 {
+				continue
+			}
+			pages[gscKey(row.Keys[0])] = &gscData{
+				Clicks:      row.Clicks,
+				Impressions: row.Impressions,
+			}
+		}
+		if len(resp.Rows) < gscRowLimit {
+			break
+		}
+		q.StartRow += gscRowLimit
+	}
+	return pages, nil
+}
+
+type gscInspectionResponse struct {
+	InspectionResult struct {
+		IndexStatusResult struct {
+			Verdict       string `json:"verdict"`
+			CoverageState string `json:"coverageState"`
+		} `json:"indexStatusResult"`
+	} `json:"inspectionResult"`
+}
+
+// inspectURL asks for the index coverage of a single URL.
+// This API is heavily rate limited, use with care.
+func (g *gscClient) inspectURL(url string, data *gscData) error {
+	req := map[string]string{"inspectionUrl": url, "siteUrl": g.site}
+	var resp gscInspectionResponse
+	if err := g.post(gscInspectionEndpoint, req, &resp); err != nil {
+		return err
+	}
+	data.Verdict = resp.InspectionResult.IndexStatusResult.Verdict
+	data.Coverage = resp.InspectionResult.IndexStatusResult.CoverageState
+	return nil
+}
+
+// gscKey makes crawled and Search Console URLs comparable.
+func gscKey(url string) string {
+	return strings.TrimSuffix(url, "/")
+}
+
+// annotate joins Search Console data with the crawl results,
+// flagging crawled pages that are not indexed and indexed
+// pages of the crawled site that the crawl could not reach
+// through links. Without a full crawl, nothing is orphaned.
+func (g *gscClient) annotate(rep *report) error {
+	pages, err := g.pages()
+	if err != nil {
+		return err
+	}
+	crawled := make(map[string]bool)
+	for url := range rep.results {
+		crawled[gscKey(url)] = true
+	}
+	annotated := make(map[string]bool)
+	for _, res := range rep.sorted() {
+		key := gscKey(res.URL)
+		if !res.page() || annotated[key] {
+			continue
+		}
+		annotated[key] = true
+		data, ok := pages[key]
+		if !ok {
+			data = &gscData{}
+		}
+		if g.inspect {
+			if err := g.inspectURL(res.URL, data); err != nil {
+				return err
+			}
+		}
+		res.GSC = data
+		switch {
+		case g.inspect && data.Verdict != "PASS":
+			rep.add("crawled-not-indexed", res.URL, data.Coverage)
+		case !g.inspect && !ok:
+			rep.add("crawled-not-indexed", res.URL, "no search impressions")
+		}
+	}
+	if rep.truncated != "" || rep.interrupted || rep.sampled {
+		return nil
+	}
+	base, err := nurl.Parse(rep.base)
+	if err != nil {
+		return nil
+	}
+	for url := range pages {
+		u, err := nurl.Parse(url)
+		if err != nil || u.Scheme != base.Scheme || u.Host != base.Host {
+			continue
+		}
+		if !crawled[url] {
+			rep.add("indexed-orphaned", url, "")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	nurl "net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// snapshotExt is the extension of the snapshots in a history.
+const snapshotExt = ".json"
+
+// saveSnapshot stores the exported report in the history
+// directory dir, named after the time of the crawl, a sequence
+// number and the host crawled. Snapshots of crawls that did not
+// cover the whole site are marked as partial.
+func saveSnapshot(dir string, rep *report) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	seq, err := nextSnapshot(dir)
+	if err != nil {
+		return err
+	}
+	e := rep.export()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, snapshotName(e, seq)), data, 0644)
+}
+
+// snapshotName returns the file name of snapshot e, number seq.
+// Names sort in the order the crawls were made, also when more
+// crawls happen in the same second or their time is fixed.
+func snapshotName(e *Report, seq int) string {
+	host := "unknown"
+	if u, err := nurl.Parse(e.Base); err == nil && u.Host != "" {
+		host = strings.Replace(u.Host, ":", "_", -1)
+	}
+	name := fmt.Sprintf("%s-%06d-%s", e.CrawledAt.Format("20060102T150405Z"), seq, host)
+	if e.partial() {
+		name += "-partial"
+	}
+	return name + snapshotExt
+}
+
+// nextSnapshot returns the sequence number of the next snapshot
+// in the history directory dir.
+func nextSnapshot(dir string) (int, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	seq := 1
+	for _, f := range files {
+		parts := strings.SplitN(f.Name(), "-", 3)
+		if len(parts) < 3 || !strings.HasSuffix(f.Name(), snapshotExt) {
+			continue
+		}
+		if n, err := strconv.Atoi(parts[1]); err == nil && n >= seq {
+			seq = n + 1
+		}
+	}
+	return seq, nil
+}
+
+// loadSnapshots reads all snapshots of the history directory
+// dir, oldest first.
+func loadSnapshots(dir string) ([]*Report, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), snapshotExt) {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+	snaps := make([]*Report, 0, len(names))
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		var r Report
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("cannot decode snapshot %s: %s", name, err)
+		}
+		snaps = append(snaps, &r)
+	}
+	return snaps, nil
+}
+
+// writeTrends prints one line per snapshot with its page, error
+// and finding counts and average depth, then the count of each
+// type of finding in each snapshot.
+func writeTrends(w io.Writer, snaps []*Report) error {
+	if _, err := fmt.Fprintln(w, "crawled at\tpages\terrors\tdepth\tfindings"); err != nil {
+		return err
+	}
+	kinds := make(map[string][]int)
+	for i, s := range snaps {
+		errors, depth := 0, 0
+		for _, r := range s.Results {
+			if r.Error != "" || r.Status >= 400 {
+				errors++
+			}
+			depth += r.Depth
+		}
+		avg := 0.0
+		if len(s.Results) > 0 {
+			avg = float64(depth) / float64(len(s.Results))
+		}
+		_, err := fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\t%d\n", s.CrawledAt.Format("2006-01-02 15:04:05"),
+			len(s.Results), errors, avg, len(s.Findings))
+		if err != nil {
+			return err
+		}
+		for _, f := range s.Findings {
+			if kinds[f.Kind] == nil {
+				kinds[f.Kind] = make([]int, len(snaps))
+			}
+			kinds[f.Kind][i]++
+		}
+	}
+	names := make([]string, 0, len(kinds))
+	for k := range kinds {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	if _, err := fmt.Fprintln(w, "\nfindings by type"); err != nil {
+		return err
+	}
+	for _, k := range names {
+		counts := make([]string, len(snaps))
+		for i, n := range kinds[k] {
+			counts[i] = fmt.Sprintf("%d", n)
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", k, strings.Join(counts, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trendsCmd implements the "trends" subcommand: print how the
+// crawls kept in a history directory changed over time.
+func trendsCmd(opts *options, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: seopeo trends DIR\n")
+		return 2
+	}
+	snaps, err := loadSnapshots(args[0])
+	if err != nil {
+		slog.Error("cannot read history", "err", err)
+		return 1
+	}
+	if err := writeTrends(os.Stdout, snaps); err != nil {
+		slog.Error("cannot write trends", "err", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// htmlReportMax is the number of worst offenders listed.
+const htmlReportMax = 20
+
+type statusCount struct {
+	Status int
+	Text   string
+	Count  int
+}
+
+type kindCount struct {
+	Kind     string
+	Count    int
+	Findings []finding
+}
+
+type offender struct {
+	URL   string
+	Count int
+}
+
+// reportModel is the data given to report templates, both
+// the built-in HTML report and user supplied ones.
+type reportModel struct {
+	Generated time.Time
+	Base      string
+	Pages     int
+	Errors    int
+	Statuses  []statusCount
+	Kinds     []kindCount
+	Offenders []offender
+	Results   []*result
+	Findings  []finding
+}
+
+func newReportModel(r *report) *reportModel {
+	d := &reportModel{
+		Generated: now(),
+		Base:      r.base,
+		Pages:     len(r.results),
+		Results:   r.sorted(),
+		Findings:  r.findings,
+	}
+	statuses := make(map[int]int)
+	for _, res := range d.Results {
+		if res.Err != nil {
+			d.Errors++
+			continue
+		}
+		statuses[res.Status]++
+	}
+	for st, n := range statuses {
+		d.Statuses = append(d.Statuses, statusCount{Status: st, Text: http.StatusText(st), Count: n})
+	}
+	sort.Slice(d.Statuses, func(i, j int) bool {
+		return d.Statuses[i].Status < d.Statuses[j].Status
+	})
+	perURL := make(map[string]int)
+	for kind, fs := range r.byKind() {
+		d.Kinds = append(d.Kinds, kindCount{Kind: kind, Count: len(fs), Findings: fs})
+		for _, f := range fs {
+			perURL[f.URL]++
+		}
+	}
+	sort.Slice(d.Kinds, func(i, j int) bool {
+		if d.Kinds[i].Count != d.Kinds[j].Count {
+			return d.Kinds[i].Count > d.Kinds[j].Count
+		}
+		return d.Kinds[i].Kind < d.Kinds[j].Kind
+	})
+	for url, n := range perURL {
+		d.Offenders = append(d.Offenders, offender{URL: url, Count: n})
+	}
+	sort.Slice(d.Offenders, func(i, j int) bool {
+		if d.Offenders[i].Count != d.Offenders[j].Count {
+			return d.Offenders[i].Count > d.Offenders[j].Count
+		}
+		return d.Offenders[i].URL < d.Offenders[j].URL
+	})
+	if len(d.Offenders) > htmlReportMax {
+		d.Offenders = d.Offenders[:htmlReportMax]
+	}
+	return d
+}
+
+// writeHTML renders r as a single HTML file with no external
+// resources, which can be opened directly in a browser.
+func (r *report) writeHTML(w io.Writer) error {
+	return htmlReportTemplate.Execute(w, newReportModel(r))
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Crawl report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { margin-bottom: 0; }
+.meta { color: #666; }
+.cards { display: flex; gap: 1em; flex-wrap: wrap; }
+.card { border: 1px solid #ddd; border-radius: 4px; padding: 0.5em 1em; }
+.card b { display: block; font-size: 1.6em; }
+table { border-collapse: collapse; margin: 1em 0; width: 100%; }
+th, td { border-bottom: 1px solid #eee; padding: 0.3em 0.6em; text-align: left; }
+th { cursor: pointer; background: #f5f5f5; user-select: none; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+td.num { text-align: right; }
+.bad { color: #b00; }
+details { margin: 0.3em 0; }
+</style>
+</head>
+<body>
+<h1>Crawl report</h1>
+<p class="meta">Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}</p>
+
+<div class="cards">
+<div class="card"><b>{{.Pages}}</b>pages</div>
+<div class="card"><b>{{.Errors}}</b>fetch errors</div>
+<div class="card"><b>{{len .Kinds}}</b>issue types</div>
+</div>
+
+<h2>Status codes</h2>
+<table class="sortable">
+<thead><tr><th>Status</th><th>Description</th><th>Pages</th></tr></thead>
+<tbody>
+{{range .Statuses}}<tr><td class="num{{if ge .Status 400}} bad{{end}}">{{.Status}}</td><td>{{.Text}}</td><td class="num">{{.Count}}</td></tr>
+{{end}}</tbody>
+</table>
+
+<h2>Issues by type</h2>
+{{if .Kinds}}<table class="sortable">
+<thead><tr><th>Type</th><th>Count</th></tr></thead>
+<tbody>
+{{range .Kinds}}<tr><td>{{.Kind}}</td><td class="num">{{.Count}}</td></tr>
+{{end}}</tbody>
+</table>
+{{range .Kinds}}<details><summary>{{.Kind}} ({{.Count}})</summary>
+<table class="sortable">
+<thead><tr><th>URL</th><th>Detail</th></tr></thead>
+<tbody>
+{{range .Findings}}<tr><td><a href="{{.URL}}">{{.URL}}</a></td><td>{{.Detail}}</td></tr>
+{{end}}</tbody>
+</table>
+</details>
+{{end}}{{else}}<p>No issues found.</p>{{end}}
+
+<h2>Worst offenders</h2>
+{{if .Offenders}}<table class="sortable">
+<thead><tr><th>URL</th><th>Issues</th></tr></thead>
+<tbody>
+{{range .Offenders}}<tr><td><a href="{{.URL}}">{{.URL}}</a></td><td class="num">{{.Count}}</td></tr>
+{{end}}</tbody>
+</table>{{else}}<p>No issues found.</p>{{end}}
+
+<h2>All pages</h2>
+<table class="sortable">
+<thead><tr><th>URL</th><th>Status</th><th>Depth</th><th>Links</th><th>Issues</th></tr></thead>
+<tbody>
+{{range .Results}}<tr><td><a href="{{.URL}}">{{.URL}}</a></td><td class="num{{if or .Err (ge .Status 400)}} bad{{end}}">{{if .Err}}error{{else}}{{.Status}}{{end}}</td><td class="num">{{.Depth}}</td><td class="num">{{len .Links}}</td><td class="num">{{len .Findings}}</td></tr>
+{{end}}</tbody>
+</table>
+
+<script>
+document.querySelectorAll("table.sortable th").forEach(function(th) {
+	th.addEventListener("click", function() {
+		var table = th.closest("table"), body = table.tBodies[0];
+		var col = Array.prototype.indexOf.call(th.parentNode.children, th);
+		var asc = !th.classList.contains("asc");
+		th.parentNode.querySelectorAll("th").forEach(function(h) { h.classList.remove("asc", "desc"); });
+		th.classList.add(asc ? "asc" : "desc");
+		var rows = Array.prototype.slice.call(body.rows);
+		rows.sort(function(a, b) {
+			var x = a.cells[col].textContent, y = b.cells[col].textContent;
+			var nx = parseFloat(x), ny = parseFloat(y);
+			var c = (!isNaN(nx) && !isNaN(ny)) ? nx - ny : x.localeCompare(y);
+			return asc ? c : -c;
+		});
+		rows.forEach(function(r) { body.appendChild(r); });
+	});
+});
+</script>
+</body>
+</html>
+`))
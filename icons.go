@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	nurl "net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// icon is a favicon or touch icon declared by a page.
+type icon struct {
+	Rel string
+	URL string
+}
+
+// touch returns true for Apple touch icons.
+func (i icon) touch() bool {
+	return strings.HasPrefix(i.Rel, "apple-touch-icon")
+}
+
+// iconAnalyzer collects the icons a page declares.
+type iconAnalyzer struct {
+	p     *page
+	icons []icon
+}
+
+func (a *iconAnalyzer) token(t *html.Token) {
+	if t.Data != "link" || (t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken) {
+		return
+	}
+	rel, _ := attr(t, "rel")
+	href, ok := attr(t, "href")
+	if !ok || href == "" {
+		return
+	}
+	for _, r := range strings.Fields(strings.ToLower(rel)) {
+		if r == "icon" || strings.HasPrefix(r, "apple-touch-icon") {
+			a.icons = append(a.icons, icon{Rel: r, URL: a.p.resolve(href)})
+			return
+		}
+	}
+}
+
+func (a *iconAnalyzer) finish(res *result) {
+	res.Icons = a.icons
+}
+
+// checkIconURL fetches an icon and returns what is wrong with
+// it, or an empty string.
+func checkIconURL(ctx context.Context, fetch *fetcher, url string) (kind, detail string) {
+	resp, err := fetch.get(ctx, url)
+	if err != nil {
+		return "broken-icon", err.Error()
+	}
+	if resp.status != 200 {
+		return "broken-icon", fmt.Sprintf("status %d", resp.status)
+	}
+	ct := resp.header.Get("Content-Type")
+	if mt, _, err := mime.ParseMediaType(ct); err != nil || !strings.HasPrefix(mt, "image/") {
+		return "icon-wrong-type", fmt.Sprintf("content type %q", ct)
+	}
+	return "", ""
+}
+
+// checkIcons fetches the icons declared by the crawled pages and
+// reports those that do not load or are not images. If the root
+// of the site declares no favicon or touch icon, the files at
+// their conventional locations must exist.
+func checkIcons(ctx context.Context, fetch *fetcher, rep *report) {
+	users := make(map[string][]string)
+	for _, res := range rep.sorted() {
+		for _, i := range res.Icons {
+			users[i.URL] = append(users[i.URL], res.URL)
+		}
+	}
+	urls := make([]string, 0, len(users))
+	for url := range users {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	for _, url := range urls {
+		if kind, detail := checkIconURL(ctx, fetch, url); kind != "" {
+			rep.add(kind, url, fmt.Sprintf("%s, declared by %s", detail, pageList(users[url])))
+		}
+	}
+	base, err := nurl.Parse(rep.base)
+	if err != nil {
+		return
+	}
+	var favicon, touch bool
+	if root := rep.lookup(base.Scheme + "://" + base.Host + "/"); root != nil {
+		for _, i := range root.Icons {
+			favicon = favicon || !i.touch()
+			touch = touch || i.touch()
+		}
+	}
+	for _, c := range []struct {
+		declared bool
+		kind     string
+		path     string
+	}{
+		{favicon, "missing-favicon", "/favicon.ico"},
+		{touch, "missing-touch-icon", "/apple-touch-icon.png"},
+	} {
+		if c.declared {
+			continue
+		}
+		url := base.Scheme + "://" + base.Host + c.path
+		if kind, detail := checkIconURL(ctx, fetch, url); kind != "" {
+			rep.add(c.kind, rep.base, fmt.Sprintf("none declared and %s: %s", c.path, detail))
+		}
+	}
+}
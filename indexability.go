@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	nurl "net/url"
+	"strings"
+)
+
+// maxSitemaps is how many sitemaps are read, also counting those
+// listed by sitemap indexes.
+const maxSitemaps = 100
+
+// sitemapFile is either a sitemap or a sitemap index.
+type sitemapFile struct {
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+	URLs []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"url"`
+}
+
+// readSitemaps fetches the sitemaps at urls, following sitemap
+// indexes, and returns the URLs they list without trailing slash,
+// each with its lastmod, if any.
+func readSitemaps(ctx context.Context, fetch *fetcher, urls []string) map[string]string {
+	listed := make(map[string]string)
+	seen := make(map[string]bool)
+	for len(urls) > 0 && len(seen) < maxSitemaps {
+		url := urls[0]
+		urls = urls[1:]
+		if seen[url] {
+			continue
+		}
+		seen[url] = true
+		resp, err := fetch.get(ctx, url)
+		if err != nil {
+			slog.Warn("cannot fetch sitemap", "url", url, "err", err)
+			continue
+		}
+		if resp.status != http.StatusOK {
+			slog.Warn("cannot fetch sitemap", "url", url, "status", resp.status)
+			continue
+		}
+		body := resp.body
+		// Compressed sitemaps are sent as gzip files.
+		if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+			if body, err = gunzip(body); err != nil {
+				slog.Warn("cannot decompress sitemap", "url", url, "err", err)
+				continue
+			}
+		}
+		var sf sitemapFile
+		if err := xml.Unmarshal(body, &sf); err != nil {
+			slog.Warn("cannot parse sitemap", "url", url, "err", err)
+			continue
+		}
+		for _, s := range sf.Sitemaps {
+			urls = append(urls, strings.TrimSpace(s.Loc))
+		}
+		for _, u := range sf.URLs {
+			listed[strings.TrimSuffix(strings.TrimSpace(u.Loc), "/")] = strings.TrimSpace(u.LastMod)
+		}
+	}
+	return listed
+}
+
+// indexSignals are the signals about indexing that come from
+// outside the pages: robots.txt and the sitemaps of the site.
+type indexSignals struct {
+	robots    *robotsTxt // nil if it could not be fetched
+	agent     string
+	inSitemap map[string]string // lastmod by URL
+}
+
+// fetchIndexSignals fetches robots.txt of the site of base and
+// the sitemaps it lists, or /sitemap.xml if it lists none.
+func fetchIndexSignals(ctx context.Context, fetch *fetcher, base *nurl.URL, agent string) *indexSignals {
+	is := &indexSignals{agent: agent}
+	rt, _, err := fetchRobots(ctx, fetch, base)
+	if err != nil {
+		slog.Error("cannot fetch robots.txt", "err", err)
+	}
+	is.robots = rt
+	var sitemaps []string
+	if rt != nil {
+		sitemaps = rt.sitemaps
+	}
+	if len(sitemaps) == 0 {
+		sitemaps = []string{base.Scheme + "://" + base.Host + "/sitemap.xml"}
+	}
+	is.inSitemap = readSitemaps(ctx, fetch, sitemaps)
+	return is
+}
+
+// orDash returns s, or "-" if it is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// writeIndexability prints, for each page, all signals that
+// decide whether it can be indexed, as tab separated columns.
+// Signals that were not fetched are shown as "-".
+func (r *report) writeIndexability(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "url\tstatus\trobots.txt\tmeta robots\tx-robots-tag\tcanonical\tsitemap\tindexable"); err != nil {
+		return err
+	}
+	for _, res := range r.sorted() {
+		status := fmt.Sprintf("%d", res.Status)
+		if res.Err != nil {
+			status = "error"
+		}
+		indexable := res.indexable()
+		robots, sitemap := "-", "-"
+		if s := r.signals; s != nil {
+			if s.robots != nil {
+				allowed, rule := s.robots.test(s.agent, res.URL)
+				robots = "allowed"
+				if !allowed {
+					robots = "disallowed by " + rule.String()
+					indexable = false
+				}
+			}
+			sitemap = "no"
+			if _, ok := s.inSitemap[strings.TrimSuffix(res.URL, "/")]; ok {
+				sitemap = "yes"
+			}
+		}
+		canonical := res.Canonical
+		switch {
+		case canonical == "":
+			canonical = "none"
+		case sameURL(canonical, res.URL):
+			canonical = "self"
+		}
+		verdict := "no"
+		if indexable {
+			verdict = "yes"
+		}
+		_, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", res.URL, status, robots,
+			orDash(res.Robots), orDash(res.XRobotsTag), canonical, sitemap, verdict)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	nurl "net/url"
+	"strings"
+)
+
+const (
+	indexNowEndpoint = "https://api.indexnow.org/indexnow"
+	// indexNowMaxURLs is how many URLs IndexNow takes per request.
+	indexNowMaxURLs = 10000
+)
+
+// indexNowClient submits URLs to search engines supporting
+// IndexNow. The key must be served at keyLocation or, if that is
+// not set, at /KEY.txt of the site.
+type indexNowClient struct {
+	endpoint    string
+	key         string
+	keyLocation string
+	client      *http.Client
+}
+
+func newIndexNowClient(endpoint, key, keyLocation string) *indexNowClient {
+	return &indexNowClient{
+		endpoint:    endpoint,
+		key:         key,
+		keyLocation: keyLocation,
+		client:      http.DefaultClient,
+	}
+}
+
+type indexNowRequest struct {
+	Host        string   `json:"host"`
+	Key         string   `json:"key"`
+	KeyLocation string   `json:"keyLocation,omitempty"`
+	URLList     []string `json:"urlList"`
+}
+
+// submit sends urls, all on host, in as many requests as needed.
+func (in *indexNowClient) submit(host string, urls []string) error {
+	for len(urls) > 0 {
+		n := len(urls)
+		if n > indexNowMaxURLs {
+			n = indexNowMaxURLs
+		}
+		body, err := json.Marshal(indexNowRequest{Host: host, Key: in.key, KeyLocation: in.keyLocation, URLList: urls[:n]})
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest("POST", in.endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		resp, err := in.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("cannot submit to IndexNow: %s", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+			return fmt.Errorf("IndexNow returned %s", resp.Status)
+		}
+		urls = urls[n:]
+	}
+	return nil
+}
+
+// submitURLs returns the indexable pages to submit for indexing:
+// with a previous crawl, those that are new or changed since, all
+// of them otherwise.
+func (r *report) submitURLs(prev *Report) []string {
+	before := make(map[string]string)
+	if prev != nil {
+		for _, res := range prev.Results {
+			if res.Error == "" && res.Status == http.StatusOK {
+				before[strings.TrimSuffix(res.URL, "/")] = res.Hash
+			}
+		}
+	}
+	var urls []string
+	for _, res := range r.sorted() {
+		if !res.indexable() {
+			continue
+		}
+		if hash, ok := before[strings.TrimSuffix(res.URL, "/")]; prev != nil && ok && hash == res.Hash {
+			continue
+		}
+		urls = append(urls, res.URL)
+	}
+	return urls
+}
+
+// pingSitemap tells each of endpoints, like
+// "https://example.com/ping?sitemap=", that the sitemap at
+// sitemap changed.
+func pingSitemap(endpoints []string, sitemap string) {
+	for _, e := range endpoints {
+		resp, err := http.Get(e + nurl.QueryEscape(sitemap))
+		if err != nil {
+			slog.Error("cannot ping sitemap", "endpoint", e, "err", err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			slog.Error("cannot ping sitemap", "endpoint", e, "status", resp.Status)
+			continue
+		}
+		slog.Info("sitemap pinged", "endpoint", e, "sitemap", sitemap)
+	}
+}
+
+// submit sends the pages to IndexNow and pings the sitemap, as
+// configured in opts, if there is anything new since prev.
+func submit(opts *options, rep *report, prev *Report) {
+	base, err := nurl.Parse(rep.base)
+	if err != nil {
+		return
+	}
+	urls := rep.submitURLs(prev)
+	if len(urls) == 0 {
+		slog.Info("no new or changed pages to submit")
+		return
+	}
+	if opts.indexNowKey != "" {
+		in := newIndexNowClient(opts.indexNowEndpoint, opts.indexNowKey, opts.indexNowKeyURL)
+		if err := in.submit(base.Host, urls); err != nil {
+			slog.Error("cannot submit pages", "err", err)
+		} else {
+			slog.Info("pages submitted to IndexNow", "pages", len(urls))
+		}
+	}
+	sitemap := opts.sitemapURL
+	if sitemap == "" {
+		sitemap = base.Scheme + "://" + base.Host + "/sitemap.xml"
+	}
+	pingSitemap(opts.pings, sitemap)
+}
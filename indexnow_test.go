@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	nurl "net/url"
+	"reflect"
+	"testing"
+)
+
+func TestIndexNowSubmit(t *testing.T) {
+	site := httptest.NewServer(testSite())
+	defer site.Close()
+	fetch, err := newFetcher("", "", nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err := newURLFilter(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCrawler(context.Background(), []string{site.URL}, 2, fetch, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.configure(newOptions(flag.NewFlagSet("test", flag.ContinueOnError))); err != nil {
+		t.Fatal(err)
+	}
+	c.start()
+	c.wait()
+	rep := newReport(c.base, c.results)
+	rep.sortBy = "url"
+
+	var got []string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req indexNowRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		got = append(got, req.URLList...)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer endpoint.Close()
+	u, _ := nurl.Parse(site.URL)
+	in := newIndexNowClient(endpoint.URL, "key", "")
+	if err := in.submit(u.Host, rep.submitURLs(nil)); err != nil {
+		t.Fatal(err)
+	}
+	// Neither /old, which redirects, nor /missing are submitted.
+	want := []string{site.URL + "/", site.URL + "/about", site.URL + "/blog", site.URL + "/blog/first", site.URL + "/blog/second"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("submitted %v, want %v", got, want)
+	}
+}
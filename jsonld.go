@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ldAnalyzer decodes the JSON-LD structured data of the page
+// for the analyzers that use it once parsing is done, and
+// checks it against the expectations of schema.org types.
+type ldAnalyzer struct {
+	p      *page
+	script textCapture
+	issues []finding
+}
+
+func (a *ldAnalyzer) token(t *html.Token) {
+	if a.script.active() {
+		if a.script.token(t) {
+			var v interface{}
+			if err := json.Unmarshal([]byte(a.script.text.String()), &v); err != nil {
+				a.p.log.Debug("cannot parse JSON-LD", "err", err)
+				a.issues = append(a.issues, finding{Kind: "ld-invalid-json", Detail: err.Error()})
+				return
+			}
+			a.p.ld = append(a.p.ld, v)
+		}
+		return
+	}
+	if t.Type == html.StartTagToken && t.Data == "script" {
+		if typ, _ := attr(t, "type"); strings.EqualFold(typ, "application/ld+json") {
+			a.script.start("script")
+		}
+	}
+}
+
+func (a *ldAnalyzer) finish(res *result) {
+	for _, v := range a.p.ld {
+		if f := ldContext(v); f != nil {
+			a.issues = append(a.issues, *f)
+		}
+		a.issues = validateLD(v, a.issues)
+	}
+	res.Structured = a.issues
+	res.RichResults = richResults(a.p.ld)
+}
+
+// jsonString returns v as a string, the first element if it is
+// a list, or the "url" or "@id" of v if it is an object.
+func jsonString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []interface{}:
+		if len(t) > 0 {
+			return jsonString(t[0])
+		}
+	case map[string]interface{}:
+		if s := jsonString(t["url"]); s != "" {
+			return s
+		}
+		return jsonString(t["@id"])
+	}
+	return ""
+}
+
+// isType returns true if the JSON-LD object o has one of types.
+func isType(o map[string]interface{}, types ...string) bool {
+	var ts []interface{}
+	switch t := o["@type"].(type) {
+	case string:
+		ts = []interface{}{t}
+	case []interface{}:
+		ts = t
+	}
+	for _, t := range ts {
+		for _, typ := range types {
+			if t == typ {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findTyped walks decoded JSON-LD and calls fn for each
+// object of one of types.
+func findTyped(v interface{}, fn func(map[string]interface{}), types ...string) {
+	switch t := v.(type) {
+	case []interface{}:
+		for _, e := range t {
+			findTyped(e, fn, types...)
+		}
+	case map[string]interface{}:
+		if isType(t, types...) {
+			fn(t)
+			return
+		}
+		for _, e := range t {
+			findTyped(e, fn, types...)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// lastmodLayouts are the W3C datetime formats of sitemaps.
+var lastmodLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// parseLastmod parses the lastmod of a sitemap entry.
+func parseLastmod(s string) (time.Time, error) {
+	for _, layout := range lastmodLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a W3C datetime", s)
+}
+
+// lastmodSlack is how much lastmod can be off before it is
+// reported, as it is often written as a date only.
+const lastmodSlack = 24 * time.Hour
+
+// checkLastmod compares the lastmod of each crawled page listed
+// in the sitemaps with when the page changed. It adds findings
+// for values that cannot be parsed ("lastmod-invalid"), are in
+// the future ("lastmod-future") or older than the Last-Modified
+// header of the page ("lastmod-stale"). With prev, the snapshot
+// of the previous crawl, it also reports pages whose content
+// changed since then while lastmod did not ("lastmod-stale"),
+// and the other way around ("lastmod-misleading").
+func checkLastmod(rep *report, prev *Report) {
+	if rep.signals == nil {
+		return
+	}
+	before := make(map[string]string)
+	if prev != nil {
+		for _, r := range prev.Results {
+			if r.Error == "" && r.Status == http.StatusOK {
+				before[strings.TrimSuffix(r.URL, "/")] = r.Hash
+			}
+		}
+	}
+	crawled := now()
+	for _, res := range rep.sorted() {
+		if !res.loaded() {
+			continue
+		}
+		url := strings.TrimSuffix(res.URL, "/")
+		lastmod := rep.signals.inSitemap[url]
+		if lastmod == "" {
+			continue
+		}
+		lm, err := parseLastmod(lastmod)
+		if err != nil {
+			rep.add("lastmod-invalid", res.URL, err.Error())
+			continue
+		}
+		if lm.After(crawled.Add(lastmodSlack)) {
+			rep.add("lastmod-future", res.URL, "lastmod "+lastmod)
+			continue
+		}
+		if res.LastModified != "" {
+			if mod, err := http.ParseTime(res.LastModified); err == nil && mod.After(lm.Add(lastmodSlack)) {
+				rep.add("lastmod-stale", res.URL, fmt.Sprintf("lastmod %s, Last-Modified %s", lastmod, res.LastModified))
+				continue
+			}
+		}
+		hash, ok := before[url]
+		if !ok {
+			continue
+		}
+		since := prev.CrawledAt.Format("2006-01-02 15:04:05")
+		switch {
+		case hash != res.Hash && lm.Add(lastmodSlack).Before(prev.CrawledAt):
+			rep.add("lastmod-stale", res.URL, fmt.Sprintf("lastmod %s, content changed since the crawl of %s", lastmod, since))
+		case hash == res.Hash && lm.After(prev.CrawledAt.Add(lastmodSlack)):
+			rep.add("lastmod-misleading", res.URL, fmt.Sprintf("lastmod %s, content unchanged since the crawl of %s", lastmod, since))
+		}
+	}
+}
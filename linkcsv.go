@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	nurl "net/url"
+)
+
+// writeLinksCSV writes the internal links of the crawled pages as
+// CSV, one row per anchor: source and target URL, anchor text,
+// rel attribute and position in the page.
+func (r *report) writeLinksCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"source", "target", "text", "rel", "position"}); err != nil {
+		return err
+	}
+	for _, res := range r.sorted() {
+		src, err := nurl.Parse(res.URL)
+		if err != nil {
+			continue
+		}
+		for _, a := range res.Anchors {
+			u, err := nurl.Parse(a.URL)
+			if err != nil || (u.Host != src.Host && u.Host != aliasHost(src.Host)) {
+				continue
+			}
+			if err := cw.Write([]string{res.URL, a.URL, a.Text, a.Rel, a.Position}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
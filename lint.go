@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// deprecatedTags are presentational or obsolete elements.
+var deprecatedTags = map[string]bool{
+	"font": true, "center": true, "marquee": true, "blink": true, "big": true,
+	"strike": true, "tt": true, "basefont": true, "applet": true, "acronym": true,
+}
+
+// lintAnalyzer detects outdated HTML and SEO practices. Its
+// findings are all of the "lint-" kinds: worth cleaning up, but
+// of low severity.
+type lintAnalyzer struct {
+	issues []finding
+	nav    int // open nav elements
+}
+
+func (a *lintAnalyzer) add(kind, detail string) {
+	a.issues = append(a.issues, finding{Kind: kind, Detail: detail})
+}
+
+func (a *lintAnalyzer) token(t *html.Token) {
+	if t.Type == html.EndTagToken && t.Data == "nav" && a.nav > 0 {
+		a.nav--
+	}
+	if t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken {
+		return
+	}
+	switch {
+	case deprecatedTags[t.Data]:
+		a.add("lint-deprecated-tag", "<"+t.Data+">")
+	case t.Data == "frameset" || t.Data == "frame":
+		a.add("lint-frames", "<"+t.Data+">")
+	case t.Data == "nav" && t.Type == html.StartTagToken:
+		a.nav++
+	case t.Data == "a":
+		href, _ := attr(t, "href")
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(href)), "javascript:") {
+			where := "link"
+			if a.nav > 0 {
+				where = "navigation link"
+			}
+			a.add("lint-javascript-link", where+" "+href)
+		}
+		if target, _ := attr(t, "target"); strings.EqualFold(target, "_blank") && href != "" && !opensSafely(t) {
+			a.add("lint-target-blank", href)
+		}
+	case t.Data == "meta":
+		name, _ := attr(t, "name")
+		equiv, _ := attr(t, "http-equiv")
+		switch {
+		case strings.EqualFold(name, "keywords"):
+			a.add("lint-meta-keywords", "")
+		case strings.EqualFold(equiv, "refresh"):
+			content, _ := attr(t, "content")
+			a.add("lint-meta-refresh", content)
+		}
+	case t.Data == "embed" || t.Data == "object":
+		typ, _ := attr(t, "type")
+		if strings.Contains(strings.ToLower(typ), "flash") {
+			a.add("lint-flash", "<"+t.Data+">")
+		}
+	}
+}
+
+// opensSafely returns true if the link t opens without giving the
+// new page access to its opener through window.opener.
+func opensSafely(t *html.Token) bool {
+	rel, _ := attr(t, "rel")
+	for _, r := range strings.Fields(strings.ToLower(rel)) {
+		if r == "noopener" || r == "noreferrer" {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *lintAnalyzer) finish(res *result) {
+	res.Lint = a.issues
+}
+
+// checkLint adds the findings of lintAnalyzer, one per page and
+// kind.
+func checkLint(rep *report) {
+	for _, res := range rep.sorted() {
+		if res.page() {
+			rep.addIssues(res.URL, res.Lint)
+		}
+	}
+}
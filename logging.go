@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// parseLevel returns the slog level called name.
+func parseLevel(name string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToUpper(name))); err != nil {
+		return l, fmt.Errorf("unknown log level %s", name)
+	}
+	return l, nil
+}
+
+// setupLogging makes the default logger write records of at
+// least level to w, formatted as text or JSON.
+func setupLogging(w io.Writer, format, level string) error {
+	l, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	hopts := &slog.HandlerOptions{Level: l}
+	var h slog.Handler
+	switch format {
+	case "text":
+		h = slog.NewTextHandler(w, hopts)
+	case "json":
+		h = slog.NewJSONHandler(w, hopts)
+	default:
+		return fmt.Errorf("unknown log format %s", format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// fatal logs msg and err with args as attributes and exits.
+func fatal(msg string, err error, args ...interface{}) {
+	slog.Error(msg, append([]interface{}{"err", err}, args...)...)
+	os.Exit(1)
+}
+
+// setupErrorLog sends warnings and errors, and nothing else,
+// as JSON records to the file name, or stderr if name is "-".
+// This keeps stdout for results only and makes problems easy
+// to process in scripts.
+func setupErrorLog(name string) error {
+	w := os.Stderr
+	if name != "-" {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		w = f
+	}
+	return setupLogging(w, "json", "warn")
+}
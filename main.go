@@ -2,238 +2,568 @@ package main
 
 import (
 	"bytes"
-	"errors"
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
+	"log/slog"
+	"math/rand"
+	"mime"
 	"net/http"
+	"net/http/httptrace"
 	nurl "net/url"
-	"path"
-
-	"golang.org/x/net/html"
-)
-
-var (
-	bodyTag  = []byte("body")
-	hrefAttr = []byte("href")
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
-type pfn func() (pfn, error)
-
-// TODO: implement a system where several state machines receive
-//       incoming tokens and emit results to be give to crawler.done()
-//       This way several checks can be done with one single tokenization pass.
-//       For efficiency, ask each state machine if they are interested in a tag,
-//       if any is, parse the full tag with attributes and deliver it.
-
-type page struct {
-	r    io.Reader
-	url  *nurl.URL
-	tok  *html.Tokenizer
-	urls []string
+// fetcher performs HTTP requests on behalf of the workers,
+// adding authentication and extra headers and keeping the
+// request rate under the configured limit.
+type fetcher struct {
+	client   *http.Client
+	direct   *http.Client // does not follow redirects
+	user     string
+	password string
+	headers  http.Header
+	tick     <-chan time.Time
+	// transport sends the requests, once passed through mws;
+	// nil for the default transport.
+	transport http.RoundTripper
+	mws       []middleware
+	// budget, if set, limits the bytes read from responses.
+	budget *byteBudget
 }
 
-func newPage(r io.Reader, url *nurl.URL) *page {
-	return &page{
-		r:    r,
-		url:  url,
-		tok:  html.NewTokenizer(r),
-		urls: make([]string, 0),
+func newFetcher(user, password string, headers, perHost []string, rate float64) (*fetcher, error) {
+	f := &fetcher{
+		user:     user,
+		password: password,
+		headers:  make(http.Header),
 	}
-}
-
-func (p *page) normalize(surl string) (string, error) {
-	url, err := nurl.Parse(surl)
-	if err != nil {
-		return "", err
-	}
-	// Ignore links to other domains
-	// TODO: be more lax about 80 and 443 with right scheme
-	if url.Host != "" && url.Host != p.url.Host {
-		return "", nil
-	}
-	url.Host = p.url.Host
-	if url.Scheme != "" {
-		// Skip unhandled schemes
-		if url.Scheme != "http" || url.Scheme != "https" {
-			return "", nil
-		}
-		if url.Scheme != p.url.Scheme {
-			return "", fmt.Errorf("schema is %s, it was %s", url.Scheme, p.url.Scheme)
+	f.setClient(http.DefaultClient)
+	for _, h := range headers {
+		name, val, err := parseHeader(h)
+		if err != nil {
+			return nil, err
 		}
+		f.headers.Add(name, val)
 	}
-	url.Scheme = p.url.Scheme
-	// Opaque: ignored
-	// User: ignored
-	if url.Path[0] != '/' {
-		url.Path = p.url.Path + url.Path
-	}
-	url.Path = path.Clean(url.Path)
-	if url.Path == "/" {
-		url.Path = ""
+	if len(perHost) > 0 {
+		mw, err := hostHeaders(perHost)
+		if err != nil {
+			return nil, err
+		}
+		f.use(mw)
 	}
-	url.Fragment = ""
-	surl = url.String()
-	// Skip internal link, only fragment
-	if surl[0] == '#' {
-		return "", nil
+	if rate > 0 {
+		f.tick = time.NewTicker(time.Duration(float64(time.Second) / rate)).C
 	}
-	return surl, nil
+	return f, nil
 }
 
-func (p *page) findBody() (pfn, error) {
-	for {
-		tt := p.tok.Next()
-		if tt == html.ErrorToken {
-			break
-		}
-		if tt != html.StartTagToken {
-			continue
-		}
-		tn, _ := p.tok.TagName()
-		if bytes.Compare(tn, bodyTag) == 0 {
-			return p.findAnchor, nil
-		}
-	}
-	err := p.tok.Err()
-	if err == io.EOF {
-		return nil, errors.New("body not found")
+// setClient makes f send its requests through client, after
+// the middlewares of f. Its redirect policy is replaced by that
+// of the fetcher.
+func (f *fetcher) setClient(client *http.Client) {
+	f.transport = client.Transport
+	c := *client
+	c.Transport = f.wrap(f.transport)
+	f.client = &c
+	direct := c
+	direct.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
 	}
-	return nil, err
+	f.direct = &direct
 }
 
-func (p *page) findAnchor() (pfn, error) {
-	for {
-		tt := p.tok.Next()
-		if tt == html.ErrorToken {
-			break
-		}
-		if tt != html.StartTagToken {
-			continue
+// setTransport makes f send its requests through rt, after its
+// middlewares, keeping the other settings of its client.
+func (f *fetcher) setTransport(rt http.RoundTripper) {
+	client := *f.client
+	client.Transport = rt
+	f.setClient(&client)
+}
+
+// response is what the server replied for a URL.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+	// redirects followed to get to the response, in order.
+	redirects []redirect
+	// elapsed is the time from the request to the end of the body.
+	elapsed time.Duration
+	// proto is the protocol of the response, like "HTTP/2.0",
+	// and remote the address of the server that sent it.
+	proto  string
+	remote string
+	// wire is the size of the body as sent, encoded as encoding.
+	wire     int
+	encoding string
+}
+
+// get performs a GET request for URL url and reads the
+// full body in memory.
+func (f *fetcher) get(ctx context.Context, url string) (*response, error) {
+	var hops []redirect
+	client := *f.client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
 		}
-		tn, hasAttrs := p.tok.TagName()
-		if len(tn) != 1 || tn[0] != 'a' || !hasAttrs {
-			continue
-		}
-		var (
-			key, val []byte
-			more     bool = true
-		)
-		for more {
-			key, val, more = p.tok.TagAttr()
-			if bytes.Compare(key, hrefAttr) != 0 {
-				continue
-			}
-			ourl := string(val)
-			url, err := p.normalize(ourl)
-			if err != nil {
-				log.Printf("html parser: cannot handle link %s: %s", ourl, err)
-				continue
-			}
-			if url != "" {
-				p.urls = append(p.urls, url)
-			}
+		hops = append(hops, redirect{
+			From:   via[len(via)-1].URL.String(),
+			To:     req.URL.String(),
+			Status: req.Response.StatusCode,
+		})
+		// Do not fetch other sites as if they were part of this one.
+		if h := via[0].URL.Host; req.URL.Host != h && req.URL.Host != aliasHost(h) {
+			return http.ErrUseLastResponse
 		}
+		return nil
 	}
-	err := p.tok.Err()
-	if err == io.EOF {
-		return nil, nil
+	resp, err := f.do(ctx, &client, "GET", url)
+	if err != nil {
+		return nil, err
 	}
-	return nil, err
+	resp.redirects = hops
+	return resp, nil
 }
 
-func (p *page) parse() error {
-	f := p.findBody
-	for {
-		var err error
-		f, err = f()
-		if err != nil {
-			return fmt.Errorf("cannot parse HTML: %s", err)
-		}
-		if f == nil {
-			break
-		}
-	}
-	return nil
+// getDirect is like get, but returns redirects instead of
+// following them.
+func (f *fetcher) getDirect(ctx context.Context, url string) (*response, error) {
+	return f.do(ctx, f.direct, "GET", url)
+}
+
+// head performs a HEAD request for URL url, following redirects.
+func (f *fetcher) head(ctx context.Context, url string) (*response, error) {
+	return f.do(ctx, f.client, "HEAD", url)
 }
 
-// httpBodyReader performs a GET request for URL url and
-// reads the full body in memory, returning a reader for
-// the memory buffer.
-func httpBodyReader(url string) (io.Reader, error) {
-	resp, err := http.Get(url)
+func (f *fetcher) do(ctx context.Context, client *http.Client, method, url string) (*response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("cannot GET from HTTP: %s", err)
+		return nil, fmt.Errorf("cannot %s from HTTP: %s", method, err)
+	}
+	for name, vals := range f.headers {
+		req.Header[name] = vals
+	}
+	if f.user != "" {
+		req.SetBasicAuth(f.user, f.password)
+	}
+	// Ask for gzip ourselves, so that the transport leaves the
+	// body as sent and the size on the wire is known.
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	if f.tick != nil {
+		select {
+		case <-f.tick:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	var remote string
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			remote = info.Conn.RemoteAddr().String()
+		},
+	}))
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot %s from HTTP: %s", method, err)
 	}
 	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+	var r io.Reader = resp.Body
+	if f.budget != nil {
+		r = &budgetReader{ctx: ctx, r: r, b: f.budget}
+	}
+	body, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read from HTTP: %s", err)
 	}
-	return bytes.NewReader(body), nil
+	wire := len(body)
+	encoding := strings.ToLower(resp.Header.Get("Content-Encoding"))
+	if encoding == "gzip" || encoding == "x-gzip" {
+		if body, err = gunzip(body); err != nil {
+			return nil, fmt.Errorf("cannot decompress HTTP body: %s", err)
+		}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+	}
+	return &response{
+		status:   resp.StatusCode,
+		header:   resp.Header,
+		body:     body,
+		proto:    resp.Proto,
+		remote:   remote,
+		wire:     wire,
+		encoding: encoding,
+		elapsed:  time.Since(start),
+	}, nil
+}
+
+// result holds everything known about a single crawled URL.
+type result struct {
+	URL    string
+	Status int
+	Depth  int
+	// Robots is the content of the robots meta tag, XRobotsTag
+	// the value of the X-Robots-Tag header.
+	Robots      string
+	XRobotsTag  string
+	Title       string
+	Titles      []string // all title elements, first is Title
+	Description string
+	Lang        string
+	Hreflangs   []hreflang
+	// Published is the publication date of an article and
+	// Headline its title, if the page declares them.
+	Published  time.Time
+	Headline   string
+	Canonical  string
+	Canonicals []string
+	Metas      []meta
+	Headings   []heading
+	Images     []image
+	Resources  []resource
+	Frames     []string
+	Icons      []icon
+	// A11y are the accessibility problems found on the page.
+	A11y []finding
+	// Lint are outdated practices found on the page.
+	Lint []finding
+	// Mobile are what makes the page hard to use on phones.
+	Mobile []finding
+	// Tags are the analytics tags and tracking pixels loaded.
+	Tags []tag
+	// DOM measures the markup of the page.
+	DOM *domStats
+	// Markup are the places where the HTML is broken.
+	Markup []finding
+	// Breadcrumbs are the trails of the page, nil if none.
+	Breadcrumbs *breadcrumbs
+	// Structured are the problems of the JSON-LD of the page.
+	Structured []finding
+	// RichResults are the rich results the page declares.
+	RichResults []richResult
+	// Misspelled are the words of the text not in the dictionary.
+	Misspelled  []string
+	Readability *readability
+	// Terms are the most frequent words and phrases of the text.
+	Terms  []term
+	Videos []video
+	// IDs are the ids and anchor names that fragments can target.
+	IDs       []string
+	Anchors   []anchor
+	Err       error
+	Links     []string
+	External  []string
+	Redirects []redirect
+	// Hash is the SHA-1 of the body, to spot identical pages,
+	// ContentHash that of its main content, if long enough.
+	Hash        string
+	ContentHash string
+	// Size is the length of the body and Elapsed the time it
+	// took to download it, zero when it was not fetched.
+	Size    int
+	Elapsed time.Duration
+	// ContentType is as sent; WireSize is the size of the body
+	// before undoing its Content-Encoding, Encoding.
+	ContentType string
+	WireSize    int
+	Encoding    string
+	// Proto is the HTTP version the page was fetched with and
+	// RemoteAddr the address of the server.
+	Proto      string
+	RemoteAddr string
+	// LastModified is the Last-Modified header, as sent.
+	LastModified string
+	// CSP are the Content-Security-Policy headers, one per policy.
+	CSP      []string
+	CrUX     *cruxRecord
+	GSC      *gscData
+	Findings []finding
 }
 
 func newWorkers(n int, c *crawler) chan<- string {
 	ch := make(chan string, n)
 	for i := 0; i < n; i++ {
-		go worker(ch, c)
+		go worker(i, ch, c)
 	}
 	return ch
 }
 
 // worker consumes URLs from channel ch and parses them,
 // calling back the crawler to signal completion with done().
-func worker(ch <-chan string, c *crawler) {
+func worker(id int, ch <-chan string, c *crawler) {
+	wlog := slog.With("worker", id)
 	for url := range ch {
-		r, err := httpBodyReader(url)
+		l := wlog.With("url", url)
+		l.Debug("fetching page")
+		res := &result{URL: url}
+		resp, err := c.fetch.get(c.ctx, url)
 		if err != nil {
-			log.Printf("worker error: http: %s", err)
-			c.done(nil)
+			l.Error("cannot fetch page", "err", err)
+			res.Err = err
+			c.done(res)
 			continue
 		}
-		p := newPage(r, c.baseurl)
-		if err := p.parse(); err != nil {
-			log.Printf("worker error: parser: %s", err)
-			c.done(nil)
-			continue
+		if c.archive != nil {
+			if err := c.archive.store(url, resp); err != nil {
+				l.Error("cannot archive page", "err", err)
+			}
+		}
+		extra := make([]analyzer, len(c.analyzers))
+		for i, newAnalyzer := range c.analyzers {
+			extra[i] = newAnalyzer()
 		}
-		c.done(p.urls)
+		if err := analyze(res, resp, l, extra...); err != nil {
+			l.Error("cannot parse page", "err", err)
+			res.Err = err
+		}
+		c.done(res)
 	}
 }
 
-type crawler struct {
-	// TODO: string should be only the unique part of the URL. bool should be nil or struct(result)
-	urls     map[string]bool
-	fn       chan func() error
-	fin      chan struct{}
-	workers  chan<- string
-	baseurl  *nurl.URL
-	nworkers int
-	nbusy    int
-	base     string
-	hasWork  bool
-}
-
-func newCrawler(base string, nworkers int) (*crawler, error) {
-	burl, err := nurl.Parse(base)
+// analyze fills res with the data extracted from resp,
+// the response fetched for res.URL, running the extra
+// analyzers after the built-in ones.
+func analyze(res *result, resp *response, log *slog.Logger, extra ...analyzer) error {
+	res.Status = resp.status
+	res.Proto = resp.proto
+	res.RemoteAddr = resp.remote
+	res.Elapsed = resp.elapsed
+	res.Redirects = resp.redirects
+	// A redirect is only recorded as such: the body belongs to
+	// the URL it ends at, which is crawled as a page of its own.
+	if n := len(resp.redirects); n > 0 {
+		res.Status = resp.redirects[0].Status
+		res.Links = []string{resp.redirects[n-1].To}
+		return nil
+	}
+	res.XRobotsTag = strings.Join(resp.header.Values("X-Robots-Tag"), ", ")
+	res.Hash = contentHash(res.URL, resp.body)
+	res.Size = len(resp.body)
+	res.WireSize = resp.wire
+	res.Encoding = resp.encoding
+	res.ContentType = resp.header.Get("Content-Type")
+	res.LastModified = resp.header.Get("Last-Modified")
+	for _, v := range resp.header.Values("Content-Security-Policy") {
+		res.CSP = append(res.CSP, strings.Split(v, ",")...)
+	}
+	if !isHTML(res.ContentType) {
+		return nil
+	}
+	// Without redirects, the body is that of res.URL, so links
+	// resolve against it.
+	u, err := nurl.Parse(res.URL)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	p := newPage(bytes.NewReader(resp.body), u, log)
+	p.analyzers = append(p.analyzers, extra...)
+	if err := p.parse(); err != nil {
+		return err
 	}
+	p.finish(res)
+	return nil
+}
+
+// isHTML returns true if ctype, a Content-Type header, is that of
+// an HTML page. Pages sent without one are taken as HTML.
+func isHTML(ctype string) bool {
+	if strings.TrimSpace(ctype) == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return false
+	}
+	return mt == "text/html" || mt == "application/xhtml+xml"
+}
+
+// loaded returns true if res was fetched with a 200 status and
+// without redirects.
+func (res *result) loaded() bool {
+	return res.Err == nil && res.Status == http.StatusOK && len(res.Redirects) == 0
+}
+
+// page returns true if res is an HTML page that loaded, the only
+// results on-page checks look at.
+func (res *result) page() bool {
+	return res.loaded() && isHTML(res.ContentType)
+}
+
+type crawler struct {
+	// TODO: string should be only the unique part of the URL. bool should be nil or struct(result)
+	urls    map[string]bool
+	depths  map[string]int
+	results map[string]*result
+	fn      chan func() error
+	fin     chan struct{}
+	workers chan<- string
+	baseurl *nurl.URL
+	fetch   *fetcher
+	filter  *urlFilter
+	archive *bodyArchive
+	traps   *trapDetector
+	sample  *sampler
+	pages   *pageBudget
+	// analyzers make the optional analyzers for each page.
+	analyzers []func() analyzer
+	// normalizers rewrite discovered URLs, the first strips the
+	// query parameters in strip; params, if set, adds session
+	// parameters to it.
+	normalizers []normalizer
+	strip       map[string]bool
+	params      *paramDetector
+	nworkers    int
+	nbusy       int
+	nerrors     int
+	base        string
+	hasWork     bool
+	stopping    bool
+	// truncated is why a budget ended the crawl early, if it did.
+	truncated string
+	paused    bool
+	// strategy is the key in strategies of the crawl order.
+	strategy string
+	// queue holds the URLs to visit once the crawl has started.
+	queue *frontier
+	// rand orders the hosts when crawling more than one.
+	rand *rand.Rand
+	// priorities schedule the URLs they rank higher first.
+	priorities []*priority
+	// emit, if set, is called with each result as it arrives.
+	emit   func(*result)
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// now returns the time recorded in reports and exports;
+// it is fixed in deterministic mode.
+var now = time.Now
+
+// newCrawler prepares a crawl from seeds; the first seed is
+// the base URL that decides which host is crawled. Crawling
+// begins with start and stops, as with stop, when ctx is done;
+// its values are seen by all requests.
+func newCrawler(ctx context.Context, seeds []string, nworkers int, fetch *fetcher, filter *urlFilter) (*crawler, error) {
 	c := &crawler{
-		base:     base,
 		nworkers: nworkers,
-		baseurl:  burl,
+		fetch:    fetch,
+		filter:   filter,
 		urls:     make(map[string]bool),
+		strip:    make(map[string]bool),
+		depths:   make(map[string]int),
+		results:  make(map[string]*result),
 		fn:       make(chan func() error),
+		strategy: "bfs",
+		rand:     rand.New(rand.NewSource(now().UnixNano())),
 		fin:      make(chan struct{}),
 	}
-	c.workers = newWorkers(nworkers, c)
-	c.urls[base] = false
+	c.normalizers = []normalizer{normalizerFunc(rootPath), paramStripper(c.strip)}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	for _, seed := range seeds {
+		c.urls[seed] = false
+	}
+	if err := c.normalizeSeeds(seeds[0]); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// start launches the workers and begins crawling.
+func (c *crawler) start() {
+	c.queue = newFrontier(strategies[c.strategy], c.rand)
+	for url, scheduled := range c.urls {
+		if !scheduled {
+			c.enqueue(url)
+		}
+	}
+	c.workers = newWorkers(c.nworkers, c)
 	go c.run()
+	// Also wake up an idle, paused crawl when the context ends.
+	go func() {
+		select {
+		case <-c.ctx.Done():
+			c.do(func() {
+				c.stopping = true
+				c.hasWork = false
+			})
+		case <-c.fin:
+		}
+	}()
 	c.fn <- c.sched
-	return c, nil
+}
+
+// do runs f synchronized with the crawler, unless the crawl
+// is already over, in which case it returns false.
+func (c *crawler) do(f func()) bool {
+	done := make(chan struct{})
+	select {
+	case c.fn <- func() error {
+		f()
+		close(done)
+		return nil
+	}:
+		<-done
+		return true
+	case <-c.fin:
+		return false
+	}
+}
+
+// stop makes the crawler stop scheduling new pages. Fetches
+// in flight are cancelled if they do not complete within grace.
+func (c *crawler) stop(grace time.Duration) {
+	if c.do(func() { c.stopping = true }) {
+		time.AfterFunc(grace, c.cancel)
+	}
+}
+
+// truncate makes the crawler stop scheduling new pages because
+// a budget ran out, noting reason. Fetches in flight complete.
+func (c *crawler) truncate(reason string) {
+	c.do(func() {
+		if c.truncated == "" {
+			c.truncated = reason
+		}
+	})
+}
+
+// pending returns how many discovered URLs were not crawled.
+func (c *crawler) pending() int {
+	n := 0
+	for _, scheduled := range c.urls {
+		if !scheduled {
+			n++
+		}
+	}
+	return n
+}
+
+// pause makes the crawler stop scheduling new pages until
+// resume is called. Fetches in flight complete and the
+// links they found are queued.
+func (c *crawler) pause() {
+	c.do(func() { c.paused = true })
+}
+
+// resume continues a paused crawl.
+func (c *crawler) resume() {
+	c.do(func() {
+		c.paused = false
+		c.hasWork = true
+	})
 }
 
 // wait returns when the crawler has no more work to carry out.
@@ -244,37 +574,91 @@ func (c *crawler) wait() {
 // sched schedules work to free workers until they are all
 // busy or work has run out.
 func (c *crawler) sched() error {
-	var hasWork bool
-	for url, done := range c.urls {
-		if done {
+	if c.ctx.Err() != nil {
+		c.stopping = true
+	}
+	if c.stopping || c.truncated != "" {
+		c.hasWork = false
+		return nil
+	}
+	if c.paused {
+		return nil
+	}
+	for c.nbusy < c.nworkers {
+		url, ok := c.queue.pop()
+		if !ok {
+			break
+		}
+		if c.pages != nil && !c.pages.allow(url) {
 			continue
 		}
-		hasWork = true
 		c.urls[url] = true
 		c.nbusy++
 		c.workers <- url
-		if c.nbusy >= c.nworkers {
-			break
+	}
+	// Once all else is crawled, the URLs held back because their
+	// section used its share get what other sections left.
+	if c.pages != nil && c.nbusy == 0 && c.queue.len() == 0 {
+		if urls := c.pages.release(); len(urls) > 0 {
+			for _, url := range urls {
+				c.enqueue(url)
+			}
+			return c.sched()
 		}
 	}
-	c.hasWork = hasWork
+	c.hasWork = c.queue.len() > 0 || c.pages != nil && c.pages.waiting()
 	return nil
 }
 
-// done marks a worker as free and ingests the URLs
-// that were extracted from a page.
+// done marks a worker as free, stores the result of a
+// page and ingests the URLs that were extracted from it.
 //
 // done must be always called after each task a worker
 // performs.
 //
 // done can be called from other go routines
-func (c *crawler) done(urls []string) {
+func (c *crawler) done(res *result) {
 	c.fn <- func() error {
 		c.nbusy--
-		for _, url := range urls {
+		// Cancelled on shutdown, the page is still to be visited.
+		if res.Err != nil && c.ctx.Err() != nil {
+			c.enqueue(res.URL)
+			return nil
+		}
+		res.Depth = c.depths[res.URL]
+		c.results[res.URL] = res
+		if res.Err != nil {
+			c.nerrors++
+		}
+		if c.emit != nil {
+			c.emit(res)
+		}
+		if c.params != nil {
+			c.learnParams(res)
+		}
+		if b := c.fetch.budget; b != nil && c.truncated == "" && b.exceeded() {
+			slog.Warn("byte budget reached, waiting for pages in flight", "max-bytes", b.max)
+			c.truncated = fmt.Sprintf("byte budget of %d bytes reached", b.max)
+		}
+		for i, url := range res.Links {
+			url = c.normalize(url)
+			res.Links[i] = url
+			if !c.filter.allow(url) {
+				continue
+			}
 			if _, ok := c.urls[url]; !ok {
-				c.urls[url] = false
+				if c.traps != nil && !c.traps.allow(url) {
+					continue
+				}
+				if c.sample != nil && !c.sample.allow(url) {
+					continue
+				}
+				c.depths[url] = res.Depth + 1
+				c.enqueue(url)
 				c.hasWork = true
+			} else if d, ok := c.depths[url]; !ok || d > res.Depth+1 {
+				c.depths[url] = res.Depth + 1
+				c.queue.fix(url, res.Depth+1)
 			}
 		}
 		return nil
@@ -287,30 +671,638 @@ func (c *crawler) done(urls []string) {
 func (c *crawler) run() {
 	for fn := range c.fn {
 		if err := fn(); err != nil {
-			log.Printf("crawler error: %s", err)
+			slog.Error("crawler error", "err", err)
+		}
+		if c.hasWork {
+			c.sched()
 		}
 		// No more work and no results to wait for, exit.
 		if !c.hasWork && c.nbusy == 0 {
 			break
 		}
-		if c.hasWork {
-			c.sched()
-		}
 	}
 	close(c.workers)
+	c.cancel()
 	close(c.fin)
 }
 
 func main() {
-	// TODO: as real flag
-	nworkers := 4
+	opts := newOptions(flag.CommandLine)
 	flag.Parse()
-	c, err := newCrawler(flag.Arg(0), nworkers)
+	if flag.Arg(0) == "config" {
+		os.Exit(configCmd(flag.Args()[1:]))
+	}
+	if opts.config != "" {
+		if err := loadConfig(opts.config, flag.CommandLine); err != nil {
+			fatal("cannot load configuration", err)
+		}
+	}
+	if opts.errorLog != "" {
+		opts.quiet = true
+		if err := setupErrorLog(opts.errorLog); err != nil {
+			fatal("cannot setup error log", err)
+		}
+	} else if err := setupLogging(os.Stderr, opts.logFormat, opts.logLevel); err != nil {
+		fatal("cannot setup logging", err)
+	}
+	if opts.deterministic {
+		now = func() time.Time { return time.Unix(0, 0).UTC() }
+		opts.workers = 1
+		if opts.sortBy == "" && !opts.stream {
+			opts.sortBy = "url"
+		}
+	}
+	switch flag.Arg(0) {
+	case "audit":
+		os.Exit(auditCmd(opts, flag.Args()[1:]))
+	case "robots":
+		os.Exit(robotsCmd(opts, flag.Args()[1:]))
+	case "compare":
+		os.Exit(compareCmd(opts, flag.Args()[1:]))
+	case "reanalyze":
+		os.Exit(reanalyzeCmd(opts, flag.Args()[1:]))
+	case "bench":
+		os.Exit(benchCmd(opts, flag.Args()[1:]))
+	case "trends":
+		os.Exit(trendsCmd(opts, flag.Args()[1:]))
+	case "migrate":
+		os.Exit(migrateCmd(opts, flag.Args()[1:]))
+	case "redirect-map":
+		os.Exit(redirectMapCmd(opts, flag.Args()[1:]))
+	case "freshness":
+		os.Exit(freshnessCmd(opts, flag.Args()[1:]))
+	case "monitor":
+		os.Exit(monitorCmd(opts, flag.Args()[1:]))
+	}
+	opts.seeds = append(opts.seeds, flag.Args()...)
+	if err := opts.validate(); err != nil {
+		fatal("invalid options", err)
+	}
+	ci, err := newCIThresholds(opts.ciMax5xx, opts.ciMaxBroken, opts.ciNoindex)
+	if err != nil {
+		fatal("invalid CI thresholds", err)
+	}
+	ci.regressions = opts.ciRegressions
+	fetch, err := optionsFetcher(opts)
+	if err != nil {
+		fatal("cannot start fetcher", err)
+	}
+	filter, err := newURLFilter(opts.include, opts.exclude)
+	if err != nil {
+		fatal("cannot compile filters", err)
+	}
+	var cp *checkpoint
+	if opts.resume != "" {
+		if cp, err = loadCheckpoint(opts.resume); err != nil {
+			fatal("cannot load checkpoint", err)
+		}
+		opts.seeds = append(cp.Seeds, opts.seeds...)
+	}
+	st, err := openStorage(opts.output)
+	if err != nil {
+		fatal("cannot open output", err, "output", opts.output)
+	}
+	// ctx is cancelled by a second interrupt, ending the crawl and
+	// the requests made after it.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := newCrawler(ctx, opts.seeds, opts.workers, fetch, filter)
 	if err != nil {
-		log.Fatalf("cannot start crawler: %s", err)
+		fatal("cannot start crawler", err)
+	}
+	if cp != nil {
+		c.restore(cp)
+	}
+	if err := c.configure(opts); err != nil {
+		fatal("cannot set up crawler", err)
+	}
+	if opts.archive {
+		c.archive = newBodyArchive(st, opts.archiveGzip)
+	}
+	if opts.stream {
+		c.emit = func(res *result) {
+			if err := writeResult(os.Stdout, "", res); err != nil {
+				slog.Error("cannot write result", "url", res.URL, "err", err)
+			}
+		}
+	}
+	var notFound *errorPage
+	if opts.check404 {
+		if notFound, err = probeNotFound(ctx, fetch, c.baseurl); err != nil {
+			slog.Warn("cannot request a missing page", "err", err)
+		}
+	}
+	var cookies *cookieLog
+	if opts.cookies {
+		cookies = newCookieLog()
+		fetch.use(cookies.middleware())
+	}
+	c.start()
+	if opts.maxDuration > 0 {
+		budget := time.AfterFunc(opts.maxDuration, func() {
+			slog.Warn("duration budget reached, waiting for pages in flight", "max-duration", opts.maxDuration)
+			c.truncate(fmt.Sprintf("duration budget of %s reached", opts.maxDuration))
+		})
+		defer budget.Stop()
+	}
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		pauses := make(chan os.Signal, 1)
+		signal.Notify(pauses, syscall.SIGUSR1)
+		var paused bool
+		for range pauses {
+			paused = !paused
+			if paused {
+				c.pause()
+				slog.Warn("crawl paused, send SIGUSR1 again to resume")
+			} else {
+				c.resume()
+				slog.Warn("crawl resumed")
+			}
+		}
+	}()
+	go func() {
+		<-sigs
+		slog.Warn("interrupted, waiting for pages in flight", "grace", opts.grace)
+		c.stop(opts.grace)
+		<-sigs
+		// A third interrupt kills the process.
+		signal.Stop(sigs)
+		cancel()
+	}()
+	var shown <-chan struct{}
+	if !opts.quiet {
+		shown = showProgress(c, os.Stderr)
 	}
 	c.wait()
-	for url := range c.urls {
-		fmt.Printf("%s\n", url)
+	if shown != nil {
+		<-shown
+	}
+	if c.archive != nil {
+		if err := c.archive.close(); err != nil {
+			slog.Error("cannot write archive index", "err", err)
+		}
+	}
+	rep := newReport(c.base, c.results)
+	rep.interrupted = c.stopping
+	if c.truncated != "" {
+		rep.truncated = c.truncated
+		rep.add("crawl-truncated", c.base, fmt.Sprintf("%s, %d URLs not crawled", c.truncated, c.pending()))
+	}
+	if c.traps != nil {
+		c.traps.report(rep)
+	}
+	if c.sample != nil {
+		rep.sampled = true
+		c.sample.report(rep)
+	}
+	if c.pages != nil {
+		c.pages.report(rep)
+		if rep.truncated == "" && c.pages.skipped() > 0 {
+			rep.truncated = fmt.Sprintf("page budget of %d pages reached", c.pages.max)
+		}
+	}
+	if notFound != nil {
+		checkNotFound(rep, notFound)
+	}
+	// An interrupted crawl is saved as soon as possible,
+	// without the checks that make more requests.
+	if opts.checkRobots && !c.stopping {
+		rt, _, err := fetchRobots(ctx, fetch, c.baseurl)
+		if err != nil {
+			slog.Error("cannot fetch robots.txt", "err", err)
+		} else {
+			checkDisallowedLinks(rep, rt, opts.robotsAgent)
+		}
+		blocked := checkBlockedResources(ctx, fetch, rep, opts.robotsAgent)
+		if opts.mobileFriendly {
+			checkMobileResources(rep, blocked)
+		}
+	}
+	if opts.checkIcons && !c.stopping {
+		checkIcons(ctx, fetch, rep)
+	}
+	if cookies != nil {
+		rep.cookies = cookies.list()
+	}
+	if (opts.indexability || opts.checkLastmod) && !c.stopping {
+		rep.signals = fetchIndexSignals(ctx, fetch, c.baseurl, opts.robotsAgent)
+	}
+	if opts.checkCompression && !c.stopping {
+		checkCompression(ctx, fetch, rep)
+	}
+	if opts.checkCerts && !c.stopping {
+		rep.certs = checkCertificates(ctx, fetch, rep, opts.certWarn)
+	}
+	if opts.documents {
+		rep.docs = rep.documents()
+		if opts.headDocuments && !c.stopping {
+			headDocuments(ctx, fetch, rep.docs)
+		}
+	}
+	if opts.renderBlocking && !c.stopping {
+		checkRenderBlocking(ctx, fetch, rep)
+	}
+	if opts.checkMobile && !c.stopping {
+		checkMobile(ctx, fetch, rep)
+	}
+	if opts.checkAliases && !c.stopping {
+		checkAliases(ctx, fetch, rep, opts.aliasSample)
+	}
+	pass := publish(opts, rep, st, ci)
+	if c.stopping {
+		data, err := json.MarshalIndent(c.checkpoint(), "", "  ")
+		if err != nil {
+			fatal("cannot encode checkpoint", err)
+		}
+		if err := st.put(checkpointName, data); err != nil {
+			fatal("cannot store checkpoint", err)
+		}
+		slog.Warn("crawl interrupted, checkpoint written", "checkpoint", checkpointName)
+		os.Exit(exitInterrupted)
+	}
+	if !pass {
+		os.Exit(exitCIFailed)
+	}
+}
+
+// optionsFetcher returns a fetcher set up as opts say.
+func optionsFetcher(opts *options) (*fetcher, error) {
+	fetch, err := newFetcher(opts.authUser, opts.authPassword, opts.headers, opts.hostHeaders, opts.rate)
+	if err != nil {
+		return nil, err
+	}
+	if err := fetch.setProtocol(opts.httpVersion); err != nil {
+		return nil, fmt.Errorf("cannot set HTTP version: %s", err)
+	}
+	if err := fetch.setDialing(opts.ipFamily, opts.sourceIPs); err != nil {
+		return nil, fmt.Errorf("cannot set up connections: %s", err)
+	}
+	if opts.checkMobile && fetch.headers.Get("User-Agent") == "" {
+		fetch.headers.Set("User-Agent", desktopUserAgent)
+	}
+	if opts.maxBandwidth > 0 || opts.maxBytes > 0 {
+		fetch.budget = newByteBudget(opts.maxBandwidth, opts.maxBytes)
+	}
+	if opts.record != "" {
+		if err := os.MkdirAll(opts.record, 0755); err != nil {
+			return nil, fmt.Errorf("cannot create record directory: %s", err)
+		}
+		fetch.use(recorder(opts.record))
+	}
+	if opts.replay != "" {
+		fetch.use(replayer(opts.replay))
+	}
+	return fetch, nil
+}
+
+// configure sets the crawl order, analyzers, URL normalization
+// and limits of c from opts. It must be called before start.
+func (c *crawler) configure(opts *options) error {
+	var err error
+	c.strategy = opts.strategy
+	if c.analyzers, err = pageAnalyzers(opts); err != nil {
+		return fmt.Errorf("cannot set up analyzers: %s", err)
+	}
+	for _, p := range opts.stripParams {
+		c.strip[p] = true
+	}
+	for _, r := range opts.rewrites {
+		rule, err := newRewriteRule(r)
+		if err != nil {
+			return err
+		}
+		c.normalizers = append(c.normalizers, rule)
+	}
+	if err := c.normalizeSeeds(c.base); err != nil {
+		return fmt.Errorf("invalid base URL: %s", err)
+	}
+	for _, p := range opts.priorities {
+		prio, err := newPriority(p)
+		if err != nil {
+			return err
+		}
+		c.priorities = append(c.priorities, prio)
+	}
+	if opts.autoStrip {
+		c.params = newParamDetector()
+	}
+	if opts.trapLimit > 0 {
+		c.traps = newTrapDetector(opts.trapLimit)
+	}
+	if opts.sample > 0 {
+		c.sample = newSampler(opts.sample)
+	}
+	if opts.maxPages > 0 {
+		if c.pages, err = newPageBudget(opts.maxPages, opts.sectionBudgets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pageAnalyzers returns constructors for the optional analyzers
+// that opts turn on.
+func pageAnalyzers(opts *options) ([]func() analyzer, error) {
+	var as []func() analyzer
+	if len(opts.spellDicts) > 0 {
+		sp, err := newSpeller(opts.spellDicts, opts.spellIgnore)
+		if err != nil {
+			return nil, err
+		}
+		as = append(as, func() analyzer { return &spellAnalyzer{sp: sp} })
+	}
+	return as, nil
+}
+
+// writeSection prints what write produces after the report on
+// stdout if stdout is set, otherwise it stores it as name.
+func writeSection(st storage, stdout bool, name string, write func(io.Writer) error) error {
+	if stdout {
+		fmt.Println()
+		return write(os.Stdout)
+	}
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	return st.put(name, buf.Bytes())
+}
+
+// publish runs all checks and integrations on the results in
+// rep and writes them to all configured outputs. It returns
+// false if CI mode is on and its thresholds were exceeded.
+func publish(opts *options, rep *report, st storage, ci *ciThresholds) bool {
+	rep.sortBy = opts.sortBy
+	rep.group = opts.group
+	rep.fold = opts.fold
+	rep.timings = !opts.deterministic
+	var prev *Report
+	if opts.history != "" {
+		var err error
+		if prev, err = latestSnapshot(opts.history, rep.base); err != nil {
+			fatal("cannot read history", err)
+		}
+	}
+	checkLinks(rep)
+	checkPages(rep)
+	checkParams(rep)
+	checkCanonicals(rep)
+	checkRedirects(rep)
+	checkFragments(rep)
+	checkNoindexLinks(rep)
+	checkLint(rep)
+	checkStructuredData(rep)
+	checkRichResults(rep)
+	checkSpelling(rep)
+	checkPerformance(rep, opts.slowThreshold, opts.heavyThreshold)
+	if opts.a11y {
+		checkAccessibility(rep)
+	}
+	if opts.mobileFriendly {
+		checkMobileFriendly(rep)
+	}
+	if opts.cookies {
+		checkCookies(rep)
+	}
+	if opts.breadcrumbs {
+		checkBreadcrumbs(rep)
+	}
+	if opts.anchorText {
+		checkAnchorText(rep)
+	}
+	if opts.domSize {
+		checkDOM(rep)
+	}
+	if opts.markup {
+		checkMarkup(rep)
+	}
+	if opts.sri {
+		checkSRI(rep)
+	}
+	if opts.csp {
+		checkCSP(rep)
+	}
+	if opts.otherLinks {
+		checkOtherLinks(rep)
+	}
+	if opts.duplicateContent {
+		checkDuplicateContent(rep)
+	}
+	if opts.documents {
+		if rep.docs == nil {
+			rep.docs = rep.documents()
+		}
+		checkDocuments(rep, rep.docs)
+	}
+	if opts.checkLastmod {
+		checkLastmod(rep, prev)
+	}
+	if opts.tags || len(opts.requireTags) > 0 {
+		required := make([]requiredTag, len(opts.requireTags))
+		for i, s := range opts.requireTags {
+			required[i], _ = newRequiredTag(s)
+		}
+		checkTags(rep, required)
+	}
+	if opts.readabilityBand != "" {
+		min, max, _ := parseBand(opts.readabilityBand)
+		checkReadability(rep, min, max)
+	}
+	var stop map[string]bool
+	if opts.terms {
+		if opts.stopwords != "" {
+			var err error
+			if stop, err = loadStopwords(opts.stopwords); err != nil {
+				fatal("cannot read stopwords", err)
+			}
+		}
+		checkCannibalization(rep, stop)
+	}
+	// Interrupted crawls do not call external services.
+	if opts.cruxKey != "" && !rep.interrupted {
+		cc := newCruxClient(opts.cruxKey, opts.cruxForm)
+		if err := cc.annotate(rep); err != nil {
+			slog.Error("cannot fetch CrUX data", "err", err)
+		}
+	}
+	if opts.gscSite != "" && !rep.interrupted {
+		gc := newGscClient(opts.gscSite, opts.gscToken, opts.gscDays, opts.gscInspect)
+		if err := gc.annotate(rep); err != nil {
+			slog.Error("cannot fetch Search Console data", "err", err)
+		}
+	}
+	if opts.deterministic {
+		rep.sortFindings()
+	}
+	sinks := make([]sink, 0)
+	if opts.esURL != "" && !rep.interrupted {
+		es, err := newElasticSink(opts.esURL, opts.esIndex)
+		if err != nil {
+			fatal("cannot start elasticsearch sink", err)
+		}
+		sinks = append(sinks, es)
+	}
+	if opts.bqTable != "" && !rep.interrupted {
+		bq, err := newBigquerySink(opts.bqTable, opts.bqToken)
+		if err != nil {
+			fatal("cannot start bigquery sink", err)
+		}
+		sinks = append(sinks, bq)
+	}
+	if err := drain(rep, sinks); err != nil {
+		slog.Error("cannot write to sink", "err", err)
+	}
+	if opts.htmlReport != "" {
+		if err := writeFile(opts.htmlReport, rep.writeHTML); err != nil {
+			fatal("cannot write HTML report", err)
+		}
+	}
+	for _, name := range opts.templates {
+		if err := rep.writeTemplate(name, st); err != nil {
+			fatal("cannot render template", err, "template", name)
+		}
+	}
+	switch {
+	case opts.stream:
+		if err := rep.writeFindings(os.Stdout); err != nil {
+			fatal("cannot write report", err)
+		}
+	case opts.output == "":
+		if err := rep.write(os.Stdout); err != nil {
+			fatal("cannot write report", err)
+		}
+	}
+	if opts.output != "" {
+		var buf bytes.Buffer
+		if err := rep.write(&buf); err != nil {
+			fatal("cannot write report", err)
+		}
+		if err := st.put("results.txt", buf.Bytes()); err != nil {
+			fatal("cannot store report", err)
+		}
+	}
+	if opts.sitemap {
+		if err := rep.writeSitemaps(st, sitemapOptions{
+			images:    opts.sitemapImages,
+			videos:    opts.sitemapVideos,
+			hreflangs: opts.sitemapHreflang,
+		}); err != nil {
+			fatal("cannot write sitemap", err)
+		}
+	}
+	if opts.sitemapNews != "" {
+		if err := rep.writeNewsSitemap(st, opts.sitemapNews, opts.newsLanguage); err != nil {
+			fatal("cannot write news sitemap", err)
+		}
+	}
+	if opts.history != "" {
+		if err := saveSnapshot(opts.history, rep); err != nil {
+			fatal("cannot store snapshot", err)
+		}
+		if prev != nil {
+			rep.regressions = regressions(prev, rep)
+			err := writeSection(st, opts.output == "", "regressions.txt", func(w io.Writer) error {
+				return rep.writeRegressions(w, prev)
+			})
+			if err != nil {
+				fatal("cannot write regressions", err)
+			}
+		}
+	}
+	if (opts.indexNowKey != "" || len(opts.pings) > 0) && !rep.interrupted {
+		submit(opts, rep, prev)
+	}
+	if opts.json {
+		if err := writeSection(st, opts.output == "", "results.json", rep.writeJSON); err != nil {
+			fatal("cannot write JSON results", err)
+		}
+	}
+	if opts.patterns {
+		if err := writeSection(st, opts.output == "", "patterns.txt", rep.writeTemplates); err != nil {
+			fatal("cannot write URL patterns", err)
+		}
+	}
+	if opts.terms {
+		err := writeSection(st, opts.output == "", "terms.txt", func(w io.Writer) error {
+			return rep.writeTerms(w, stop)
+		})
+		if err != nil {
+			fatal("cannot write terms", err)
+		}
+	}
+	if opts.indexability {
+		if err := writeSection(st, opts.output == "", "indexability.txt", rep.writeIndexability); err != nil {
+			fatal("cannot write indexability", err)
+		}
+	}
+	if opts.richResults {
+		if err := writeSection(st, opts.output == "", "rich-results.txt", rep.writeRichResults); err != nil {
+			fatal("cannot write rich results", err)
+		}
+	}
+	if opts.serverRewrites != "" {
+		err := writeSection(st, opts.output == "", "rewrites.conf", func(w io.Writer) error {
+			return rep.writeServerRewrites(w, opts.serverRewrites)
+		})
+		if err != nil {
+			fatal("cannot write rewrite rules", err)
+		}
+	}
+	if opts.linksCSV {
+		if err := writeSection(st, opts.output == "", "links.csv", rep.writeLinksCSV); err != nil {
+			fatal("cannot write links", err)
+		}
+	}
+	if opts.otherLinks {
+		if err := writeSection(st, opts.output == "", "other-links.txt", rep.writeOtherLinks); err != nil {
+			fatal("cannot write other links", err)
+		}
+	}
+	if opts.documents {
+		err := writeSection(st, opts.output == "", "documents.txt", func(w io.Writer) error {
+			return writeDocuments(w, rep.docs)
+		})
+		if err != nil {
+			fatal("cannot write documents", err)
+		}
+	}
+	if opts.tags {
+		if err := writeSection(st, opts.output == "", "tags.txt", rep.writeTags); err != nil {
+			fatal("cannot write tags", err)
+		}
+	}
+	if opts.cookies {
+		if err := writeSection(st, opts.output == "", "cookies.txt", rep.writeCookies); err != nil {
+			fatal("cannot write cookies", err)
+		}
+	}
+	if opts.checkCerts {
+		if err := writeSection(st, opts.output == "", "certs.txt", rep.writeCerts); err != nil {
+			fatal("cannot write certificates", err)
+		}
+	}
+	if opts.thirdParties {
+		if err := writeSection(st, opts.output == "", "third-parties.txt", rep.writeThirdParties); err != nil {
+			fatal("cannot write third parties", err)
+		}
+	}
+	if opts.top > 0 {
+		err := writeSection(st, opts.output == "", "top.txt", func(w io.Writer) error {
+			return rep.writeTop(w, opts.top)
+		})
+		if err != nil {
+			fatal("cannot write top pages", err)
+		}
+	}
+	if opts.outlinks {
+		if err := writeSection(st, opts.output == "", "outlinks.txt", rep.writeOutlinks); err != nil {
+			fatal("cannot write outbound links", err)
+		}
+	}
+	if opts.ci {
+		return ci.check(rep, os.Stderr)
 	}
+	return true
 }
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// TestMain runs seopeo itself instead of the tests when the test
+// binary is started by runSeopeo.
+func TestMain(m *testing.M) {
+	if os.Getenv("SEOPEO_TEST_MAIN") != "" {
+		os.Args = append([]string{"seopeo"}, strings.Split(os.Getenv("SEOPEO_TEST_MAIN"), "\n")...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runSeopeo runs seopeo with args and fails t if it does not
+// exit successfully.
+func runSeopeo(t *testing.T, args ...string) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "SEOPEO_TEST_MAIN="+strings.Join(args, "\n"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("seopeo %s: %s\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+// testSite serves a small site with a redirect, a missing page
+// and some pages with issues to report.
+func testSite() http.Handler {
+	page := func(title, body string) string {
+		return fmt.Sprintf("<!DOCTYPE html><html lang=\"en\"><head><title>%s</title></head><body>%s</body></html>", title, body)
+	}
+	pages := map[string]string{
+		"/":            page("Home", `<h1>Home</h1><a href="/about">About</a> <a href="/blog">Blog</a> <a href="/old">Old</a>`),
+		"/about":       page("About", `<h1>About us</h1><p>We are here.</p><a href="/">Home</a> <a href="/missing">Missing</a>`),
+		"/blog":        page("Blog", `<h1>Blog</h1><a href="/blog/first">First</a> <a href="/blog/second">Second</a>`),
+		"/blog/first":  page("First post", `<h1>First</h1><p>Hello.</p><a href="/blog">Blog</a>`),
+		"/blog/second": page("First post", `<p>No heading.</p><img src="/img.png">`),
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/about", http.StatusMovedPermanently)
+			return
+		}
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, body)
+	})
+}
+
+// readOutput returns the files written to dir, with the address
+// of the test server srv replaced so that they do not change
+// from run to run.
+func readOutput(t *testing.T, dir string, srv *httptest.Server) map[string]string {
+	t.Helper()
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := strings.TrimPrefix(srv.URL, "http://")
+	out := make(map[string]string)
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[f.Name()] = strings.Replace(string(data), host, "site.test", -1)
+	}
+	return out
+}
+
+func TestDeterministicGolden(t *testing.T) {
+	srv := httptest.NewServer(testSite())
+	defer srv.Close()
+	dir := t.TempDir()
+	runSeopeo(t, "-deterministic", "-quiet", "-json", "-top", "3", "-output", dir, srv.URL)
+	got := readOutput(t, dir, srv)
+	golden := filepath.Join("testdata", "golden")
+	if *update {
+		if err := os.MkdirAll(golden, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for name, data := range got {
+			if err := ioutil.WriteFile(filepath.Join(golden, name), []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	want := make(map[string]string)
+	files, err := ioutil.ReadDir(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(filepath.Join(golden, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[f.Name()] = string(data)
+	}
+	for name, data := range want {
+		if got[name] != data {
+			t.Errorf("%s differs from the golden file:\n%s", name, got[name])
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			t.Errorf("%s has no golden file", name)
+		}
+	}
+	// A second crawl gives the same output.
+	dir2 := t.TempDir()
+	runSeopeo(t, "-deterministic", "-quiet", "-json", "-top", "3", "-output", dir2, srv.URL)
+	for name, data := range readOutput(t, dir2, srv) {
+		if got[name] != data {
+			t.Errorf("%s differs between two crawls", name)
+		}
+	}
+}
+
+// cannedSite is a transport that answers without a network: /old
+// redirects to /new, everything else is "ok". It keeps the
+// requests it was sent.
+type cannedSite struct {
+	reqs []*http.Request
+}
+
+func (s *cannedSite) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.reqs = append(s.reqs, req)
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader("ok")),
+		Request:    req,
+	}
+	if req.URL.Path == "/old" {
+		resp.StatusCode = http.StatusMovedPermanently
+		resp.Header.Set("Location", "/new")
+		resp.Body = ioutil.NopCloser(strings.NewReader(""))
+	}
+	return resp, nil
+}
+
+func TestFetcherTransport(t *testing.T) {
+	f, err := newFetcher("", "", []string{"X-All: 1"}, []string{"example.test=X-Host: 2"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := &cannedSite{}
+	f.setTransport(site)
+	resp, err := f.get(context.Background(), "http://example.test/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.status != http.StatusOK || string(resp.body) != "ok" {
+		t.Errorf("got %d %q, want 200 \"ok\"", resp.status, resp.body)
+	}
+	if len(resp.redirects) != 1 || resp.redirects[0].To != "http://example.test/new" {
+		t.Errorf("got redirects %v, want one to /new", resp.redirects)
+	}
+	if len(site.reqs) != 2 {
+		t.Fatalf("transport got %d requests, want 2", len(site.reqs))
+	}
+	for _, req := range site.reqs {
+		if req.Header.Get("X-All") != "1" || req.Header.Get("X-Host") != "2" {
+			t.Errorf("%s sent without the headers: %v", req.URL, req.Header)
+		}
+	}
+	resp, err = f.getDirect(context.Background(), "http://example.test/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.status != http.StatusMovedPermanently {
+		t.Errorf("getDirect got %d, want 301", resp.status)
+	}
+}
+
+func TestFetcherClient(t *testing.T) {
+	f, err := newFetcher("user", "secret", nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := &cannedSite{}
+	client := &http.Client{Transport: site, Timeout: time.Minute}
+	f.setClient(client)
+	// Middlewares added later also wrap the transport of client.
+	var seen []string
+	f.use(func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			seen = append(seen, req.URL.Path)
+			return next.RoundTrip(req)
+		})
+	})
+	if f.client.Timeout != time.Minute || f.direct.Timeout != time.Minute {
+		t.Errorf("the timeout of the client was not kept")
+	}
+	if _, err := f.get(context.Background(), "http://example.test/old"); err != nil {
+		t.Fatal(err)
+	}
+	if len(site.reqs) != 2 || strings.Join(seen, " ") != "/old /new" {
+		t.Errorf("transport got %d requests, middleware saw %v", len(site.reqs), seen)
+	}
+	if user, pass, ok := site.reqs[0].BasicAuth(); !ok || user != "user" || pass != "secret" {
+		t.Errorf("request sent without credentials")
+	}
+	if client.Transport != site || client.CheckRedirect != nil {
+		t.Errorf("the client given was changed")
+	}
+}
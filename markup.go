@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// optionalEnd are the elements whose end tag can be left out.
+var optionalEnd = map[string]bool{
+	"html": true, "head": true, "body": true, "p": true, "li": true,
+	"dt": true, "dd": true, "option": true, "optgroup": true, "tr": true,
+	"td": true, "th": true, "thead": true, "tbody": true, "tfoot": true,
+	"colgroup": true, "caption": true, "rp": true, "rt": true,
+}
+
+// headElements are the elements allowed in the head. Any other
+// ends the head early: what follows it is in the body, where
+// crawlers ignore titles, canonicals and robots directives.
+var headElements = map[string]bool{
+	"title": true, "meta": true, "link": true, "script": true, "style": true,
+	"base": true, "noscript": true, "template": true,
+}
+
+// markupAnalyzer detects broken markup: elements left open, end
+// tags closing nothing, content after the end of the document,
+// repeated html, head or body elements and elements that end
+// the head early.
+type markupAnalyzer struct {
+	issues []finding
+	open   []string
+	seen   map[string]bool // html, head and body start tags
+	inHead bool
+	ended  bool // </html> seen
+}
+
+func (a *markupAnalyzer) add(kind, detail string) {
+	a.issues = append(a.issues, finding{Kind: kind, Detail: detail})
+}
+
+func (a *markupAnalyzer) token(t *html.Token) {
+	if a.ended {
+		if t.Type == html.StartTagToken || t.Type == html.SelfClosingTagToken ||
+			(t.Type == html.TextToken && strings.TrimSpace(t.Data) != "") {
+			a.add("markup-after-html", describeToken(t))
+			a.ended = false
+		}
+	}
+	switch t.Type {
+	case html.StartTagToken, html.SelfClosingTagToken:
+		switch t.Data {
+		case "html", "head", "body":
+			if a.seen == nil {
+				a.seen = make(map[string]bool)
+			}
+			if a.seen[t.Data] {
+				a.add("markup-duplicate-"+t.Data, "")
+			}
+			a.seen[t.Data] = true
+			a.inHead = t.Data == "head" && t.Type == html.StartTagToken
+		default:
+			if a.inHead && !headElements[t.Data] {
+				a.add("markup-invalid-in-head", "<"+t.Data+">")
+				a.inHead = false
+			}
+		}
+		if t.Type == html.StartTagToken && !voidElements[t.Data] {
+			a.open = append(a.open, t.Data)
+		}
+	case html.EndTagToken:
+		if t.Data == "head" {
+			a.inHead = false
+		}
+		i := len(a.open) - 1
+		for i >= 0 && a.open[i] != t.Data {
+			i--
+		}
+		if i < 0 {
+			a.add("markup-stray-end-tag", "</"+t.Data+">")
+			return
+		}
+		a.unclosed(a.open[i+1:])
+		a.open = a.open[:i]
+		if t.Data == "html" {
+			a.ended = true
+		}
+	}
+}
+
+// unclosed adds a finding for each element of open that needed
+// an end tag.
+func (a *markupAnalyzer) unclosed(open []string) {
+	for _, name := range open {
+		if !optionalEnd[name] {
+			a.add("markup-unclosed", "<"+name+">")
+		}
+	}
+}
+
+// describeToken returns the start tag or the start of the text of t.
+func describeToken(t *html.Token) string {
+	if t.Type == html.TextToken {
+		s := strings.TrimSpace(t.Data)
+		if r := []rune(s); len(r) > 20 {
+			s = string(r[:20]) + "…"
+		}
+		return fmt.Sprintf("%q", s)
+	}
+	return "<" + t.Data + ">"
+}
+
+func (a *markupAnalyzer) finish(res *result) {
+	a.unclosed(a.open)
+	res.Markup = a.issues
+}
+
+// checkMarkup adds the findings of markupAnalyzer, one per page
+// and kind.
+func checkMarkup(rep *report) {
+	for _, res := range rep.sorted() {
+		if res.page() {
+			rep.addIssues(res.URL, res.Markup)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// roundTripperFunc makes a function an http.RoundTripper.
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// middleware wraps the transport that sends a request: it can
+// change the request before passing it to next and inspect or
+// replace the response. Each redirect is a request of its own.
+type middleware func(next http.RoundTripper) http.RoundTripper
+
+// use adds mws to the middlewares of f. Each wraps those added
+// before it, so the last one sees the request first and the
+// response last.
+func (f *fetcher) use(mws ...middleware) {
+	f.mws = append(f.mws, mws...)
+	f.setTransport(f.transport)
+}
+
+// wrap returns rt, or the default transport if nil, wrapped in
+// the middlewares of f.
+func (f *fetcher) wrap(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	for _, mw := range f.mws {
+		rt = mw(rt)
+	}
+	return rt
+}
+
+// hostHeaders returns a middleware setting headers only on
+// requests to a host. Each header is given as "HOST=Name: value".
+func hostHeaders(headers []string) (middleware, error) {
+	byHost := make(map[string]http.Header)
+	for _, h := range headers {
+		i := strings.Index(h, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("header %q is not in the form \"HOST=Name: value\"", h)
+		}
+		name, val, err := parseHeader(h[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		host := strings.ToLower(h[:i])
+		if byHost[host] == nil {
+			byHost[host] = make(http.Header)
+		}
+		byHost[host].Add(name, val)
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			hdr, ok := byHost[strings.ToLower(req.URL.Hostname())]
+			if !ok {
+				return next.RoundTrip(req)
+			}
+			// A RoundTripper must not modify the request it is given.
+			req = req.Clone(req.Context())
+			for name, vals := range hdr {
+				req.Header[name] = vals
+			}
+			return next.RoundTrip(req)
+		})
+	}, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	nurl "net/url"
+	"os"
+	"strings"
+)
+
+// readRedirectMap reads a map of old to new URLs as written by
+// redirect-map: one mapping per line, old and new URL separated
+// by spaces or tabs, anything after them ignored. Empty lines and
+// lines starting with # are skipped; "-" as new URL means that
+// no target was chosen.
+func readRedirectMap(r io.Reader) ([]redirectMapping, error) {
+	var ms []redirectMapping
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: no new URL for %s", n, fields[0])
+		}
+		to := fields[1]
+		if to == "-" {
+			to = ""
+		}
+		ms = append(ms, redirectMapping{from: fields[0], to: to})
+	}
+	return ms, sc.Err()
+}
+
+// verifyMigration checks that the old URL of m redirects with a
+// 301 in one hop to its new URL and that the new URL is indexable:
+// it answers 200, is not noindex, has no canonical elsewhere and
+// is not disallowed by robots.txt for agent. It returns what is
+// wrong, nothing if all is fine.
+func verifyMigration(ctx context.Context, fetch *fetcher, m redirectMapping, agent string, robots map[string]*robotsTxt) []string {
+	if m.to == "" {
+		return []string{"no new URL in the map"}
+	}
+	resp, err := fetch.getDirect(ctx, m.from)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var problems []string
+	loc := resp.header.Get("Location")
+	if loc != "" {
+		if u, err := nurl.Parse(m.from); err == nil {
+			if l, err := u.Parse(loc); err == nil {
+				loc = l.String()
+			}
+		}
+	}
+	switch {
+	case resp.status < 300 || resp.status >= 400:
+		problems = append(problems, fmt.Sprintf("status %d instead of a 301 to %s", resp.status, m.to))
+	case !sameURL(loc, m.to):
+		problems = append(problems, fmt.Sprintf("redirects to %s instead of %s", loc, m.to))
+	case resp.status != http.StatusMovedPermanently:
+		problems = append(problems, fmt.Sprintf("status %d instead of 301", resp.status))
+	}
+	resp, err = fetch.getDirect(ctx, m.to)
+	if err != nil {
+		return append(problems, "new URL: "+err.Error())
+	}
+	if resp.status >= 300 && resp.status < 400 {
+		return append(problems, fmt.Sprintf("new URL redirects with status %d to %s, more than one hop", resp.status, resp.header.Get("Location")))
+	}
+	res := &result{URL: m.to}
+	if err := analyze(res, resp, slog.With("url", m.to)); err != nil {
+		slog.Debug("cannot parse page", "url", m.to, "err", err)
+	}
+	switch {
+	case res.Status != http.StatusOK:
+		problems = append(problems, fmt.Sprintf("new URL has status %d", res.Status))
+	case res.noindex():
+		problems = append(problems, "new URL is noindex")
+	case res.Canonical != "" && !sameURL(res.Canonical, res.URL):
+		problems = append(problems, "new URL has canonical "+res.Canonical)
+	}
+	u, err := nurl.Parse(m.to)
+	if err != nil {
+		return problems
+	}
+	site := u.Scheme + "://" + u.Host
+	rt, ok := robots[site]
+	if !ok {
+		if rt, _, err = fetchRobots(ctx, fetch, u); err != nil {
+			slog.Warn("cannot fetch robots.txt", "site", site, "err", err)
+		}
+		robots[site] = rt
+	}
+	if rt != nil {
+		if allowed, rule := rt.test(agent, m.to); !allowed {
+			why := "everything"
+			if rule != nil {
+				why = rule.String()
+			}
+			problems = append(problems, "new URL is disallowed by robots.txt: "+why)
+		}
+	}
+	return problems
+}
+
+// migrateCmd implements the "migrate" subcommand: verify that
+// the old URLs of a redirect map redirect to the new ones as they
+// should after a site migration. It prints each mismatch and exits
+// with status 1 if there are any.
+func migrateCmd(opts *options, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: seopeo [flags] migrate MAP\n")
+		return 2
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		slog.Error("cannot open redirect map", "err", err)
+		return 2
+	}
+	ms, err := readRedirectMap(f)
+	f.Close()
+	if err != nil {
+		slog.Error("cannot read redirect map", "file", args[0], "err", err)
+		return 2
+	}
+	fetch, err := optionsFetcher(opts)
+	if err != nil {
+		slog.Error("cannot start fetcher", "err", err)
+		return 2
+	}
+	robots := make(map[string]*robotsTxt)
+	failed := 0
+	for _, m := range ms {
+		problems := verifyMigration(context.Background(), fetch, m, opts.robotsAgent, robots)
+		if len(problems) > 0 {
+			failed++
+		}
+		for _, p := range problems {
+			fmt.Printf("%s\t%s\n", m.from, p)
+		}
+	}
+	fmt.Printf("%d of %d redirects verified\n", len(ms)-failed, len(ms))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// The user agents of the mobile and desktop Googlebot, which
+// sites serving content by device are most likely to recognize.
+const (
+	mobileUserAgent  = "Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.6478.126 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+	desktopUserAgent = "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; Googlebot/2.1; +http://www.google.com/bot.html) Chrome/126.0.6478.126 Safari/537.36"
+)
+
+// withUserAgent returns a copy of f sending ua as user agent.
+func (f *fetcher) withUserAgent(ua string) *fetcher {
+	g := *f
+	g.headers = f.headers.Clone()
+	g.headers.Set("User-Agent", ua)
+	return &g
+}
+
+// checkMobile fetches the crawled pages again with a mobile user
+// agent and adds a finding for each page whose status, canonical,
+// content or links differ from those seen by the crawl, which is
+// done as a desktop browser.
+func checkMobile(ctx context.Context, fetch *fetcher, rep *report) {
+	mobile := fetch.withUserAgent(mobileUserAgent)
+	for _, res := range rep.sorted() {
+		if res.Err != nil {
+			continue
+		}
+		m := &result{URL: res.URL}
+		resp, err := mobile.get(ctx, res.URL)
+		if err != nil {
+			rep.add("mobile-error", res.URL, err.Error())
+			continue
+		}
+		if err := analyze(m, resp, slog.With("url", res.URL, "agent", "mobile")); err != nil {
+			slog.Warn("cannot parse mobile page", "url", res.URL, "err", err)
+		}
+		if m.Status != res.Status {
+			rep.add("mobile-status", res.URL, fmt.Sprintf("status %d on desktop, %d on mobile", res.Status, m.Status))
+			continue
+		}
+		if m.Canonical != res.Canonical {
+			rep.add("mobile-canonical", res.URL, fmt.Sprintf("canonical %q on desktop, %q on mobile", res.Canonical, m.Canonical))
+		}
+		if m.Hash != res.Hash {
+			rep.add("mobile-content", res.URL, fmt.Sprintf("%d bytes on desktop, %d on mobile", res.Size, m.Size))
+		}
+		if d := linkDiff(linkSet(res), linkSet(m)); d != "" {
+			rep.add("mobile-links", res.URL, d)
+		}
+	}
+}
+
+// linkDiff describes the links only in desktop and only in
+// mobile, or returns an empty string if there are none.
+func linkDiff(desktop, mobile map[string]bool) string {
+	only := func(a, b map[string]bool) []string {
+		var ls []string
+		for l := range a {
+			if !b[l] {
+				ls = append(ls, l)
+			}
+		}
+		sort.Strings(ls)
+		return ls
+	}
+	var parts []string
+	if ls := only(desktop, mobile); len(ls) > 0 {
+		parts = append(parts, fmt.Sprintf("%d only on desktop: %s", len(ls), strings.Join(ls, " ")))
+	}
+	if ls := only(mobile, desktop); len(ls) > 0 {
+		parts = append(parts, fmt.Sprintf("%d only on mobile: %s", len(ls), strings.Join(ls, " ")))
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// urlState is what monitor remembers of a URL between checks.
+type urlState struct {
+	status int
+	target string // where redirects end, empty without them
+	title  string
+	err    string
+	slow   bool
+}
+
+// checkURL fetches url and returns its state.
+func checkURL(ctx context.Context, fetch *fetcher, url string, slow time.Duration) urlState {
+	resp, err := fetch.get(ctx, url)
+	if err != nil {
+		return urlState{err: err.Error()}
+	}
+	st := urlState{status: resp.status}
+	if n := len(resp.redirects); n > 0 {
+		st.target = resp.redirects[n-1].To
+	}
+	st.slow = slow > 0 && resp.elapsed > slow
+	res := &result{URL: url}
+	if err := analyze(res, resp, slog.With("url", url)); err != nil {
+		slog.Debug("cannot parse page", "url", url, "err", err)
+	}
+	st.title = res.Title
+	return st
+}
+
+// deviations describes how cur differs from prev.
+func deviations(prev, cur urlState) []string {
+	var ds []string
+	if prev.err != cur.err {
+		switch {
+		case cur.err == "":
+			ds = append(ds, "reachable again")
+		default:
+			ds = append(ds, "error: "+cur.err)
+		}
+	}
+	if cur.err != "" || prev.err != "" {
+		return ds
+	}
+	if prev.status != cur.status {
+		// An error page has a title of its own.
+		return append(ds, fmt.Sprintf("status %d -> %d", prev.status, cur.status))
+	}
+	if prev.target != cur.target {
+		ds = append(ds, fmt.Sprintf("redirect target %q -> %q", prev.target, cur.target))
+	}
+	if prev.title != cur.title {
+		ds = append(ds, fmt.Sprintf("title %q -> %q", prev.title, cur.title))
+	}
+	if prev.slow != cur.slow {
+		if cur.slow {
+			ds = append(ds, "slow")
+		} else {
+			ds = append(ds, "fast again")
+		}
+	}
+	return ds
+}
+
+// monitor checks urls every interval, for checks rounds or
+// until ctx is done if checks is zero, and writes an alert line
+// to w for each change of a URL since the previous check. The
+// first check only prints the state of each URL. It returns the
+// number of alerts.
+func monitor(ctx context.Context, w io.Writer, fetch *fetcher, urls []string, interval, slow time.Duration, checks int) int {
+	states := make(map[string]urlState)
+	alerts := 0
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for round := 0; checks == 0 || round < checks; round++ {
+		if round > 0 {
+			select {
+			case <-tick.C:
+			case <-ctx.Done():
+				return alerts
+			}
+		}
+		at := now().UTC().Format(time.RFC3339)
+		for _, url := range urls {
+			cur := checkURL(ctx, fetch, url, slow)
+			if ctx.Err() != nil {
+				return alerts
+			}
+			prev, ok := states[url]
+			states[url] = cur
+			if !ok {
+				state := fmt.Sprintf("status %d", cur.status)
+				if cur.err != "" {
+					state = "error: " + cur.err
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", at, url, state)
+				continue
+			}
+			for _, d := range deviations(prev, cur) {
+				alerts++
+				fmt.Fprintf(w, "%s\t%s\tALERT %s\n", at, url, d)
+			}
+		}
+	}
+	return alerts
+}
+
+// monitorCmd implements the "monitor" subcommand: check a few
+// key URLs at an interval and alert when they change. With a
+// number of checks, it exits with status 1 if there were alerts.
+func monitorCmd(opts *options, args []string) int {
+	fs := flag.NewFlagSet("monitor", flag.ContinueOnError)
+	interval := fs.Duration("interval", 5*time.Minute, "time between checks")
+	checks := fs.Int("checks", 0, "stop after this many checks (0 to run until interrupted)")
+	slow := fs.Duration("slow", 0, "alert when a URL takes longer than this to download (0 to disable)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: seopeo [flags] monitor [-interval D] [-checks N] [-slow D] URL...\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 || *interval <= 0 || *checks < 0 {
+		fs.Usage()
+		return 2
+	}
+	fetch, err := optionsFetcher(opts)
+	if err != nil {
+		slog.Error("cannot start fetcher", "err", err)
+		return 2
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if monitor(ctx, os.Stdout, fetch, fs.Args(), *interval, *slow, *checks) > 0 && *checks > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// newsWindow is how old an article can be to be in a news sitemap.
+const newsWindow = 48 * time.Hour
+
+// maxNewsURLs is the limit of URLs in a news sitemap.
+const maxNewsURLs = 1000
+
+const sitemapNewsNS = "http://www.google.com/schemas/sitemap-news/0.9"
+
+// publishedMetas are the meta tags that carry the publication
+// date of an article.
+var publishedMetas = map[string]bool{
+	"article:published_time": true,
+	"datepublished":          true,
+	"pubdate":                true,
+	"publish-date":           true,
+	"date":                   true,
+	"dc.date.issued":         true,
+}
+
+// articleTypes are the JSON-LD types of articles.
+var articleTypes = []string{"NewsArticle", "Article", "BlogPosting", "ReportageNewsArticle"}
+
+// dateLayouts are the forms publication dates are found in.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// parseDate parses a publication date, returning the zero
+// time if it is in no known form.
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, l := range dateLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// articleAnalyzer finds the publication date and headline of
+// an article, from its structured data or its meta tags.
+type articleAnalyzer struct {
+	p    *page
+	date string
+}
+
+func (a *articleAnalyzer) token(t *html.Token) {
+	if t.Data != "meta" || a.date != "" || (t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken) {
+		return
+	}
+	name, ok := attr(t, "property")
+	if !ok {
+		name, _ = attr(t, "name")
+	}
+	if publishedMetas[strings.ToLower(name)] {
+		a.date, _ = attr(t, "content")
+	}
+}
+
+func (a *articleAnalyzer) finish(res *result) {
+	date := a.date
+	findTyped(a.p.ld, func(o map[string]interface{}) {
+		if d := jsonString(o["datePublished"]); d != "" && res.Headline == "" {
+			date = d
+			res.Headline = jsonString(o["headline"])
+		}
+	}, articleTypes...)
+	res.Published = parseDate(date)
+}
+
+// newsLanguage turns the language tag of a document into the
+// ISO 639 code news sitemaps want; only Chinese keeps a region.
+func newsLanguage(tag string) string {
+	tag = strings.ToLower(strings.ReplaceAll(tag, "_", "-"))
+	if tag == "zh-cn" || tag == "zh-tw" {
+		return tag
+	}
+	if i := strings.Index(tag, "-"); i >= 0 {
+		return tag[:i]
+	}
+	return tag
+}
+
+type sitemapNewsPublication struct {
+	Name     string `xml:"news:name"`
+	Language string `xml:"news:language"`
+}
+
+type sitemapNews struct {
+	Publication     sitemapNewsPublication `xml:"news:publication"`
+	PublicationDate string                 `xml:"news:publication_date"`
+	Title           string                 `xml:"news:title"`
+}
+
+type sitemapNewsURL struct {
+	Loc  string      `xml:"loc"`
+	News sitemapNews `xml:"news:news"`
+}
+
+type sitemapNewsURLSet struct {
+	XMLName xml.Name         `xml:"urlset"`
+	NS      string           `xml:"xmlns,attr"`
+	NewsNS  string           `xml:"xmlns:news,attr"`
+	URLs    []sitemapNewsURL `xml:"url"`
+}
+
+// writeNewsSitemap stores news-sitemap.xml with the indexable
+// articles published in the newsWindow before now, for the
+// publication name. The language of a page defaults to lang.
+func (r *report) writeNewsSitemap(st storage, name, lang string) error {
+	set := sitemapNewsURLSet{NS: sitemapNS, NewsNS: sitemapNewsNS}
+	since := now().Add(-newsWindow)
+	for _, res := range r.sorted() {
+		if !res.indexable() || res.Published.IsZero() || res.Published.Before(since) {
+			continue
+		}
+		if len(set.URLs) == maxNewsURLs {
+			slog.Warn("too many articles for news sitemap", "max", maxNewsURLs)
+			break
+		}
+		title := res.Headline
+		if title == "" {
+			title = res.Title
+		}
+		l := newsLanguage(res.Lang)
+		if l == "" {
+			l = lang
+		}
+		set.URLs = append(set.URLs, sitemapNewsURL{
+			Loc: res.URL,
+			News: sitemapNews{
+				Publication:     sitemapNewsPublication{Name: name, Language: l},
+				PublicationDate: res.Published.Format(time.RFC3339),
+				Title:           title,
+			},
+		})
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		return fmt.Errorf("cannot encode news sitemap: %s", err)
+	}
+	buf.WriteString("\n")
+	return st.put("news-sitemap.xml", buf.Bytes())
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"regexp"
+	"strings"
+)
+
+// normalizer rewrites the URLs found on pages before the crawler
+// deduplicates and schedules them.
+type normalizer interface {
+	// normalize returns url rewritten, or unchanged.
+	normalize(url string) string
+}
+
+// normalizerFunc makes a function a normalizer.
+type normalizerFunc func(url string) string
+
+func (f normalizerFunc) normalize(url string) string {
+	return f(url)
+}
+
+// rewriteRule replaces the matches of a regular expression.
+type rewriteRule struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+// newRewriteRule parses a rule given as REGEXP=>REPLACEMENT; the
+// replacement can refer to groups as $1 or ${name}.
+func newRewriteRule(rule string) (*rewriteRule, error) {
+	i := strings.Index(rule, "=>")
+	if i <= 0 {
+		return nil, fmt.Errorf("rewrite %q is not REGEXP=>REPLACEMENT", rule)
+	}
+	re, err := regexp.Compile(rule[:i])
+	if err != nil {
+		return nil, fmt.Errorf("cannot compile rewrite: %s", err)
+	}
+	return &rewriteRule{re: re, repl: rule[i+2:]}, nil
+}
+
+func (r *rewriteRule) normalize(url string) string {
+	return r.re.ReplaceAllString(url, r.repl)
+}
+
+// rootPath adds the slash to URLs with an empty path, so that
+// "http://host" and "http://host/" are the same page.
+func rootPath(url string) string {
+	u, err := nurl.Parse(url)
+	if err != nil || u.Host == "" || u.Opaque != "" || u.Path != "" {
+		return url
+	}
+	u.Path = "/"
+	return u.String()
+}
+
+// normalize passes url through all normalizers, in order.
+func (c *crawler) normalize(url string) string {
+	for _, n := range c.normalizers {
+		url = n.normalize(url)
+	}
+	return url
+}
+
+// normalizeSeeds passes base and the URLs queued before the crawl
+// starts through the normalizers, as for discovered URLs. It is
+// called again when normalizers are added.
+func (c *crawler) normalizeSeeds(base string) error {
+	base = c.normalize(base)
+	burl, err := nurl.Parse(base)
+	if err != nil {
+		return err
+	}
+	c.base, c.baseurl = base, burl
+	urls := make(map[string]bool, len(c.urls))
+	for url, scheduled := range c.urls {
+		url = c.normalize(url)
+		urls[url] = urls[url] || scheduled
+	}
+	c.urls = urls
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"log/slog"
+	nurl "net/url"
+)
+
+// errorPage is the fingerprint of the page a site answers with
+// for URLs that do not exist.
+type errorPage struct {
+	URL    string
+	Status int
+	Title  string
+	Hash   string
+}
+
+// probeNotFound requests a URL that cannot exist on the site of
+// base and returns what the site answered. The URL is the same
+// for each site, so that repeated crawls compare.
+func probeNotFound(ctx context.Context, fetch *fetcher, base *nurl.URL) (*errorPage, error) {
+	url := fmt.Sprintf("%s://%s/seopeo-not-found-%x", base.Scheme, base.Host, sha1.Sum([]byte(base.Host)))
+	resp, err := fetch.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	res := &result{URL: url}
+	if err := analyze(res, resp, slog.With("url", url)); err != nil {
+		return nil, err
+	}
+	return &errorPage{URL: url, Status: res.Status, Title: res.Title, Hash: res.Hash}, nil
+}
+
+// checkNotFound adds a "soft-404-site" finding if the site does not
+// answer 404 or 410 for missing pages, and a "soft-404" finding
+// for each crawled page answering 200 with the error page.
+func checkNotFound(rep *report, ep *errorPage) {
+	if ep.Status != 404 && ep.Status != 410 {
+		rep.add("soft-404-site", ep.URL, fmt.Sprintf("status %d for a missing page", ep.Status))
+	}
+	// A title shared with the home page says nothing.
+	title := ep.Title
+	if root := rep.lookup(rep.base); root != nil && root.Title == title {
+		title = ""
+	}
+	for _, res := range rep.sorted() {
+		if !res.page() {
+			continue
+		}
+		if res.Hash == ep.Hash || (title != "" && res.Title == title) {
+			rep.add("soft-404", res.URL, fmt.Sprintf("same page as for missing URLs (title %q)", ep.Title))
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	nurl "net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// stringList is a flag that can be repeated to collect several values.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// options holds all settings of a crawl. Every option is a
+// flag and can also be set from a configuration file, where
+// keys are the flag names.
+type options struct {
+	config           string
+	seeds            stringList
+	workers          int
+	include          stringList
+	exclude          stringList
+	authUser         string
+	authPassword     string
+	headers          stringList
+	hostHeaders      stringList
+	rate             float64
+	cruxKey          string
+	cruxForm         string
+	gscSite          string
+	gscToken         string
+	indexNowKey      string
+	indexNowKeyURL   string
+	indexNowEndpoint string
+	pings            stringList
+	sitemapURL       string
+	gscDays          int
+	gscInspect       bool
+	esURL            string
+	esIndex          string
+	bqTable          string
+	bqToken          string
+	output           string
+	quiet            bool
+	logFormat        string
+	logLevel         string
+	errorLog         string
+	grace            time.Duration
+	httpVersion      string
+	sourceIPs        stringList
+	ipFamily         string
+	maxDuration      time.Duration
+	maxBandwidth     float64
+	maxBytes         int64
+	resume           string
+	stream           bool
+	sortBy           string
+	group            bool
+	fold             bool
+	htmlReport       string
+	templates        stringList
+	archive          bool
+	record           string
+	replay           string
+	deterministic    bool
+	sample           int
+	maxPages         int
+	sectionBudgets   stringList
+	trapLimit        int
+	stripParams      stringList
+	autoStrip        bool
+	rewrites         stringList
+	priorities       stringList
+	strategy         string
+	json             bool
+	history          string
+	patterns         bool
+	outlinks         bool
+	top              int
+	indexability     bool
+	richResults      bool
+	thirdParties     bool
+	cookies          bool
+	tags             bool
+	linksCSV         bool
+	serverRewrites   string
+	requireTags      stringList
+	slowThreshold    time.Duration
+	heavyThreshold   int64
+	a11y             bool
+	mobileFriendly   bool
+	breadcrumbs      bool
+	anchorText       bool
+	domSize          bool
+	markup           bool
+	sri              bool
+	csp              bool
+	otherLinks       bool
+	duplicateContent bool
+	documents        bool
+	headDocuments    bool
+	readabilityBand  string
+	terms            bool
+	stopwords        string
+	checkAliases     bool
+	aliasSample      int
+	checkRobots      bool
+	checkIcons       bool
+	checkCompression bool
+	checkCerts       bool
+	certWarn         time.Duration
+	checkMobile      bool
+	checkLastmod     bool
+	renderBlocking   bool
+	check404         bool
+	spellDicts       stringList
+	spellIgnore      string
+	robotsAgent      string
+	sitemap          bool
+	sitemapImages    bool
+	sitemapVideos    bool
+	sitemapNews      string
+	sitemapHreflang  bool
+	newsLanguage     string
+	archiveGzip      bool
+	ci               bool
+	ciMax5xx         int
+	ciMaxBroken      int
+	ciNoindex        stringList
+	ciRegressions    bool
+}
+
+// newOptions registers all options as flags of fs.
+func newOptions(fs *flag.FlagSet) *options {
+	o := &options{}
+	fs.StringVar(&o.config, "config", "", "read options from this YAML or TOML file; flags override file values")
+	fs.Var(&o.seeds, "seed", "URL to start crawling from (repeatable, also accepted as arguments)")
+	fs.IntVar(&o.workers, "workers", 4, "number of concurrent fetches")
+	fs.Var(&o.include, "include", "only follow URLs matching this regexp (repeatable)")
+	fs.Var(&o.exclude, "exclude", "never follow URLs matching this regexp (repeatable)")
+	fs.StringVar(&o.authUser, "auth-user", "", "user for HTTP basic authentication")
+	fs.StringVar(&o.authPassword, "auth-password", "", "password for HTTP basic authentication")
+	fs.Var(&o.headers, "header", "extra \"Name: value\" request header (repeatable)")
+	fs.Var(&o.hostHeaders, "host-header", "extra request header only for requests to HOST, given as \"HOST=Name: value\" (repeatable)")
+	fs.Float64Var(&o.rate, "rate", 0, "maximum requests per second, 0 for no limit")
+	fs.StringVar(&o.cruxKey, "crux-key", "", "Chrome UX Report API key; fetch field data (LCP/CLS/INP) for crawled URLs")
+	fs.StringVar(&o.cruxForm, "crux-form-factor", "", "CrUX form factor: PHONE, DESKTOP or TABLET (default all)")
+	fs.StringVar(&o.gscSite, "gsc-site", "", "Search Console property (e.g. sc-domain:example.com) to join with the crawl")
+	fs.StringVar(&o.gscToken, "gsc-token", "", "OAuth2 access token for the Search Console API")
+	fs.IntVar(&o.gscDays, "gsc-days", 28, "days of Search Console data to consider")
+	fs.BoolVar(&o.gscInspect, "gsc-inspect", false, "inspect index coverage of each crawled URL (rate limited)")
+	fs.StringVar(&o.indexNowKey, "indexnow-key", "", "submit the indexable pages with -sitemap, or those new or changed since the previous crawl with -history, to IndexNow with this key")
+	fs.StringVar(&o.indexNowKeyURL, "indexnow-key-location", "", "URL the IndexNow key is served at, if not /KEY.txt of the site")
+	fs.StringVar(&o.indexNowEndpoint, "indexnow-endpoint", indexNowEndpoint, "IndexNow endpoint to submit to")
+	fs.Var(&o.pings, "ping", "after writing the sitemap with -sitemap, or when pages changed since the previous crawl with -history, request this `URL` followed by the escaped -sitemap-url to tell a search engine (repeatable)")
+	fs.StringVar(&o.sitemapURL, "sitemap-url", "", "public URL of the sitemap for -ping, by default /sitemap.xml of the site")
+	fs.StringVar(&o.esURL, "es-url", "", "Elasticsearch/OpenSearch server URL to index results into")
+	fs.StringVar(&o.esIndex, "es-index", "seopeo", "Elasticsearch index name")
+	fs.StringVar(&o.bqTable, "bq-table", "", "BigQuery table (project.dataset.table) to stream results into")
+	fs.StringVar(&o.bqToken, "bq-token", "", "OAuth2 access token for the BigQuery API")
+	fs.StringVar(&o.output, "output", "", "write result files to this directory or s3://bucket/prefix (gs:// for GCS) instead of stdout")
+	fs.DurationVar(&o.grace, "grace", 10*time.Second, "on interrupt, wait this long for pages in flight before cancelling them")
+	fs.StringVar(&o.httpVersion, "http-version", "auto", "HTTP version to use: auto to negotiate, or 1.1 or 2 to force one")
+	fs.Var(&o.sourceIPs, "source-ip", "local IP address to connect from; if repeated, new connections use them in turn")
+	fs.StringVar(&o.ipFamily, "ip", "dual", "IP family to connect over: 4, 6 or dual")
+	fs.DurationVar(&o.maxDuration, "max-duration", 0, "stop scheduling new pages after this long and report the crawl as truncated, 0 for no limit")
+	fs.Float64Var(&o.maxBandwidth, "max-bandwidth", 0, "maximum bytes per second read from responses by all workers, 0 for no limit")
+	fs.Int64Var(&o.maxBytes, "max-bytes", 0, "stop scheduling new pages after reading this many bytes of responses and report the crawl as truncated, 0 for no limit")
+	fs.StringVar(&o.resume, "resume", "", "continue an interrupted crawl from this checkpoint file")
+	fs.BoolVar(&o.stream, "stream", false, "print each result as soon as it is fetched; findings follow at the end")
+	fs.StringVar(&o.sortBy, "sort", "", "sort results by url, depth, status or section")
+	fs.BoolVar(&o.group, "group", false, "group results by directory")
+	fs.BoolVar(&o.fold, "fold", false, "list variants of a page (parameters, pagination, duplicates) indented under its canonical URL")
+	fs.StringVar(&o.htmlReport, "report", "", "write a self-contained HTML report to this file")
+	fs.Var(&o.templates, "template", "render this text/template or html/template file with the crawl results; out.html.tmpl is written as out.html to the output (repeatable)")
+	fs.BoolVar(&o.archive, "archive", false, "store response bodies under bodies/ in the output")
+	fs.BoolVar(&o.archiveGzip, "archive-gzip", false, "compress archived bodies with gzip")
+	fs.StringVar(&o.record, "record", "", "save all HTTP responses into directory `dir`")
+	fs.StringVar(&o.replay, "replay", "", "answer all HTTP requests with the responses saved by -record in directory `dir`, without using the network")
+	fs.BoolVar(&o.ci, "ci", false, "exit with status 3 and a summary if the crawl exceeds the -ci-* thresholds")
+	fs.IntVar(&o.ciMax5xx, "ci-max-5xx", 0, "CI mode: maximum number of pages answering with a 5xx status")
+	fs.IntVar(&o.ciMaxBroken, "ci-max-broken-links", 0, "CI mode: maximum number of links to broken pages")
+	fs.Var(&o.ciNoindex, "ci-noindex", "CI mode: fail if a page matching this regexp is noindex (repeatable)")
+	fs.BoolVar(&o.ciRegressions, "ci-regressions", false, "CI mode: fail on regressions since the previous crawl in the -history directory")
+	fs.IntVar(&o.maxPages, "max-pages", 0, "crawl at most this many pages, 0 for no limit")
+	fs.Var(&o.sectionBudgets, "section-budget", "give the paths starting with PREFIX a share of -max-pages, as PREFIX=PERCENT; the rest of the site gets what is left (repeatable)")
+	fs.IntVar(&o.sample, "sample", 0, "crawl only this many URLs of each URL pattern (digits, slugs and query values ignored), and all pages that match no pattern; 0 crawls everything")
+	fs.IntVar(&o.trapLimit, "trap-limit", 0, "stop following URLs of a pattern (digits and query values ignored) after this many, and URLs repeating path segments; 0 disables trap detection")
+	fs.Var(&o.stripParams, "strip-param", "remove this query parameter from discovered URLs (repeatable)")
+	fs.BoolVar(&o.autoStrip, "auto-strip-params", false, "strip query parameters found not to change page content, like session IDs, while crawling")
+	fs.Var(&o.rewrites, "rewrite", "rewrite discovered URLs matching REGEXP before they are queued, given as REGEXP=>REPLACEMENT with $1 for groups (repeatable)")
+	fs.StringVar(&o.strategy, "strategy", "bfs", "crawl order: bfs (breadth-first) or dfs (depth-first)")
+	fs.Var(&o.priorities, "priority", "crawl URLs matching REGEXP before those with lower priority, given as REGEXP=N; URLs matching no pattern have priority 0 and the first matching pattern counts (repeatable)")
+	fs.BoolVar(&o.json, "json", false, "also write the results, findings and links as JSON (results.json)")
+	fs.StringVar(&o.history, "history", "", "keep a snapshot of the crawl in directory `dir`, for the trends and freshness subcommands")
+	fs.BoolVar(&o.patterns, "patterns", false, "list URL templates like /blog/{year}/{slug} with their pages, average depth and error rate (patterns.txt in the output)")
+	fs.BoolVar(&o.checkAliases, "check-aliases", false, "request pages on http (for https sites) and on the www alias of the host (or the bare host) and report those not redirecting to the crawled page in one hop")
+	fs.BoolVar(&o.checkRobots, "check-robots", false, "report linked URLs and used stylesheets, scripts and images that robots.txt disallows")
+	fs.BoolVar(&o.checkCompression, "check-compression", false, "report text responses, also the stylesheets and scripts of the site, sent without or with poor compression, by content type and directory")
+	fs.BoolVar(&o.renderBlocking, "render-blocking", false, "report pages loading stylesheets and scripts in the head without async, defer or media, with their count and download size")
+	fs.BoolVar(&o.checkLastmod, "check-lastmod", false, "compare the lastmod of the pages in the sitemaps with their Last-Modified header and, with -history, with content changes since the previous crawl")
+	fs.BoolVar(&o.checkMobile, "check-mobile", false, "crawl with a desktop user agent, fetch each page again with a mobile one and report differing status, canonical, content and links")
+	fs.BoolVar(&o.checkCerts, "check-certs", false, "fetch the TLS certificates of the crawled hosts, list their expiry, issuer and names (certs.txt in the output) and report expired or expiring ones, names not covered and incomplete chains")
+	fs.DurationVar(&o.certWarn, "cert-warn", 30*24*time.Hour, "with -check-certs, report certificates expiring within this time")
+	fs.BoolVar(&o.checkIcons, "check-icons", false, "fetch declared favicons and touch icons and report missing, broken or non-image ones")
+	fs.BoolVar(&o.check404, "check-404", false, "request a missing URL first, report if it is not a 404 or 410 and pages that look like it")
+	fs.StringVar(&o.robotsAgent, "robots-agent", "*", "user-agent whose robots.txt rules -check-robots and -indexability apply")
+	fs.IntVar(&o.aliasSample, "alias-sample", 0, "with -check-aliases, only check this many pages (0 for all)")
+	fs.BoolVar(&o.sitemap, "sitemap", false, "write sitemap.xml with the indexable pages to the output")
+	fs.BoolVar(&o.sitemapImages, "sitemap-images", false, "include the images of each page in the sitemap")
+	fs.BoolVar(&o.sitemapVideos, "sitemap-videos", false, "include the videos of each page (video elements, known players, VideoObject data) in the sitemap")
+	fs.BoolVar(&o.sitemapHreflang, "sitemap-hreflang", false, "include the hreflang alternates of each page in the sitemap")
+	fs.StringVar(&o.sitemapNews, "sitemap-news", "", "write news-sitemap.xml with the articles of the last 48 hours for this publication name")
+	fs.StringVar(&o.newsLanguage, "news-language", "en", "language of the articles in the news sitemap that do not declare one")
+	fs.BoolVar(&o.outlinks, "outlinks", false, "list the domains linked from the site with link and page counts (outlinks.txt in the output)")
+	fs.BoolVar(&o.indexability, "indexability", false, "list for each page robots.txt verdict, meta robots, X-Robots-Tag, canonical and sitemap membership (indexability.txt in the output); robots.txt and the sitemaps are fetched")
+	fs.StringVar(&o.serverRewrites, "server-rewrites", "", "suggest `nginx` or `apache` rewrite rules for http and alias hosts not redirecting, redirect chains, uppercase and trailing slash duplicates (rewrites.conf in the output)")
+	fs.BoolVar(&o.linksCSV, "links-csv", false, "write all internal links as CSV with source, target, anchor text, rel and position in the page (links.csv in the output)")
+	fs.BoolVar(&o.otherLinks, "other-links", false, "list the mailto:, tel:, javascript: and other non-HTTP links with the pages using them (other-links.txt in the output) and report invalid or obfuscated email addresses and phone numbers")
+	fs.BoolVar(&o.documents, "documents", false, "list the linked PDFs, office documents and archives with the pages linking them (documents.txt in the output) and report broken ones")
+	fs.BoolVar(&o.headDocuments, "head-documents", false, "with -documents, send a HEAD request for documents not crawled, to know their status and size")
+	fs.BoolVar(&o.tags, "tags", false, "list the analytics tags and tracking pixels of the pages (tags.txt in the output) and report pages loading one twice")
+	fs.Var(&o.requireTags, "require-tag", "report pages not loading the tag NAME, or NAME=ID for a given account, like google-tag-manager=GTM-XXXX (repeatable)")
+	fs.BoolVar(&o.cookies, "cookies", false, "record the cookies set during the crawl (cookies.txt in the output) and report oversized ones and those missing Secure, HttpOnly or SameSite")
+	fs.BoolVar(&o.thirdParties, "third-parties", false, "list the providers of scripts and frames from other sites, with the URL templates of the pages embedding them (third-parties.txt in the output)")
+	fs.BoolVar(&o.richResults, "rich-results", false, "list for each URL template how many pages are eligible for the rich results they declare and how many are broken (rich-results.txt in the output)")
+	fs.IntVar(&o.top, "top", 0, "list the N slowest and N largest pages and the N with the most elements or markup errors (top.txt in the output), 0 to disable")
+	fs.DurationVar(&o.slowThreshold, "slow-threshold", 0, "report pages taking longer than this to download, 0 to disable")
+	fs.Int64Var(&o.heavyThreshold, "heavy-threshold", 0, "report pages larger than this many bytes, 0 to disable")
+	fs.BoolVar(&o.breadcrumbs, "breadcrumbs", false, "check breadcrumb markup and navigation: dead links, items not above the page, markup not matching what is shown, pages without them where most of their directory has them")
+	fs.BoolVar(&o.anchorText, "anchor-text", false, "report internal pages linked with generic anchor text, like \"click here\", \"read more\" or a bare URL, or with none")
+	fs.BoolVar(&o.domSize, "dom-size", false, "report pages with more than 1500 elements, nested deeper than 32 levels or less than 10% visible text")
+	fs.BoolVar(&o.markup, "markup", false, "report broken HTML that can hide links and metadata from parsers: unclosed elements, stray end tags, content after </html>, repeated html, head or body and elements ending the head early")
+	fs.BoolVar(&o.sri, "sri", false, "report scripts and stylesheets from other sites loaded without a Subresource Integrity hash, or with one but no crossorigin attribute")
+	fs.BoolVar(&o.csp, "csp", false, "report pages without a Content-Security-Policy, policies allowing unsafe-inline or unsafe-eval and the scripts, stylesheets, images and frames of each page its policy forbids")
+	fs.BoolVar(&o.duplicateContent, "duplicate-content", false, "report pages with the same main content, without navigation, headers and footers, as another page not declared as their canonical")
+	fs.BoolVar(&o.mobileFriendly, "mobile-friendly", false, "report pages likely not mobile-friendly: missing or fixed-width viewport, disabled zoom, wide fixed-width elements and, with -check-robots, stylesheets and scripts blocked by robots.txt")
+	fs.BoolVar(&o.a11y, "a11y", false, "report accessibility problems: missing alt text, labels and document language, empty links and buttons, duplicate ids")
+	fs.StringVar(&o.readabilityBand, "readability-band", "", "report pages whose Flesch reading ease is outside MIN-MAX, e.g. 30-80")
+	fs.BoolVar(&o.terms, "terms", false, "list the top terms and phrases of the site and of each page and report pages sharing their top term (terms.txt in the output)")
+	fs.StringVar(&o.stopwords, "stopwords", "", "file of extra words, one per line, to leave out of -terms")
+	fs.Var(&o.spellDicts, "spell", "check the spelling of page text with the word list (or hunspell .dic) FILE for LANG, given as LANG=FILE; the first is used for other languages (repeatable)")
+	fs.StringVar(&o.spellIgnore, "spell-ignore", "", "file of words, one per line, never reported as misspelled")
+	fs.BoolVar(&o.deterministic, "deterministic", false, "crawl with one worker in a stable order, sort all output and fix timestamps, so that crawls of the same site give identical output")
+	fs.BoolVar(&o.quiet, "quiet", false, "do not print crawl progress to stderr")
+	fs.StringVar(&o.logFormat, "log-format", "text", "log format: text or json")
+	fs.StringVar(&o.logLevel, "log-level", "info", "minimum log level: debug, info, warn or error")
+	fs.StringVar(&o.errorLog, "error-log", "", "write only warnings and errors, as JSON records, to this file (- for stderr); implies -quiet")
+	return o
+}
+
+// validate checks the options for mistakes that would otherwise
+// only show up during or after a long crawl. All problems found
+// are returned together.
+func (o *options) validate() error {
+	var errs []string
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+	if len(o.seeds) == 0 && o.resume == "" {
+		fail("no seed URL given")
+	}
+	for _, s := range o.seeds {
+		u, err := nurl.Parse(s)
+		if err != nil {
+			fail("seed %s: %s", s, err)
+			continue
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("seed %s: not an absolute HTTP URL", s)
+		}
+	}
+	if o.workers < 1 {
+		fail("workers must be at least 1")
+	}
+	for _, re := range append(append(append([]string{}, o.include...), o.exclude...), o.ciNoindex...) {
+		if _, err := regexp.Compile(re); err != nil {
+			fail("invalid regexp: %s", err)
+		}
+	}
+	for _, h := range o.headers {
+		if _, _, err := parseHeader(h); err != nil {
+			fail("%s", err)
+		}
+	}
+	if o.record != "" && o.replay != "" {
+		fail("record and replay cannot be used together")
+	}
+	if _, ok := strategies[o.strategy]; !ok {
+		fail("unknown strategy %s", o.strategy)
+	}
+	if o.checkCerts && o.replay != "" {
+		fail("check-certs cannot be used with replay")
+	}
+	if o.certWarn < 0 {
+		fail("cert-warn cannot be negative")
+	}
+	if o.top < 0 || o.slowThreshold < 0 || o.heavyThreshold < 0 {
+		fail("top, slow-threshold and heavy-threshold cannot be negative")
+	}
+	switch o.httpVersion {
+	case "auto", "1.1", "2":
+	case "3":
+		fail("%s", errHTTP3)
+	default:
+		fail("unknown HTTP version %s", o.httpVersion)
+	}
+	if _, ok := serverRewriters[o.serverRewrites]; !ok && o.serverRewrites != "" {
+		fail("unknown web server %s", o.serverRewrites)
+	}
+	if _, ok := ipFamilies[o.ipFamily]; !ok {
+		fail("unknown IP family %s", o.ipFamily)
+	}
+	if o.ciRegressions && o.history == "" {
+		fail("ci-regressions requires history")
+	}
+	if o.maxDuration < 0 {
+		fail("max-duration cannot be negative")
+	}
+	if o.maxBandwidth < 0 || o.maxBytes < 0 {
+		fail("max-bandwidth and max-bytes cannot be negative")
+	}
+	if o.maxPages < 0 {
+		fail("max-pages cannot be negative")
+	}
+	if o.headDocuments && !o.documents {
+		fail("head-documents requires documents")
+	}
+	if len(o.sectionBudgets) > 0 && o.maxPages == 0 {
+		fail("section-budget requires max-pages")
+	}
+	if _, err := newPageBudget(o.maxPages, o.sectionBudgets); err != nil {
+		fail("%s", err)
+	}
+	if o.sample < 0 {
+		fail("sample cannot be negative")
+	}
+	if o.trapLimit < 0 {
+		fail("trap-limit cannot be negative")
+	}
+	if (o.sitemapImages || o.sitemapVideos || o.sitemapHreflang) && !o.sitemap {
+		fail("sitemap-images, sitemap-videos and sitemap-hreflang require sitemap")
+	}
+	if o.readabilityBand != "" {
+		if _, _, err := parseBand(o.readabilityBand); err != nil {
+			fail("%s", err)
+		}
+	}
+	for _, t := range o.requireTags {
+		if _, err := newRequiredTag(t); err != nil {
+			fail("%s", err)
+		}
+	}
+	if o.aliasSample < 0 {
+		fail("alias-sample cannot be negative")
+	}
+	if o.rate < 0 {
+		fail("rate cannot be negative")
+	}
+	switch o.cruxForm {
+	case "", "PHONE", "DESKTOP", "TABLET":
+	default:
+		fail("unknown CrUX form factor %s", o.cruxForm)
+	}
+	if o.gscSite != "" && o.gscToken == "" {
+		fail("gsc-site requires gsc-token")
+	}
+	if (o.indexNowKey != "" || len(o.pings) > 0) && !o.sitemap && o.history == "" {
+		fail("indexnow-key and ping require sitemap or history")
+	}
+	if o.esURL != "" {
+		if _, err := nurl.Parse(o.esURL); err != nil {
+			fail("es-url: %s", err)
+		}
+	}
+	if o.bqTable != "" {
+		if len(strings.Split(o.bqTable, ".")) != 3 {
+			fail("bq-table %s is not in the form project.dataset.table", o.bqTable)
+		}
+		if o.bqToken == "" {
+			fail("bq-table requires bq-token")
+		}
+	}
+	if _, ok := resultOrders[o.sortBy]; !ok && o.sortBy != "" {
+		fail("unknown sort order %s", o.sortBy)
+	}
+	if o.stream && (o.sortBy != "" || o.group || o.fold) {
+		fail("results cannot be sorted or grouped when streamed")
+	}
+	if o.group && o.fold {
+		fail("group and fold cannot be used together")
+	}
+	switch o.logFormat {
+	case "text", "json":
+	default:
+		fail("unknown log format %s", o.logFormat)
+	}
+	if _, err := parseLevel(o.logLevel); err != nil {
+		fail("%s", err)
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// parseHeader splits a "Name: value" header line.
+func parseHeader(h string) (string, string, error) {
+	i := strings.Index(h, ":")
+	if i <= 0 {
+		return "", "", fmt.Errorf("header %q is not in the form \"Name: value\"", h)
+	}
+	return strings.TrimSpace(h[:i]), strings.TrimSpace(h[i+1:]), nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/mail"
+	nurl "net/url"
+	"sort"
+	"strings"
+)
+
+// otherScheme returns the scheme of the link a if it is not an
+// HTTP one, like "mailto" or "tel", or an empty string.
+func otherScheme(a anchor) string {
+	u, err := nurl.Parse(strings.TrimSpace(a.URL))
+	if err != nil || u.Scheme == "" || u.Scheme == "http" || u.Scheme == "https" {
+		return ""
+	}
+	return strings.ToLower(u.Scheme)
+}
+
+// otherLink is a link with a non-HTTP scheme and the pages using it.
+type otherLink struct {
+	href  string
+	count int
+	pages []string
+}
+
+// otherLinks returns the links of the crawled pages with non-HTTP
+// schemes by scheme, in the order first found.
+func (r *report) otherLinks() map[string][]*otherLink {
+	byScheme := make(map[string][]*otherLink)
+	byHref := make(map[string]*otherLink)
+	for _, res := range r.sorted() {
+		for _, a := range res.Anchors {
+			scheme := otherScheme(a)
+			if scheme == "" {
+				continue
+			}
+			href := strings.TrimSpace(a.Href)
+			l, ok := byHref[href]
+			if !ok {
+				l = &otherLink{href: href}
+				byHref[href] = l
+				byScheme[scheme] = append(byScheme[scheme], l)
+			}
+			l.count++
+			if n := len(l.pages); n == 0 || l.pages[n-1] != res.URL {
+				l.pages = append(l.pages, res.URL)
+			}
+		}
+	}
+	return byScheme
+}
+
+// writeOtherLinks prints the non-HTTP links by scheme, each with
+// how often and where it is used, then how many each page has.
+func (r *report) writeOtherLinks(w io.Writer) error {
+	byScheme := r.otherLinks()
+	schemes := make([]string, 0, len(byScheme))
+	for s := range byScheme {
+		schemes = append(schemes, s)
+	}
+	sort.Strings(schemes)
+	for _, s := range schemes {
+		if _, err := fmt.Fprintf(w, "%s (%d)\n", s, len(byScheme[s])); err != nil {
+			return err
+		}
+		for _, l := range byScheme[s] {
+			if _, err := fmt.Fprintf(w, "\t%s\t%d links\t%s\n", l.href, l.count, pageList(l.pages)); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "pages\n"); err != nil {
+		return err
+	}
+	for _, res := range r.sorted() {
+		counts := make(map[string]int)
+		for _, a := range res.Anchors {
+			if s := otherScheme(a); s != "" {
+				counts[s]++
+			}
+		}
+		if len(counts) == 0 {
+			continue
+		}
+		parts := make([]string, 0, len(counts))
+		for _, s := range schemes {
+			if n := counts[s]; n > 0 {
+				parts = append(parts, fmt.Sprintf("%s %d", s, n))
+			}
+		}
+		if _, err := fmt.Fprintf(w, "\t%s\t%s\n", res.URL, strings.Join(parts, ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// telDigits returns the number of digits of the phone number s,
+// or -1 if it has characters a number cannot have.
+func telDigits(s string) int {
+	n := 0
+	for i, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			n++
+		case r == '+' && i == 0:
+		case strings.ContainsRune(" -.()", r):
+		default:
+			return -1
+		}
+	}
+	return n
+}
+
+// checkOtherLinks adds a "mailto-invalid" finding for each mailto
+// link without a valid address, as obfuscated ones like
+// "name[at]example.com", and a "tel-invalid" finding for each tel
+// link that is not a phone number.
+func checkOtherLinks(rep *report) {
+	byScheme := rep.otherLinks()
+	for _, l := range byScheme["mailto"] {
+		u, err := nurl.Parse(l.href)
+		if err != nil {
+			rep.add("mailto-invalid", l.href, linkedFrom(l.pages))
+			continue
+		}
+		addrs := u.Opaque
+		if addrs == "" {
+			addrs = u.Query().Get("to")
+		}
+		if s, err := nurl.PathUnescape(addrs); err == nil {
+			addrs = s
+		}
+		if _, err := mail.ParseAddressList(addrs); err != nil {
+			rep.add("mailto-invalid", l.href, linkedFrom(l.pages))
+		}
+	}
+	for _, l := range byScheme["tel"] {
+		num := l.href[strings.Index(l.href, ":")+1:]
+		if s, err := nurl.PathUnescape(num); err == nil {
+			num = s
+		}
+		// Extensions and other parameters follow a semicolon.
+		if i := strings.Index(num, ";"); i >= 0 {
+			num = num[:i]
+		}
+		if n := telDigits(num); n < 3 || n > 15 {
+			rep.add("tel-invalid", l.href, linkedFrom(l.pages))
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	nurl "net/url"
+	"sort"
+)
+
+// outlinkDomain is the inventory of the links to one other site.
+type outlinkDomain struct {
+	Domain  string
+	Links   int
+	Pages   int    // linking pages
+	Example string // a linking page
+}
+
+// outlinks groups the links to other sites by domain, the most
+// linked first.
+func (r *report) outlinks() []*outlinkDomain {
+	domains := make(map[string]*outlinkDomain)
+	for _, res := range r.sorted() {
+		seen := make(map[string]bool)
+		for _, l := range res.External {
+			u, err := nurl.Parse(l)
+			if err != nil {
+				continue
+			}
+			host := u.Hostname()
+			d, ok := domains[host]
+			if !ok {
+				d = &outlinkDomain{Domain: host, Example: res.URL}
+				domains[host] = d
+			}
+			d.Links++
+			if !seen[host] {
+				seen[host] = true
+				d.Pages++
+			}
+		}
+	}
+	list := make([]*outlinkDomain, 0, len(domains))
+	for _, d := range domains {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Links != list[j].Links {
+			return list[i].Links > list[j].Links
+		}
+		return list[i].Domain < list[j].Domain
+	})
+	return list
+}
+
+// writeOutlinks prints one line per linked domain with the number
+// of links, of linking pages and an example linking page.
+func (r *report) writeOutlinks(w io.Writer) error {
+	for _, d := range r.outlinks() {
+		_, err := fmt.Fprintf(w, "%s\t%d links\t%d pages\te.g. %s\n", d.Domain, d.Links, d.Pages, d.Example)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,570 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	nurl "net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// analyzer extracts data from the tokens of a page. All the
+// analyzers of a page share a single tokenization pass.
+type analyzer interface {
+	// token is called for each token of the page, in order.
+	token(t *html.Token)
+	// finish stores what was found into the result of the page.
+	finish(res *result)
+}
+
+type page struct {
+	r         io.Reader
+	log       *slog.Logger
+	url       *nurl.URL
+	tok       *html.Tokenizer
+	urls      []string
+	external  []string // links to other sites
+	analyzers []analyzer
+	// ld is the decoded JSON-LD of the page, complete only
+	// once all tokens have been seen.
+	ld []interface{}
+}
+
+// newPage prepares the parsing of the page at url, whose
+// content is read from r.
+func newPage(r io.Reader, url *nurl.URL, log *slog.Logger) *page {
+	p := &page{
+		r:    r,
+		log:  log,
+		url:  url,
+		tok:  html.NewTokenizer(r),
+		urls: make([]string, 0),
+	}
+	p.analyzers = []analyzer{
+		&linkAnalyzer{p: p},
+		&headAnalyzer{p: p},
+		&headingAnalyzer{},
+		&ldAnalyzer{p: p},
+		&idAnalyzer{},
+		&imageAnalyzer{p: p},
+		&resourceAnalyzer{p: p},
+		newA11yAnalyzer(),
+		&textAnalyzer{},
+		&lintAnalyzer{},
+		&domAnalyzer{},
+		&markupAnalyzer{},
+		&viewportAnalyzer{},
+		&tagAnalyzer{},
+		&breadcrumbAnalyzer{p: p},
+		&iconAnalyzer{p: p},
+		&videoAnalyzer{p: p},
+		&articleAnalyzer{p: p},
+	}
+	return p
+}
+
+// normalize resolves the link surl found on the page and
+// returns it only if it is a crawlable link to the same host.
+func (p *page) normalize(surl string) (string, error) {
+	ref, err := nurl.Parse(strings.TrimSpace(surl))
+	if err != nil {
+		return "", err
+	}
+	// Skip internal link, only fragment
+	if ref.Scheme == "" && ref.Host == "" && ref.Path == "" && ref.RawQuery == "" {
+		return "", nil
+	}
+	url := p.url.ResolveReference(ref)
+	// Skip unhandled schemes
+	if url.Scheme != "http" && url.Scheme != "https" {
+		return "", nil
+	}
+	// Ignore links to other domains
+	// TODO: be more lax about 80 and 443 with right scheme
+	// and fold the www alias into the host of the page
+	if url.Host == aliasHost(p.url.Host) {
+		url.Host = p.url.Host
+	}
+	if url.Host != p.url.Host {
+		url.Fragment = ""
+		p.external = append(p.external, url.String())
+		return "", nil
+	}
+	if url.Scheme != p.url.Scheme {
+		return "", fmt.Errorf("schema is %s, it was %s", url.Scheme, p.url.Scheme)
+	}
+	// Opaque: ignored
+	// User: ignored
+	url.Path = path.Clean("/" + url.Path)
+	if url.Path == "/" {
+		url.Path = ""
+	}
+	url.Fragment = ""
+	return url.String(), nil
+}
+
+// aliasHost returns the www alias of host: host without
+// "www." if it has it, with it otherwise.
+func aliasHost(host string) string {
+	if strings.HasPrefix(host, "www.") {
+		return strings.TrimPrefix(host, "www.")
+	}
+	return "www." + host
+}
+
+// resolve makes the reference surl absolute, without any
+// other normalization.
+func (p *page) resolve(surl string) string {
+	ref, err := nurl.Parse(strings.TrimSpace(surl))
+	if err != nil {
+		return surl
+	}
+	return p.url.ResolveReference(ref).String()
+}
+
+// parse tokenizes the whole page feeding all analyzers.
+func (p *page) parse() error {
+	for {
+		tt := p.tok.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		t := p.tok.Token()
+		for _, a := range p.analyzers {
+			a.token(&t)
+		}
+	}
+	if err := p.tok.Err(); err != io.EOF {
+		return fmt.Errorf("cannot parse HTML: %s", err)
+	}
+	return nil
+}
+
+// finish stores all extracted data into res.
+func (p *page) finish(res *result) {
+	res.Links = p.urls
+	res.External = p.external
+	for _, a := range p.analyzers {
+		a.finish(res)
+	}
+}
+
+// attr returns the value of the attribute key of t.
+func attr(t *html.Token, key string) (string, bool) {
+	for _, a := range t.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// textCapture collects the text inside an element.
+type textCapture struct {
+	tag   string
+	depth int
+	text  strings.Builder
+}
+
+// start begins capturing the text inside tag.
+func (c *textCapture) start(tag string) {
+	c.tag = tag
+	c.depth = 1
+	c.text.Reset()
+}
+
+// active returns true while inside the element.
+func (c *textCapture) active() bool {
+	return c.depth > 0
+}
+
+// token feeds t to the capture and returns true when the
+// element has been closed and its text is complete.
+func (c *textCapture) token(t *html.Token) bool {
+	if c.depth == 0 {
+		return false
+	}
+	switch t.Type {
+	case html.TextToken:
+		c.text.WriteString(t.Data)
+	case html.StartTagToken:
+		if t.Data == c.tag {
+			c.depth++
+		}
+	case html.EndTagToken:
+		if t.Data == c.tag {
+			c.depth--
+			return c.depth == 0
+		}
+	}
+	return false
+}
+
+// String returns the captured text with collapsed spaces.
+func (c *textCapture) String() string {
+	return strings.Join(strings.Fields(c.text.String()), " ")
+}
+
+// anchor is a link found on a page.
+type anchor struct {
+	Href   string // as written in the page
+	URL    string // absolute URL
+	Text   string
+	Rel    string
+	Target string
+	// Position is the innermost landmark containing the link,
+	// like "nav" or "footer", or "body" if there is none.
+	Position string
+}
+
+// landmarks are the elements that tell where a link is placed,
+// with the ARIA roles that mean the same.
+var landmarks = map[string]string{
+	"header": "header", "nav": "nav", "main": "main", "aside": "aside", "footer": "footer",
+	"banner": "header", "navigation": "nav", "complementary": "aside", "contentinfo": "footer",
+}
+
+// linkAnalyzer collects anchors and the crawlable URLs
+// they point to.
+type linkAnalyzer struct {
+	p       *page
+	anchors []anchor
+	cur     *anchor
+	text    textCapture
+	// open are the elements containing the current token that
+	// are landmarks, innermost last, as element and landmark.
+	open [][2]string
+}
+
+// landmark keeps track of the landmarks containing t.
+func (a *linkAnalyzer) landmark(t *html.Token) {
+	switch t.Type {
+	case html.StartTagToken:
+		lm := landmarks[t.Data]
+		if role, ok := attr(t, "role"); ok {
+			lm = landmarks[strings.ToLower(strings.TrimSpace(role))]
+		}
+		if lm != "" {
+			a.open = append(a.open, [2]string{t.Data, lm})
+		}
+	case html.EndTagToken:
+		for i := len(a.open) - 1; i >= 0; i-- {
+			if a.open[i][0] == t.Data {
+				a.open = a.open[:i]
+				break
+			}
+		}
+	}
+}
+
+func (a *linkAnalyzer) token(t *html.Token) {
+	a.landmark(t)
+	if a.cur != nil {
+		// Images used as links are described by their alt text.
+		if t.Data == "img" && (t.Type == html.StartTagToken || t.Type == html.SelfClosingTagToken) {
+			if alt, ok := attr(t, "alt"); ok {
+				a.text.text.WriteString(" " + alt + " ")
+			}
+		}
+		if a.text.token(t) {
+			a.cur.Text = a.text.String()
+			a.anchors = append(a.anchors, *a.cur)
+			a.cur = nil
+		}
+		return
+	}
+	if t.Type != html.StartTagToken || t.Data != "a" {
+		return
+	}
+	href, ok := attr(t, "href")
+	if !ok {
+		return
+	}
+	rel, _ := attr(t, "rel")
+	target, _ := attr(t, "target")
+	position := "body"
+	if n := len(a.open); n > 0 {
+		position = a.open[n-1][1]
+	}
+	a.cur = &anchor{Href: href, URL: a.p.resolve(href), Rel: rel, Target: target, Position: position}
+	a.text.start("a")
+	url, err := a.p.normalize(href)
+	if err != nil {
+		a.p.log.Warn("cannot handle link", "link", href, "err", err)
+		return
+	}
+	if url != "" {
+		a.p.urls = append(a.p.urls, url)
+	}
+}
+
+func (a *linkAnalyzer) finish(res *result) {
+	// Anchor never closed, take the text found so far.
+	if a.cur != nil {
+		a.cur.Text = a.text.String()
+		a.anchors = append(a.anchors, *a.cur)
+	}
+	res.Anchors = a.anchors
+}
+
+// meta is a meta tag, identified by name, property or http-equiv.
+type meta struct {
+	Name    string
+	Content string
+}
+
+// image is an image embedded in a page.
+type image struct {
+	URL string
+	Alt string
+}
+
+// imageAnalyzer collects the images of the page.
+type imageAnalyzer struct {
+	p      *page
+	images []image
+}
+
+func (a *imageAnalyzer) token(t *html.Token) {
+	if t.Data != "img" || (t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken) {
+		return
+	}
+	src, ok := attr(t, "src")
+	if !ok || src == "" || strings.HasPrefix(src, "data:") {
+		return
+	}
+	alt, _ := attr(t, "alt")
+	a.images = append(a.images, image{URL: a.p.resolve(src), Alt: alt})
+}
+
+func (a *imageAnalyzer) finish(res *result) {
+	res.Images = a.images
+}
+
+// resource is a stylesheet or script a page loads.
+type resource struct {
+	Kind string // css or js
+	URL  string
+	// Blocking is set if the browser waits for the resource
+	// before rendering the page: it is loaded in the head,
+	// without async or defer for scripts or media for styles.
+	Blocking bool
+	// Integrity is the Subresource Integrity hash, CrossOrigin
+	// set if the crossorigin attribute is.
+	Integrity   string
+	CrossOrigin bool
+}
+
+// resourceAnalyzer collects the stylesheets, scripts and frames
+// of the page.
+type resourceAnalyzer struct {
+	p         *page
+	resources []resource
+	frames    []string
+	body      bool // past the head
+}
+
+func (a *resourceAnalyzer) token(t *html.Token) {
+	if t.Type == html.EndTagToken && t.Data == "head" {
+		a.body = true
+	}
+	if t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken {
+		return
+	}
+	switch t.Data {
+	case "body":
+		a.body = true
+	case "link":
+		rel, _ := attr(t, "rel")
+		href, ok := attr(t, "href")
+		if ok && href != "" && strings.EqualFold(strings.TrimSpace(rel), "stylesheet") {
+			media, _ := attr(t, "media")
+			media = strings.ToLower(strings.TrimSpace(media))
+			blocking := !a.body && (media == "" || media == "all" || media == "screen")
+			a.resources = append(a.resources, a.subresource(t, "css", href, blocking))
+		}
+	case "script":
+		if src, ok := attr(t, "src"); ok && src != "" {
+			_, async := attr(t, "async")
+			_, deferred := attr(t, "defer")
+			typ, _ := attr(t, "type")
+			blocking := !a.body && !async && !deferred && !strings.EqualFold(typ, "module")
+			a.resources = append(a.resources, a.subresource(t, "js", src, blocking))
+		}
+	case "iframe":
+		if src, ok := attr(t, "src"); ok && src != "" {
+			a.frames = append(a.frames, a.p.resolve(src))
+		}
+	}
+}
+
+func (a *resourceAnalyzer) finish(res *result) {
+	res.Resources = a.resources
+	res.Frames = a.frames
+}
+
+// subresource returns the resource of kind at href loaded by t.
+func (a *resourceAnalyzer) subresource(t *html.Token, kind, href string, blocking bool) resource {
+	integrity, _ := attr(t, "integrity")
+	_, cors := attr(t, "crossorigin")
+	return resource{
+		Kind:        kind,
+		URL:         a.p.resolve(href),
+		Blocking:    blocking,
+		Integrity:   strings.TrimSpace(integrity),
+		CrossOrigin: cors,
+	}
+}
+
+// headAnalyzer collects title, meta tags and canonical links,
+// and the language of the document.
+type headAnalyzer struct {
+	p          *page
+	lang       string
+	title      textCapture
+	titles     []string
+	metas      []meta
+	canonicals []string
+	hreflangs  []hreflang
+	svg        int // open svg elements, whose titles are not the page's
+}
+
+func (a *headAnalyzer) token(t *html.Token) {
+	if a.title.active() {
+		if a.title.token(t) {
+			a.titles = append(a.titles, a.title.String())
+		}
+		return
+	}
+	if t.Type == html.EndTagToken && t.Data == "svg" && a.svg > 0 {
+		a.svg--
+	}
+	if t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken {
+		return
+	}
+	switch t.Data {
+	case "html":
+		a.lang, _ = attr(t, "lang")
+	case "svg":
+		if t.Type == html.StartTagToken {
+			a.svg++
+		}
+	case "title":
+		if t.Type == html.StartTagToken && a.svg == 0 {
+			a.title.start("title")
+		}
+	case "meta":
+		name, ok := attr(t, "name")
+		if !ok {
+			if name, ok = attr(t, "property"); !ok {
+				name, ok = attr(t, "http-equiv")
+			}
+		}
+		if !ok {
+			return
+		}
+		content, _ := attr(t, "content")
+		a.metas = append(a.metas, meta{Name: strings.ToLower(name), Content: content})
+	case "link":
+		rel, _ := attr(t, "rel")
+		href, ok := attr(t, "href")
+		if ok && strings.EqualFold(strings.TrimSpace(rel), "canonical") {
+			a.canonicals = append(a.canonicals, a.p.resolve(href))
+		}
+		lang, hasLang := attr(t, "hreflang")
+		if ok && hasLang && strings.EqualFold(strings.TrimSpace(rel), "alternate") {
+			a.hreflangs = append(a.hreflangs, hreflang{Lang: lang, URL: a.p.resolve(href)})
+		}
+	}
+}
+
+func (a *headAnalyzer) finish(res *result) {
+	if len(a.titles) > 0 {
+		res.Title = a.titles[0]
+	}
+	res.Titles = a.titles
+	if len(a.canonicals) > 0 {
+		res.Canonical = a.canonicals[0]
+	}
+	res.Canonicals = a.canonicals
+	res.Metas = a.metas
+	res.Lang = a.lang
+	res.Hreflangs = a.hreflangs
+	for _, m := range a.metas {
+		switch m.Name {
+		case "robots":
+			if res.Robots != "" {
+				res.Robots += ", "
+			}
+			res.Robots += m.Content
+		case "description":
+			res.Description = m.Content
+		}
+	}
+}
+
+// hreflang is an alternate version of a page in another language.
+type hreflang struct {
+	Lang string
+	URL  string
+}
+
+// heading is the text of a h1-h6 element.
+type heading struct {
+	Level int
+	Text  string
+}
+
+// headingAnalyzer collects the outline of the page.
+type headingAnalyzer struct {
+	headings []heading
+	cur      textCapture
+	level    int
+}
+
+func (a *headingAnalyzer) token(t *html.Token) {
+	if a.cur.active() {
+		if a.cur.token(t) {
+			a.headings = append(a.headings, heading{Level: a.level, Text: a.cur.String()})
+		}
+		return
+	}
+	if t.Type != html.StartTagToken || len(t.Data) != 2 || t.Data[0] != 'h' {
+		return
+	}
+	if l := t.Data[1]; l >= '1' && l <= '6' {
+		a.level = int(l - '0')
+		a.cur.start(t.Data)
+	}
+}
+
+func (a *headingAnalyzer) finish(res *result) {
+	res.Headings = a.headings
+}
+
+// idAnalyzer collects the ids of all elements and the names
+// of anchors, that fragments of links can point to.
+type idAnalyzer struct {
+	ids []string
+}
+
+func (a *idAnalyzer) token(t *html.Token) {
+	if t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken {
+		return
+	}
+	if id, ok := attr(t, "id"); ok && id != "" {
+		a.ids = append(a.ids, id)
+	}
+	if t.Data == "a" {
+		if name, ok := attr(t, "name"); ok && name != "" {
+			a.ids = append(a.ids, name)
+		}
+	}
+}
+
+func (a *idAnalyzer) finish(res *result) {
+	res.IDs = a.ids
+}
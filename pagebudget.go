@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pageSection is a share of the page budget for the paths under
+// a prefix; the empty prefix is the rest of the site.
+type pageSection struct {
+	prefix string
+	pages  int // budget
+	used   int
+	held   []string // URLs over budget, in the order they came
+}
+
+// pageBudget limits how many pages are crawled, split across
+// sections of the site so that each is covered. The pages a
+// section does not use go to the others at the end of the crawl.
+type pageBudget struct {
+	max      int
+	sections []*pageSection // longest prefix first, rest last
+	// shared is set once the sections can use any page left.
+	shared bool
+}
+
+// newPageBudget splits max pages among the sections given as
+// PREFIX=PERCENT; the rest of the site gets what is left.
+func newPageBudget(max int, sections []string) (*pageBudget, error) {
+	b := &pageBudget{max: max}
+	total := 0
+	for _, s := range sections {
+		i := strings.LastIndex(s, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("section budget %q is not PREFIX=PERCENT", s)
+		}
+		pct, err := strconv.Atoi(strings.TrimSuffix(s[i+1:], "%"))
+		if err != nil || pct < 0 {
+			return nil, fmt.Errorf("section budget %q is not PREFIX=PERCENT", s)
+		}
+		total += pct
+		b.sections = append(b.sections, &pageSection{prefix: s[:i], pages: max * pct / 100})
+	}
+	if total > 100 {
+		return nil, fmt.Errorf("section budgets add up to %d%%", total)
+	}
+	sort.SliceStable(b.sections, func(i, j int) bool {
+		return len(b.sections[i].prefix) > len(b.sections[j].prefix)
+	})
+	rest := max
+	for _, s := range b.sections {
+		rest -= s.pages
+	}
+	b.sections = append(b.sections, &pageSection{pages: rest})
+	return b, nil
+}
+
+// section returns the section of url.
+func (b *pageBudget) section(url string) *pageSection {
+	path := url
+	if u, err := nurl.Parse(url); err == nil {
+		path = u.EscapedPath()
+	}
+	for _, s := range b.sections {
+		if strings.HasPrefix(path, s.prefix) {
+			return s
+		}
+	}
+	return b.sections[len(b.sections)-1]
+}
+
+// used returns how many pages all sections used.
+func (b *pageBudget) used() int {
+	n := 0
+	for _, s := range b.sections {
+		n += s.used
+	}
+	return n
+}
+
+// allow charges url, about to be crawled, to its section. It
+// returns false, holding url back, if the section has used all
+// its budget.
+func (b *pageBudget) allow(url string) bool {
+	s := b.section(url)
+	if s.used >= s.pages && (!b.shared || b.used() >= b.max) {
+		s.held = append(s.held, url)
+		return false
+	}
+	s.used++
+	return true
+}
+
+// release returns the URLs held back, to be crawled with the
+// pages left by the sections that did not use their budget, or
+// nil if there are none left.
+func (b *pageBudget) release() []string {
+	if b.used() >= b.max {
+		return nil
+	}
+	b.shared = true
+	var urls []string
+	for _, s := range b.sections {
+		urls = append(urls, s.held...)
+		s.held = nil
+	}
+	return urls
+}
+
+// waiting returns true if URLs are held back that release can
+// give pages to.
+func (b *pageBudget) waiting() bool {
+	return b.skipped() > 0 && b.used() < b.max
+}
+
+// skipped returns how many URLs were left out for the budget.
+func (b *pageBudget) skipped() int {
+	n := 0
+	for _, s := range b.sections {
+		n += len(s.held)
+	}
+	return n
+}
+
+// report adds a "page-budget-reached" finding for each section
+// that had to leave out URLs, about the first of them.
+func (b *pageBudget) report(rep *report) {
+	for _, s := range b.sections {
+		if len(s.held) == 0 {
+			continue
+		}
+		name := s.prefix
+		if name == "" {
+			name = "rest of the site"
+		}
+		rep.add("page-budget-reached", s.held[0], fmt.Sprintf("%s: %d of %d pages, %d URLs not crawled", name, s.used, b.max, len(s.held)))
+	}
+}
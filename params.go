@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"log/slog"
+	nurl "net/url"
+	"sort"
+)
+
+// minParamValue is the length from which parameter values are
+// removed from a page before comparing it, shorter values being
+// too likely to appear in the page by chance.
+const minParamValue = 4
+
+// minSameParam is how many pairs of identical pages, differing
+// only in the value of a parameter, show that it does not change
+// the content.
+const minSameParam = 3
+
+// contentHash returns the SHA-1 of body without the values of the
+// query parameters of url, so that pages repeating their session
+// ID in links still compare identical.
+func contentHash(url string, body []byte) string {
+	if u, err := nurl.Parse(url); err == nil {
+		for _, values := range u.Query() {
+			for _, v := range values {
+				if len(v) >= minParamValue {
+					body = bytes.ReplaceAll(body, []byte(v), nil)
+				}
+			}
+		}
+	}
+	return fmt.Sprintf("%x", sha1.Sum(body))
+}
+
+// paramPage is the first page seen for a URL without a parameter.
+type paramPage struct {
+	url   string
+	value string
+	hash  string
+}
+
+// paramDetector finds query parameters that do not change the
+// content of a page, like session IDs and cache busters: pages
+// whose URLs differ only in the value of such a parameter are
+// identical.
+type paramDetector struct {
+	pages  map[string]map[string]paramPage // by parameter and URL without it
+	same   map[string]int                  // identical pages per parameter
+	differ map[string]bool                 // parameters that change the content
+	sample map[string]string
+}
+
+func newParamDetector() *paramDetector {
+	return &paramDetector{
+		pages:  make(map[string]map[string]paramPage),
+		same:   make(map[string]int),
+		differ: make(map[string]bool),
+		sample: make(map[string]string),
+	}
+}
+
+// observe records the parameters of a crawled page and returns
+// those that have just been found not to change the content.
+func (d *paramDetector) observe(res *result) []string {
+	if !res.page() {
+		return nil
+	}
+	u, err := nurl.Parse(res.URL)
+	if err != nil || u.RawQuery == "" {
+		return nil
+	}
+	found := make([]string, 0)
+	q := u.Query()
+	for name, values := range q {
+		rest := make(nurl.Values)
+		for k, v := range q {
+			if k != name {
+				rest[k] = v
+			}
+		}
+		key := u.Scheme + "://" + u.Host + u.Path + "?" + rest.Encode()
+		if d.pages[name] == nil {
+			d.pages[name] = make(map[string]paramPage)
+		}
+		value := fmt.Sprint(values)
+		first, ok := d.pages[name][key]
+		switch {
+		case !ok:
+			d.pages[name][key] = paramPage{url: res.URL, value: value, hash: res.Hash}
+		case first.value == value:
+		case first.hash != res.Hash:
+			d.differ[name] = true
+		default:
+			if d.same[name] == 0 {
+				d.sample[name] = first.url
+			}
+			d.same[name]++
+			if d.same[name] == minSameParam && !d.differ[name] {
+				found = append(found, name)
+			}
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// params returns the parameters that never changed the content
+// of enough pages.
+func (d *paramDetector) params() []string {
+	list := make([]string, 0)
+	for name, n := range d.same {
+		if n >= minSameParam && !d.differ[name] {
+			list = append(list, name)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// checkParams adds a "session-parameter" finding for each query
+// parameter that only produced identical pages.
+func checkParams(rep *report) {
+	d := newParamDetector()
+	for _, res := range rep.sorted() {
+		d.observe(res)
+	}
+	for _, name := range d.params() {
+		rep.add("session-parameter", d.sample[name],
+			fmt.Sprintf("%s: %d duplicate pages differ only in its value", name, d.same[name]))
+	}
+}
+
+// stripParams removes the query parameters in strip from url.
+func stripParams(url string, strip map[string]bool) string {
+	if len(strip) == 0 {
+		return url
+	}
+	u, err := nurl.Parse(url)
+	if err != nil || u.RawQuery == "" {
+		return url
+	}
+	q := u.Query()
+	changed := false
+	for name := range q {
+		if strip[name] {
+			q.Del(name)
+			changed = true
+		}
+	}
+	if !changed {
+		return url
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// paramStripper is a normalizer that strips the parameters
+// in the map, which can grow during the crawl.
+type paramStripper map[string]bool
+
+func (p paramStripper) normalize(url string) string {
+	return stripParams(url, p)
+}
+
+// learnParams adds to the strip list of the crawler the
+// parameters res shows not to change the content.
+func (c *crawler) learnParams(res *result) {
+	for _, name := range c.params.observe(res) {
+		if !c.strip[name] {
+			slog.Info("stripping session parameter", "param", name, "url", res.URL)
+			c.strip[name] = true
+		}
+	}
+}
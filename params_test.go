@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParamDetector(t *testing.T) {
+	// Each page is "URL HASH", or "URL HASH STATUS" for errors.
+	tests := []struct {
+		name  string
+		pages []string
+		found []string // in the order observe returned them
+		want  []string
+	}{
+		{"one identical pair", []string{
+			"/a?sid=1 x", "/a?sid=2 x",
+		}, nil, nil},
+		{"enough identical pairs", []string{
+			"/a?sid=1 x", "/a?sid=2 x", "/b?sid=1 y", "/b?sid=2 y", "/c?sid=1 z", "/c?sid=2 z",
+		}, []string{"sid"}, []string{"sid"}},
+		{"pairs against the first page", []string{
+			"/a?sid=1 x", "/a?sid=2 x", "/a?sid=3 x", "/a?sid=4 x",
+		}, []string{"sid"}, []string{"sid"}},
+		{"changes content first", []string{
+			"/a?page=1 x", "/a?page=2 y", "/b?page=1 x", "/b?page=2 x", "/c?page=1 x", "/c?page=2 x", "/d?page=1 x", "/d?page=2 x",
+		}, nil, nil},
+		{"changes content later", []string{
+			"/a?page=1 x", "/a?page=2 x", "/b?page=1 y", "/b?page=2 y", "/c?page=1 z", "/c?page=2 z", "/d?page=1 x", "/d?page=2 y",
+		}, []string{"page"}, nil},
+		{"same URL again", []string{
+			"/a?sid=1 x", "/a?sid=1 x", "/a?sid=1 x", "/a?sid=1 x",
+		}, nil, nil},
+		{"other parameters kept apart", []string{
+			"/a?sid=1&q=x x", "/a?sid=2&q=x x", "/a?sid=3&q=y y", "/a?sid=4&q=x x",
+		}, nil, nil},
+		{"errors ignored", []string{
+			"/a?sid=1 x", "/a?sid=2 x 404", "/a?sid=3 x 500", "/a?sid=4 x 404",
+		}, nil, nil},
+		{"no query", []string{
+			"/a x", "/a x", "/a x", "/a x",
+		}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newParamDetector()
+			var found []string
+			for _, p := range tt.pages {
+				f := strings.Fields(p)
+				res := &result{URL: "http://example.test" + f[0], Status: 200, Hash: f[1]}
+				if len(f) > 2 {
+					res.Status, _ = strconv.Atoi(f[2])
+				}
+				found = append(found, d.observe(res)...)
+			}
+			if !reflect.DeepEqual(found, tt.found) {
+				t.Errorf("found %v while crawling, want %v", found, tt.found)
+			}
+			if got := d.params(); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripParams(t *testing.T) {
+	strip := map[string]bool{"sid": true, "utm_source": true}
+	tests := []struct {
+		url, want string
+	}{
+		{"http://example.test/a?sid=1", "http://example.test/a"},
+		{"http://example.test/a?q=x&sid=1&utm_source=y", "http://example.test/a?q=x"},
+		{"http://example.test/a?q=x", "http://example.test/a?q=x"},
+		{"http://example.test/a", "http://example.test/a"},
+	}
+	for _, tt := range tests {
+		if got := stripParams(tt.url, strip); got != tt.want {
+			t.Errorf("stripParams(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// slugThreshold is the number of distinct values at the same place
+// of otherwise equal paths from which they are taken for a {slug}.
+const slugThreshold = 5
+
+// urlTemplate is a group of URLs sharing the same path shape.
+type urlTemplate struct {
+	Pattern string
+	Pages   int
+	Depth   float64 // average
+	Errors  int
+}
+
+// ErrorRate is the fraction of pages of the template that failed.
+func (t *urlTemplate) ErrorRate() float64 {
+	return float64(t.Errors) / float64(t.Pages)
+}
+
+// templateSegment generalizes a path segment made of digits.
+func templateSegment(s string) string {
+	if s == "" || strings.Trim(s, "0123456789") != "" {
+		return s
+	}
+	if len(s) == 4 && (strings.HasPrefix(s, "19") || strings.HasPrefix(s, "20")) {
+		return "{year}"
+	}
+	return "{id}"
+}
+
+// pathTemplates returns the template of each path: numbers become
+// {id} or {year} and segments that take many different values
+// after the same prefix become {slug}.
+func pathTemplates(paths []string) []string {
+	segs := make([][]string, len(paths))
+	maxLen := 0
+	for i, p := range paths {
+		segs[i] = strings.Split(strings.Trim(p, "/"), "/")
+		for j := range segs[i] {
+			segs[i][j] = templateSegment(segs[i][j])
+		}
+		if len(segs[i]) > maxLen {
+			maxLen = len(segs[i])
+		}
+	}
+	for pos := 0; pos < maxLen; pos++ {
+		values := make(map[string]map[string]bool)
+		key := func(s []string) string {
+			return fmt.Sprintf("%d/%s", len(s), strings.Join(s[:pos], "/"))
+		}
+		for _, s := range segs {
+			if pos >= len(s) {
+				continue
+			}
+			k := key(s)
+			if values[k] == nil {
+				values[k] = make(map[string]bool)
+			}
+			values[k][s[pos]] = true
+		}
+		for _, s := range segs {
+			if pos < len(s) && len(values[key(s)]) >= slugThreshold && !strings.HasPrefix(s[pos], "{") {
+				s[pos] = "{slug}"
+			}
+		}
+	}
+	tmpls := make([]string, len(paths))
+	for i, s := range segs {
+		tmpls[i] = "/" + strings.Join(s, "/")
+		if strings.HasSuffix(paths[i], "/") && tmpls[i] != "/" {
+			tmpls[i] += "/"
+		}
+	}
+	return tmpls
+}
+
+// templates groups the results of the report by URL template,
+// largest groups first.
+func (r *report) templates() []*urlTemplate {
+	list := r.sorted()
+	paths := make([]string, len(list))
+	for i, res := range list {
+		paths[i] = urlPath(res.URL)
+	}
+	groups := make(map[string]*urlTemplate)
+	for i, p := range pathTemplates(paths) {
+		t, ok := groups[p]
+		if !ok {
+			t = &urlTemplate{Pattern: p}
+			groups[p] = t
+		}
+		t.Pages++
+		t.Depth += float64(list[i].Depth)
+		if list[i].broken() {
+			t.Errors++
+		}
+	}
+	tmpls := make([]*urlTemplate, 0, len(groups))
+	for _, t := range groups {
+		t.Depth /= float64(t.Pages)
+		tmpls = append(tmpls, t)
+	}
+	sort.Slice(tmpls, func(i, j int) bool {
+		if tmpls[i].Pages != tmpls[j].Pages {
+			return tmpls[i].Pages > tmpls[j].Pages
+		}
+		return tmpls[i].Pattern < tmpls[j].Pattern
+	})
+	return tmpls
+}
+
+// writeTemplates prints one line per URL template with the number
+// of pages, their average depth and how many failed.
+func (r *report) writeTemplates(w io.Writer) error {
+	for _, t := range r.templates() {
+		_, err := fmt.Fprintf(w, "%s\t%d pages\tdepth %.1f\terrors %.0f%%\n",
+			t.Pattern, t.Pages, t.Depth, 100*t.ErrorRate())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// priority raises or lowers the URLs matching a pattern in
+// the order of crawling.
+type priority struct {
+	re    *regexp.Regexp
+	value int
+}
+
+// newPriority parses a rule given as REGEXP=N.
+func newPriority(rule string) (*priority, error) {
+	i := strings.LastIndex(rule, "=")
+	if i <= 0 {
+		return nil, fmt.Errorf("priority %q is not REGEXP=N", rule)
+	}
+	n, err := strconv.Atoi(rule[i+1:])
+	if err != nil {
+		return nil, fmt.Errorf("priority %q is not REGEXP=N", rule)
+	}
+	re, err := regexp.Compile(rule[:i])
+	if err != nil {
+		return nil, fmt.Errorf("cannot compile priority: %s", err)
+	}
+	return &priority{re: re, value: n}, nil
+}
+
+// priority returns the value of the first priority rule
+// that url matches, 0 if none does.
+func (c *crawler) priority(url string) int {
+	for _, p := range c.priorities {
+		if p.re.MatchString(url) {
+			return p.value
+		}
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// progress is a snapshot of the state of a running crawl.
+type progress struct {
+	crawled int
+	queued  int
+	busy    int
+	errors  int
+	paused  bool
+}
+
+// progress returns the current counters of c. The second
+// return value is false if the crawl has already finished.
+func (c *crawler) progress() (progress, bool) {
+	var p progress
+	ok := c.do(func() {
+		p = progress{
+			crawled: len(c.results),
+			busy:    c.nbusy,
+			errors:  c.nerrors,
+			paused:  c.paused,
+			queued:  c.pending(),
+		}
+	})
+	return p, ok
+}
+
+// isTerminal returns true if f is connected to a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// showProgress prints a status line about c to w until the
+// crawl finishes. On a terminal the line is updated in place
+// every second, otherwise a new line is written every ten.
+// The returned channel is closed when the last line is out.
+func showProgress(c *crawler, w *os.File) <-chan struct{} {
+	done := make(chan struct{})
+	tty := isTerminal(w)
+	every := 10 * time.Second
+	if tty {
+		every = time.Second
+	}
+	go func() {
+		defer close(done)
+		start := time.Now()
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		var last progress
+		for {
+			select {
+			case <-ticker.C:
+			case <-c.fin:
+				// The crawler is done, its state can be read.
+				last = progress{crawled: len(c.results), errors: c.nerrors}
+				printProgress(w, tty, last, time.Since(start))
+				if tty {
+					fmt.Fprintln(w)
+				}
+				return
+			}
+			p, ok := c.progress()
+			if ok {
+				last = p
+			}
+			printProgress(w, tty, last, time.Since(start))
+		}
+	}()
+	return done
+}
+
+func printProgress(w io.Writer, tty bool, p progress, elapsed time.Duration) {
+	rate := float64(p.crawled) / elapsed.Seconds()
+	line := fmt.Sprintf("crawled %d, queued %d, in flight %d, errors %d, %.1f pages/s, elapsed %s",
+		p.crawled, p.queued, p.busy, p.errors, rate, elapsed.Truncate(time.Second))
+	if p.paused {
+		line += " (paused)"
+	}
+	if tty {
+		fmt.Fprintf(w, "\r\033[K%s", line)
+		return
+	}
+	fmt.Fprintln(w, line)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// errHTTP3 is returned when HTTP/3 is asked for: it needs a QUIC
+// transport, which is not vendored.
+var errHTTP3 = errors.New("HTTP/3 needs a QUIC transport, which is not built in")
+
+// setProtocol makes f speak the HTTP version given: "1.1" or
+// "2" only, also without TLS, or "auto" to negotiate it.
+func (f *fetcher) setProtocol(version string) error {
+	var p http.Protocols
+	switch version {
+	case "auto":
+		return nil
+	case "1.1":
+		p.SetHTTP1(true)
+	case "2":
+		p.SetHTTP2(true)
+		p.SetUnencryptedHTTP2(true)
+	case "3":
+		return errHTTP3
+	default:
+		return fmt.Errorf("unknown HTTP version %s", version)
+	}
+	t := f.httpTransport()
+	t.Protocols = &p
+	f.setTransport(t)
+	return nil
+}
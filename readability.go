@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// minReadabilityWords is the length from which a page has
+// enough text for its readability to mean anything.
+const minReadabilityWords = 100
+
+// hiddenText are elements whose text is not read by visitors.
+var hiddenText = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true, "svg": true,
+}
+
+// readability measures how hard the text of a page is to read.
+type readability struct {
+	Words     int
+	Sentences int
+	Syllables int
+	Ease      float64 // Flesch reading ease, higher is easier
+	Grade     float64 // Flesch-Kincaid grade level
+}
+
+func (r *readability) String() string {
+	return fmt.Sprintf("ease %.0f, grade %.1f, %d words", r.Ease, r.Grade, r.Words)
+}
+
+// syllables estimates the syllables of an English word by
+// counting its groups of vowels.
+func syllables(word string) int {
+	word = strings.ToLower(word)
+	n := 0
+	vowel := false
+	for _, r := range word {
+		v := strings.ContainsRune("aeiouy", r)
+		if v && !vowel {
+			n++
+		}
+		vowel = v
+	}
+	if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && n > 1 {
+		n--
+	}
+	if n == 0 {
+		n = 1
+	}
+	return n
+}
+
+// newReadability computes the readability of text.
+func newReadability(text string) *readability {
+	r := &readability{}
+	inSentence := false
+	for _, w := range strings.Fields(text) {
+		word := strings.TrimFunc(w, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		if word == "" {
+			continue
+		}
+		r.Words++
+		r.Syllables += syllables(word)
+		inSentence = true
+		if strings.ContainsAny(w[len(w)-1:], ".!?") {
+			r.Sentences++
+			inSentence = false
+		}
+	}
+	if inSentence {
+		r.Sentences++
+	}
+	if r.Words == 0 {
+		return r
+	}
+	wps := float64(r.Words) / float64(r.Sentences)
+	spw := float64(r.Syllables) / float64(r.Words)
+	r.Ease = 206.835 - 1.015*wps - 84.6*spw
+	r.Grade = 0.39*wps + 11.8*spw - 15.59
+	return r
+}
+
+// textAnalyzer collects the visible text of the page and its
+// main content, which readability and terms are computed on.
+type textAnalyzer struct {
+	hidden  int // open hidden elements
+	text    strings.Builder
+	content mainContent
+}
+
+func (a *textAnalyzer) token(t *html.Token) {
+	switch t.Type {
+	case html.StartTagToken:
+		if hiddenText[t.Data] {
+			a.hidden++
+		}
+	case html.EndTagToken:
+		if hiddenText[t.Data] && a.hidden > 0 {
+			a.hidden--
+		}
+	case html.TextToken:
+		if a.hidden == 0 {
+			a.text.WriteString(t.Data)
+			a.text.WriteString(" ")
+		} else {
+			return
+		}
+	}
+	a.content.token(t)
+}
+
+func (a *textAnalyzer) finish(res *result) {
+	text := a.content.String()
+	res.Readability = newReadability(text)
+	res.Terms = topTerms(text, stopwordSet(res.Lang, nil), maxPageTerms)
+	res.ContentHash = mainContentHash(text)
+}
+
+// parseBand parses a range like "30-70".
+func parseBand(s string) (min, max float64, err error) {
+	i := strings.Index(s[1:], "-") + 1
+	if i == 0 {
+		return 0, 0, fmt.Errorf("readability band %q is not MIN-MAX", s)
+	}
+	if min, err = strconv.ParseFloat(s[:i], 64); err != nil {
+		return 0, 0, fmt.Errorf("readability band %q: %s", s, err)
+	}
+	if max, err = strconv.ParseFloat(s[i+1:], 64); err != nil {
+		return 0, 0, fmt.Errorf("readability band %q: %s", s, err)
+	}
+	if min > max {
+		return 0, 0, fmt.Errorf("readability band %q is empty", s)
+	}
+	return min, max, nil
+}
+
+// checkReadability adds a "hard-to-read" or "too-simple" finding
+// for each page with enough text whose reading ease is outside
+// the band from min to max.
+func checkReadability(rep *report, min, max float64) {
+	for _, res := range rep.sorted() {
+		r := res.Readability
+		if !res.page() || r == nil || r.Words < minReadabilityWords {
+			continue
+		}
+		switch {
+		case r.Ease < min:
+			rep.add("hard-to-read", res.URL, r.String())
+		case r.Ease > max:
+			rep.add("too-simple", res.URL, r.String())
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// archivedPage is a response read back from an archive.
+type archivedPage struct {
+	url  string
+	resp *response
+}
+
+// readArchiveDir reads the bodies stored with -archive in dir.
+// The first page stored is the base of the crawl.
+func readArchiveDir(dir string) ([]archivedPage, string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(archiveIndex)))
+	if err != nil {
+		return nil, "", err
+	}
+	var pages []archivedPage
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var e archiveEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, "", fmt.Errorf("cannot read archive index: %s", err)
+		}
+		body, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(e.File)))
+		if err != nil {
+			return nil, "", err
+		}
+		if e.Gzip {
+			if body, err = gunzip(body); err != nil {
+				return nil, "", fmt.Errorf("%s: %s", e.File, err)
+			}
+		}
+		pages = append(pages, archivedPage{
+			url:  e.URL,
+			resp: &response{status: e.Status, header: e.Header, body: body},
+		})
+	}
+	if len(pages) == 0 {
+		return nil, "", nil
+	}
+	return pages, pages[0].url, nil
+}
+
+// readWARC reads the HTTP responses for pages stored in a WARC
+// file, optionally gzip compressed; other records, and responses
+// that are not HTML, are skipped. Bodies are decoded if they were
+// sent gzip compressed. The target of the first request record
+// is the base of the crawl.
+func readWARC(name string) ([]archivedPage, string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, "", err
+		}
+		r = zr
+	}
+	br := bufio.NewReader(r)
+	tr := textproto.NewReader(br)
+	var (
+		pages []archivedPage
+		base  string
+	)
+	for {
+		version, err := tr.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if version == "" {
+			continue
+		}
+		if !strings.HasPrefix(version, "WARC/") {
+			return nil, "", fmt.Errorf("invalid WARC record: %q", version)
+		}
+		hdr, err := tr.ReadMIMEHeader()
+		if err != nil {
+			return nil, "", err
+		}
+		n, err := strconv.ParseInt(hdr.Get("Content-Length"), 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid WARC record length: %s", err)
+		}
+		block := make([]byte, n)
+		if _, err := io.ReadFull(br, block); err != nil {
+			return nil, "", err
+		}
+		url := strings.Trim(hdr.Get("WARC-Target-URI"), "<>")
+		if hdr.Get("WARC-Type") == "request" && base == "" {
+			base = url
+		}
+		if hdr.Get("WARC-Type") != "response" || !strings.HasPrefix(hdr.Get("Content-Type"), "application/http") {
+			continue
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(block)), nil)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %s", url, err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %s", url, err)
+		}
+		if !isHTML(resp.Header.Get("Content-Type")) {
+			continue
+		}
+		switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+		case "gzip", "x-gzip":
+			if body, err = gunzip(body); err != nil {
+				return nil, "", fmt.Errorf("%s: %s", url, err)
+			}
+			// As when fetched, the body is no longer encoded.
+			resp.Header.Del("Content-Encoding")
+			resp.Header.Del("Content-Length")
+		}
+		pages = append(pages, archivedPage{
+			url:  url,
+			resp: &response{status: resp.StatusCode, header: resp.Header, body: body},
+		})
+	}
+	if base == "" && len(pages) > 0 {
+		base = pages[0].url
+	}
+	return pages, base, nil
+}
+
+// linkDepths sets the depth of each result as the shortest
+// number of links from the root of the site.
+func linkDepths(results map[string]*result) {
+	queue := make([]*result, 0)
+	seen := make(map[string]bool)
+	for url, res := range results {
+		res.Depth = -1
+		if hostPath(url) == "/" {
+			res.Depth = 0
+			seen[url] = true
+			queue = append(queue, res)
+		}
+	}
+	for len(queue) > 0 {
+		res := queue[0]
+		queue = queue[1:]
+		for _, l := range res.Links {
+			next, ok := results[l]
+			if !ok || seen[l] {
+				continue
+			}
+			seen[l] = true
+			next.Depth = res.Depth + 1
+			queue = append(queue, next)
+		}
+	}
+}
+
+// reanalyzeCmd implements the "reanalyze" subcommand: run the
+// analysis and reporting of a crawl over archived responses.
+func reanalyzeCmd(opts *options, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: seopeo [flags] reanalyze DIR|FILE.warc[.gz]\n")
+		return 2
+	}
+	var (
+		pages []archivedPage
+		base  string
+		err   error
+	)
+	if fi, serr := os.Stat(args[0]); serr == nil && fi.IsDir() {
+		pages, base, err = readArchiveDir(args[0])
+	} else {
+		pages, base, err = readWARC(args[0])
+	}
+	if err != nil {
+		slog.Error("cannot read archive", "err", err)
+		return 1
+	}
+	if len(pages) == 0 {
+		slog.Error("no pages found", "archive", args[0])
+		return 1
+	}
+	if len(opts.seeds) > 0 {
+		base = opts.seeds[0]
+	}
+	analyzers, err := pageAnalyzers(opts)
+	if err != nil {
+		slog.Error("cannot set up analyzers", "err", err)
+		return 1
+	}
+	results := make(map[string]*result)
+	for _, p := range pages {
+		res := &result{URL: p.url}
+		extra := make([]analyzer, len(analyzers))
+		for i, newAnalyzer := range analyzers {
+			extra[i] = newAnalyzer()
+		}
+		if err := analyze(res, p.resp, slog.With("url", p.url), extra...); err != nil {
+			slog.Error("cannot parse page", "url", p.url, "err", err)
+			res.Err = err
+		}
+		results[p.url] = res
+	}
+	linkDepths(results)
+	st, err := openStorage(opts.output)
+	if err != nil {
+		slog.Error("cannot open output", "err", err)
+		return 1
+	}
+	ci, err := newCIThresholds(opts.ciMax5xx, opts.ciMaxBroken, opts.ciNoindex)
+	if err != nil {
+		slog.Error("invalid CI threshold", "err", err)
+		return 2
+	}
+	ci.regressions = opts.ciRegressions
+	if !publish(opts, newReport(base, results), st, ci) {
+		return exitCIFailed
+	}
+	return 0
+}
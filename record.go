@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+)
+
+// exchangeFile returns the name of the file in dir that holds
+// the response to req.
+func exchangeFile(dir string, req *http.Request) string {
+	h := sha1.Sum([]byte(req.Method + " " + req.URL.String()))
+	return filepath.Join(dir, hex.EncodeToString(h[:])+".http")
+}
+
+// recorder returns a middleware that saves each response, as
+// received, into a file of dir.
+func recorder(dir string) middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			data, err := httputil.DumpResponse(resp, true)
+			if err != nil {
+				resp.Body.Close()
+				return nil, fmt.Errorf("cannot record response: %s", err)
+			}
+			if err := ioutil.WriteFile(exchangeFile(dir, req), data, 0644); err != nil {
+				resp.Body.Close()
+				return nil, fmt.Errorf("cannot record response: %s", err)
+			}
+			return resp, nil
+		})
+	}
+}
+
+// replayer returns a middleware that answers requests with the
+// responses recorded in dir, never calling the network.
+func replayer(dir string) middleware {
+	return func(http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			data, err := ioutil.ReadFile(exchangeFile(dir, req))
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("%s %s was not recorded", req.Method, req.URL)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("cannot replay response: %s", err)
+			}
+			resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+			if err != nil {
+				return nil, fmt.Errorf("cannot replay response: %s", err)
+			}
+			return resp, nil
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// withoutAddrs returns the exported report data without the
+// remote addresses of the results.
+func withoutAddrs(t *testing.T, data string) string {
+	t.Helper()
+	var r Report
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		t.Fatal(err)
+	}
+	for i := range r.Results {
+		r.Results[i].RemoteAddr = ""
+		r.Results[i].IPFamily = ""
+	}
+	out, err := json.Marshal(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestRecordReplay(t *testing.T) {
+	srv := httptest.NewServer(testSite())
+	exchanges := t.TempDir()
+	recorded := t.TempDir()
+	runSeopeo(t, "-deterministic", "-quiet", "-json", "-top", "3", "-record", exchanges, "-output", recorded, srv.URL)
+	srv.Close()
+	replayed := t.TempDir()
+	runSeopeo(t, "-deterministic", "-quiet", "-json", "-top", "3", "-replay", exchanges, "-output", replayed, srv.URL)
+	want := readOutput(t, recorded, srv)
+	got := readOutput(t, replayed, srv)
+	// Replayed responses come from no connection.
+	want["results.json"] = withoutAddrs(t, want["results.json"])
+	got["results.json"] = withoutAddrs(t, got["results.json"])
+	if len(got) != len(want) {
+		t.Errorf("replay wrote %d files, recording %d", len(got), len(want))
+	}
+	for name, data := range want {
+		if got[name] != data {
+			t.Errorf("%s differs when replayed:\n%s\nrecorded:\n%s", name, got[name], data)
+		}
+	}
+	if files, _ := filepath.Glob(filepath.Join(exchanges, "*.http")); len(files) == 0 {
+		t.Error("no exchanges recorded")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	nurl "net/url"
+	"strings"
+)
+
+// maxRedirects is the number of redirects followed for a URL.
+const maxRedirects = 10
+
+// redirect is a single hop followed while fetching a URL.
+type redirect struct {
+	From   string
+	To     string
+	Status int
+}
+
+// permanent returns true for the redirect statuses that
+// declare the move to be permanent.
+func (r redirect) permanent() bool {
+	return r.Status == 301 || r.Status == 308
+}
+
+// temporaryTargets are parts of a URL that betray a redirect
+// meant to be temporary, like sending to a login page.
+var temporaryTargets = []string{
+	"login", "signin", "sign-in", "logon", "auth", "logout",
+	"session", "maintenance", "unavailable", "captcha",
+	"return", "redirect", "next=",
+}
+
+// looksTemporary returns true if the target of r suggests the
+// redirect depends on the visitor or on the moment.
+func (r redirect) looksTemporary() bool {
+	to := strings.ToLower(r.To)
+	if u, err := nurl.Parse(to); err == nil {
+		to = u.Path + "?" + u.RawQuery
+	}
+	for _, t := range temporaryTargets {
+		if strings.Contains(to, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// external returns the first hop of redirects that leaves host
+// and its www alias, if any.
+func external(redirects []redirect, host string) (redirect, bool) {
+	for _, r := range redirects {
+		u, err := nurl.Parse(r.To)
+		if err != nil {
+			continue
+		}
+		if u.Host != host && u.Host != aliasHost(host) {
+			return r, true
+		}
+	}
+	return redirect{}, false
+}
+
+// checkRedirects adds a "temporary-redirect" finding for each 302,
+// 303 or 307 redirect that looks like a permanent move, and a
+// "permanent-redirect" finding for each 301 or 308 redirect to a
+// target that looks temporary, like a login page. Crawled URLs
+// that redirect to another site get an "external-redirect".
+func checkRedirects(rep *report) {
+	var host string
+	if u, err := nurl.Parse(rep.base); err == nil {
+		host = u.Host
+	}
+	seen := make(map[redirect]bool)
+	for _, res := range rep.sorted() {
+		if r, ok := external(res.Redirects, host); ok {
+			rep.add("external-redirect", res.URL, fmt.Sprintf("%d to %s", r.Status, r.To))
+		}
+		for _, r := range res.Redirects {
+			if seen[r] {
+				continue
+			}
+			seen[r] = true
+			switch {
+			case !r.permanent() && !r.looksTemporary():
+				rep.add("temporary-redirect", r.From, fmt.Sprintf("%d to %s, use 301 or 308 for a move", r.Status, r.To))
+			case r.permanent() && r.looksTemporary():
+				rep.add("permanent-redirect", r.From, fmt.Sprintf("%d to %s, which looks temporary", r.Status, r.To))
+			}
+		}
+	}
+}
+
+// writeRedirects prints all redirects met during the crawl with
+// their status, one per line.
+func (r *report) writeRedirects(w io.Writer) error {
+	seen := make(map[redirect]bool)
+	list := make([]redirect, 0)
+	for _, res := range r.sorted() {
+		for _, rd := range res.Redirects {
+			if !seen[rd] {
+				seen[rd] = true
+				list = append(list, rd)
+			}
+		}
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "\nredirects (%d)\n", len(list)); err != nil {
+		return err
+	}
+	for _, rd := range list {
+		if _, err := fmt.Fprintf(w, "\t%s\t%d\t%s\n", rd.From, rd.Status, rd.To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// minPathSimilarity is how similar the paths of an old and a new
+// page must be at least to propose a redirect between them.
+const minPathSimilarity = 0.5
+
+// pathWords returns the lowercase words in the path of url,
+// without file extensions.
+func pathWords(url string) map[string]bool {
+	p := strings.TrimSuffix(urlPath(url), path.Ext(urlPath(url)))
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(p), func(r rune) bool {
+		return r == '/' || r == '-' || r == '_' || r == '.' || r == '+'
+	}) {
+		words[w] = true
+	}
+	return words
+}
+
+// similarity returns the share of words the two sets have in
+// common, from 0 to 1.
+func similarity(a, b map[string]bool) float64 {
+	common := 0
+	for w := range a {
+		if b[w] {
+			common++
+		}
+	}
+	all := len(a) + len(b) - common
+	if all == 0 {
+		return 1
+	}
+	return float64(common) / float64(all)
+}
+
+// redirectMapping is a proposed redirect of a page of the old
+// site, with how the target was chosen.
+type redirectMapping struct {
+	from, to string // to is empty if none was found
+	match    string
+}
+
+// mapRedirects proposes a page of the new crawl for each page of
+// the old one: the page at the same path, else the only one with
+// the same title, else the one with the most similar path.
+func mapRedirects(old, new *crawler) []redirectMapping {
+	var targets []*result
+	byPath := make(map[string]*result)
+	byTitle := make(map[string][]*result)
+	for _, res := range new.results {
+		if !res.loaded() {
+			continue
+		}
+		// The root can be crawled both with and without slash.
+		if _, dup := byPath[hostPath(res.URL)]; dup {
+			continue
+		}
+		targets = append(targets, res)
+		byPath[hostPath(res.URL)] = res
+		if res.Title != "" {
+			byTitle[res.Title] = append(byTitle[res.Title], res)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].URL < targets[j].URL })
+	words := make([]map[string]bool, len(targets))
+	for i, t := range targets {
+		words[i] = pathWords(t.URL)
+	}
+	var sources []*result
+	for _, res := range old.results {
+		if res.loaded() {
+			sources = append(sources, res)
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].URL < sources[j].URL })
+	ms := make([]redirectMapping, 0, len(sources))
+	for _, src := range sources {
+		m := redirectMapping{from: src.URL, match: "none"}
+		if t, ok := byPath[hostPath(src.URL)]; ok {
+			m.to, m.match = t.URL, "path"
+		} else if ts := byTitle[src.Title]; src.Title != "" && len(ts) == 1 {
+			m.to, m.match = ts[0].URL, "title"
+		} else {
+			ws := pathWords(src.URL)
+			best := minPathSimilarity
+			for i, t := range targets {
+				if s := similarity(ws, words[i]); s >= best && (m.to == "" || s > best) {
+					best = s
+					m.to, m.match = t.URL, fmt.Sprintf("similar path %.2f", s)
+				}
+			}
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+// writeRedirectMap writes the mappings as lines with old URL, new
+// URL and how it was matched, tab separated, after a comment
+// line. Old pages without a match get "-" as new URL, to be
+// filled in or removed by whoever reviews the map. The reviewed
+// map is what the migrate subcommand verifies.
+func writeRedirectMap(w io.Writer, ms []redirectMapping) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# old\tnew\tmatch")
+	for _, m := range ms {
+		fmt.Fprintf(bw, "%s\t%s\t%s\n", m.from, orDash(m.to), m.match)
+	}
+	return bw.Flush()
+}
+
+// redirectMapCmd implements the "redirect-map" subcommand: crawl
+// the old and the new site and propose where each old page should
+// redirect to.
+func redirectMapCmd(opts *options, args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: seopeo [flags] redirect-map OLD-URL NEW-URL\n")
+		return 2
+	}
+	fetch, err := optionsFetcher(opts)
+	if err != nil {
+		slog.Error("cannot start fetcher", "err", err)
+		return 2
+	}
+	filter, err := newURLFilter(opts.include, opts.exclude)
+	if err != nil {
+		slog.Error("invalid URL filter", "err", err)
+		return 2
+	}
+	crawlers := make([]*crawler, len(args))
+	for i, seed := range args {
+		c, err := newCrawler(context.Background(), []string{seed}, opts.workers, fetch, filter)
+		if err != nil {
+			slog.Error("cannot crawl", "url", seed, "err", err)
+			return 2
+		}
+		crawlers[i] = c
+		c.start()
+	}
+	for _, c := range crawlers {
+		c.wait()
+	}
+	if err := writeRedirectMap(os.Stdout, mapRedirects(crawlers[0], crawlers[1])); err != nil {
+		slog.Error("cannot write redirect map", "err", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// latestSnapshot returns the most recent snapshot of the crawl of
+// base in the history directory dir, or nil if there is none yet.
+func latestSnapshot(dir, base string) (*Report, error) {
+	snaps, err := loadSnapshots(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := len(snaps) - 1; i >= 0; i-- {
+		if sameURL(snaps[i].Base, base) {
+			return snaps[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// regressions compares the crawl in rep with the previous one,
+// prev, and returns what got worse: pages that answer 404 now
+// ("new-404"), that became noindex ("newly-noindex"), that were
+// fine and are gone ("lost-page") and that now redirect more
+// than once ("new-redirect-chain"). Pages are only reported lost
+// if both crawls covered the whole site.
+func regressions(prev *Report, rep *report) []finding {
+	before := make(map[string]Result, len(prev.Results))
+	for _, r := range prev.Results {
+		before[strings.TrimSuffix(r.URL, "/")] = r
+	}
+	var fs []finding
+	add := func(kind, url, detail string) {
+		fs = append(fs, finding{Kind: kind, URL: url, Detail: detail})
+	}
+	for _, res := range rep.sorted() {
+		old, seen := before[strings.TrimSuffix(res.URL, "/")]
+		switch {
+		case res.Status == http.StatusNotFound && !seen:
+			add("new-404", res.URL, "not crawled before")
+		case res.Status == http.StatusNotFound && old.Status != http.StatusNotFound:
+			add("new-404", res.URL, fmt.Sprintf("was status %d", old.Status))
+		}
+		if seen && res.noindex() && !isNoindex(old.Robots) && !isNoindex(old.XRobotsTag) {
+			add("newly-noindex", res.URL, strings.Trim(res.Robots+", "+res.XRobotsTag, ", "))
+		}
+		if hops := len(res.Redirects); hops > 1 && (!seen || old.Redirects <= 1) {
+			add("new-redirect-chain", res.URL, fmt.Sprintf("%d hops", hops))
+		}
+	}
+	if prev.partial() || rep.truncated != "" || rep.interrupted {
+		return fs
+	}
+	for _, old := range prev.Results {
+		if old.Error == "" && old.Status == http.StatusOK && rep.lookup(old.URL) == nil {
+			add("lost-page", old.URL, "no longer found by the crawl")
+		}
+	}
+	return fs
+}
+
+// writeRegressions prints the regressions since the crawl of prev.
+func (r *report) writeRegressions(w io.Writer, prev *Report) error {
+	_, err := fmt.Fprintf(w, "regressions since %s (%d)\n", prev.CrawledAt.Format("2006-01-02 15:04:05"), len(r.regressions))
+	if err != nil {
+		return err
+	}
+	for _, f := range r.regressions {
+		line := f.Kind + "\t" + f.URL
+		if f.Detail != "" {
+			line += "\t" + f.Detail
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
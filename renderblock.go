@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// checkRenderBlocking adds a "render-blocking-resources" finding
+// for each page with stylesheets or scripts that delay its
+// rendering, with how many and how many bytes they take to
+// download. Each resource is fetched once.
+func checkRenderBlocking(ctx context.Context, fetch *fetcher, rep *report) {
+	sizes := make(map[string]int)
+	for _, res := range rep.sorted() {
+		if !res.page() {
+			continue
+		}
+		var ncss, njs, bytes int
+		for _, r := range res.Resources {
+			if !r.Blocking {
+				continue
+			}
+			if r.Kind == "css" {
+				ncss++
+			} else {
+				njs++
+			}
+			size, ok := sizes[r.URL]
+			if !ok {
+				resp, err := fetch.get(ctx, r.URL)
+				if err != nil {
+					slog.Warn("cannot fetch resource", "url", r.URL, "err", err)
+				} else if resp.status == http.StatusOK {
+					size = resp.wire
+				}
+				sizes[r.URL] = size
+			}
+			bytes += size
+		}
+		if ncss+njs > 0 {
+			rep.add("render-blocking-resources", res.URL, fmt.Sprintf("%d stylesheets and %d scripts, %d bytes sent", ncss, njs, bytes))
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	nurl "net/url"
+	"sort"
+	"strings"
+)
+
+// finding is an issue detected on a crawled page or,
+// when the page was never crawled, about a URL of the site.
+type finding struct {
+	Kind   string
+	URL    string
+	Detail string
+}
+
+// report is the outcome of a crawl: the results of all
+// visited URLs plus the findings of all checks that ran.
+type report struct {
+	base     string
+	results  map[string]*result
+	findings []finding
+	sortBy   string // one of resultOrders, or unsorted
+	group    bool   // group results by directory
+	fold     bool   // list variants under their canonical
+	// timings, if false, leaves out download times, which change
+	// from crawl to crawl.
+	timings bool
+	// truncated is why a budget ended the crawl early, if it did.
+	truncated string
+	// interrupted is set when a signal stopped the crawl.
+	interrupted bool
+	// sampled is set when only a sample of each URL pattern
+	// was crawled.
+	sampled bool
+	// signals, if fetched, are robots.txt and the sitemaps.
+	signals *indexSignals
+	// cookies, if recorded, are those set during the crawl.
+	cookies []*seenCookie
+	// certs, if fetched, are those of the crawled hosts.
+	certs []*hostCert
+	// docs are the documents linked from the pages, nil until listed.
+	docs []*document
+	// regressions are what got worse since the previous crawl.
+	regressions []finding
+}
+
+func newReport(base string, results map[string]*result) *report {
+	return &report{
+		base:     base,
+		results:  results,
+		findings: make([]finding, 0),
+		timings:  true,
+	}
+}
+
+// add records a finding of type kind about url. If url was
+// crawled, the finding is also attached to its result.
+func (r *report) add(kind, url, detail string) {
+	f := finding{Kind: kind, URL: url, Detail: detail}
+	r.findings = append(r.findings, f)
+	if res, ok := r.results[url]; ok {
+		res.Findings = append(res.Findings, f)
+	}
+}
+
+// sortFindings orders the findings, also those attached to
+// each result, by type, URL and detail.
+func (r *report) sortFindings() {
+	less := func(list []finding) func(i, j int) bool {
+		return func(i, j int) bool {
+			a, b := list[i], list[j]
+			if a.Kind != b.Kind {
+				return a.Kind < b.Kind
+			}
+			if a.URL != b.URL {
+				return a.URL < b.URL
+			}
+			return a.Detail < b.Detail
+		}
+	}
+	sort.SliceStable(r.findings, less(r.findings))
+	for _, res := range r.results {
+		sort.SliceStable(res.Findings, less(res.Findings))
+	}
+}
+
+// maxIssueExamples is how many examples addIssues lists.
+const maxIssueExamples = 3
+
+// addIssues adds one finding for url for each type of issues,
+// with how many there are and the first details as examples.
+func (r *report) addIssues(url string, issues []finding) {
+	kinds := make([]string, 0)
+	byKind := make(map[string][]string)
+	for _, f := range issues {
+		if _, ok := byKind[f.Kind]; !ok {
+			kinds = append(kinds, f.Kind)
+		}
+		byKind[f.Kind] = append(byKind[f.Kind], f.Detail)
+	}
+	for _, k := range kinds {
+		ex := byKind[k]
+		detail := fmt.Sprintf("%d", len(ex))
+		if len(ex) > maxIssueExamples {
+			ex = ex[:maxIssueExamples]
+		}
+		if s := strings.Trim(strings.Join(ex, ", "), ", "); s != "" {
+			detail += ": " + s
+		}
+		r.add(k, url, detail)
+	}
+}
+
+// byKind groups findings by their type.
+func (r *report) byKind() map[string][]finding {
+	kinds := make(map[string][]finding)
+	for _, f := range r.findings {
+		kinds[f.Kind] = append(kinds[f.Kind], f)
+	}
+	return kinds
+}
+
+// urlPath returns the path of url, "/" if it has none.
+func urlPath(url string) string {
+	u, err := nurl.Parse(url)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return u.Path
+}
+
+// section is the first directory of the path of url.
+func section(url string) string {
+	p := strings.TrimPrefix(urlPath(url), "/")
+	if i := strings.Index(p, "/"); i >= 0 {
+		return "/" + p[:i+1]
+	}
+	return "/"
+}
+
+// directory is the directory part of the path of url.
+func directory(url string) string {
+	p := urlPath(url)
+	return p[:strings.LastIndex(p, "/")+1]
+}
+
+// resultOrders compare two results for each sort order.
+var resultOrders = map[string]func(a, b *result) bool{
+	"url": func(a, b *result) bool {
+		return a.URL < b.URL
+	},
+	"depth": func(a, b *result) bool {
+		if a.Depth != b.Depth {
+			return a.Depth < b.Depth
+		}
+		return a.URL < b.URL
+	},
+	"status": func(a, b *result) bool {
+		if a.Status != b.Status {
+			return a.Status < b.Status
+		}
+		return a.URL < b.URL
+	},
+	"section": func(a, b *result) bool {
+		sa, sb := section(a.URL), section(b.URL)
+		if sa != sb {
+			return sa < sb
+		}
+		return a.URL < b.URL
+	},
+}
+
+// sorted returns the results in the order chosen for the report.
+func (r *report) sorted() []*result {
+	list := make([]*result, 0, len(r.results))
+	for _, res := range r.results {
+		list = append(list, res)
+	}
+	if less, ok := resultOrders[r.sortBy]; ok {
+		sort.SliceStable(list, func(i, j int) bool {
+			return less(list[i], list[j])
+		})
+	}
+	return list
+}
+
+// write prints all results, one per line, followed by the
+// redirects met and the findings grouped by type.
+func (r *report) write(w io.Writer) error {
+	list := r.sorted()
+	if r.group {
+		if err := writeGroups(w, list); err != nil {
+			return err
+		}
+		if err := r.writeRedirects(w); err != nil {
+			return err
+		}
+		return r.writeFindings(w)
+	}
+	if r.fold {
+		if err := r.writeFolded(w, list); err != nil {
+			return err
+		}
+		if err := r.writeRedirects(w); err != nil {
+			return err
+		}
+		return r.writeFindings(w)
+	}
+	for _, res := range list {
+		if err := writeResult(w, "", res); err != nil {
+			return err
+		}
+	}
+	if err := r.writeRedirects(w); err != nil {
+		return err
+	}
+	return r.writeFindings(w)
+}
+
+// writeGroups prints the results under their directory,
+// keeping their order within each directory.
+func writeGroups(w io.Writer, list []*result) error {
+	groups := make(map[string][]*result)
+	dirs := make([]string, 0)
+	for _, res := range list {
+		dir := directory(res.URL)
+		if _, ok := groups[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		groups[dir] = append(groups[dir], res)
+	}
+	sort.Strings(dirs)
+	for i, dir := range dirs {
+		sep := "\n"
+		if i == 0 {
+			sep = ""
+		}
+		if _, err := fmt.Fprintf(w, "%s%s (%d)\n", sep, dir, len(groups[dir])); err != nil {
+			return err
+		}
+		for _, res := range groups[dir] {
+			if err := writeResult(w, "\t", res); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeResult prints the line for a single result. Pages
+// that did not load correctly are marked with their status.
+func writeResult(w io.Writer, indent string, res *result) error {
+	line := indent + res.URL
+	switch {
+	case res.Err != nil:
+		line += "\terror: " + res.Err.Error()
+	case res.Status != http.StatusOK:
+		line += fmt.Sprintf("\tstatus %d", res.Status)
+	}
+	if res.CrUX != nil {
+		line += "\t" + res.CrUX.String()
+	}
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
+
+// writeFindings prints the findings grouped by type.
+func (r *report) writeFindings(w io.Writer) error {
+	kinds := r.byKind()
+	names := make([]string, 0, len(kinds))
+	for k := range kinds {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if _, err := fmt.Fprintf(w, "\n%s (%d)\n", k, len(kinds[k])); err != nil {
+			return err
+		}
+		for _, f := range kinds[k] {
+			line := "\t" + f.URL
+			if f.Detail != "" {
+				line += "\t" + f.Detail
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	nurl "net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// pathRewrite is a suggested permanent redirect of a path of the
+// site to another.
+type pathRewrite struct {
+	from, to string
+	why      string
+}
+
+// siteRewrites are the redirects the crawl suggests adding to the
+// web server of the site.
+type siteRewrites struct {
+	host     string
+	https    bool   // redirect http to https
+	alias    string // host to redirect to host
+	rewrites []pathRewrite
+}
+
+// isDirLike returns true if the path p does not name a file.
+func isDirLike(p string) bool {
+	return path.Ext(p) == ""
+}
+
+// suggestRewrites collects the redirects that would fix what the
+// crawl found: http and alias hosts that do not redirect, chains
+// of redirects, paths with uppercase letters that are also crawled
+// in lowercase, and paths linked both with and without trailing
+// slash, which are redirected to the form most links use.
+func suggestRewrites(rep *report) *siteRewrites {
+	base, err := nurl.Parse(rep.base)
+	if err != nil {
+		return nil
+	}
+	sr := &siteRewrites{host: base.Host}
+	for _, f := range rep.findings {
+		switch f.Kind {
+		case "variant-not-redirected", "variant-wrong-target", "variant-redirect-chain":
+			u, err := nurl.Parse(f.URL)
+			if err != nil {
+				continue
+			}
+			if u.Scheme == "http" && base.Scheme == "https" {
+				sr.https = true
+			}
+			if u.Host == aliasHost(base.Host) {
+				sr.alias = u.Host
+			}
+		case "alias-host":
+			sr.alias = aliasHost(base.Host)
+		}
+	}
+	onSite := func(url string) (string, bool) {
+		u, err := nurl.Parse(url)
+		if err != nil || u.Host != base.Host || u.RawQuery != "" {
+			return "", false
+		}
+		if u.Path == "" {
+			return "/", true
+		}
+		return u.Path, true
+	}
+	ok := make(map[string]bool)
+	linked := make(map[string]bool)
+	slash, noSlash := 0, 0
+	for _, res := range rep.sorted() {
+		if !res.page() {
+			continue
+		}
+		if p, on := onSite(res.URL); on {
+			ok[p] = true
+		}
+		// Crawled URLs lose their trailing slash, links keep it.
+		for _, a := range res.Anchors {
+			u, err := nurl.Parse(a.URL)
+			if err != nil {
+				continue
+			}
+			u.Fragment = ""
+			p, on := onSite(u.String())
+			if !on || p == "/" || !isDirLike(strings.TrimSuffix(p, "/")) {
+				continue
+			}
+			linked[p] = true
+			if strings.HasSuffix(p, "/") {
+				slash++
+			} else {
+				noSlash++
+			}
+		}
+	}
+	seen := make(map[string]bool)
+	add := func(from, to, why string) {
+		if from != to && !seen[from] {
+			seen[from] = true
+			sr.rewrites = append(sr.rewrites, pathRewrite{from: from, to: to, why: why})
+		}
+	}
+	for _, res := range rep.sorted() {
+		if n := len(res.Redirects); n > 1 {
+			from, on := onSite(res.Redirects[0].From)
+			to, onTo := onSite(res.Redirects[n-1].To)
+			if on && onTo {
+				add(from, to, fmt.Sprintf("chain of %d redirects", n))
+			}
+		}
+	}
+	paths := make([]string, 0, len(ok))
+	for p := range ok {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if lower := strings.ToLower(p); lower != p && ok[lower] {
+			add(p, lower, "uppercase duplicate of a lowercase path")
+		}
+	}
+	paths = paths[:0]
+	for p := range linked {
+		if !strings.HasSuffix(p, "/") && linked[p+"/"] {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if slash > noSlash {
+			add(p, p+"/", "linked with and without trailing slash, most links have it")
+		} else {
+			add(p+"/", p, "linked with and without trailing slash, most links do not have it")
+		}
+	}
+	sort.Slice(sr.rewrites, func(i, j int) bool { return sr.rewrites[i].from < sr.rewrites[j].from })
+	return sr
+}
+
+// hostname returns host without port.
+func hostname(host string) string {
+	return (&nurl.URL{Host: host}).Hostname()
+}
+
+// writeNginx writes the rewrites as nginx configuration.
+func (sr *siteRewrites) writeNginx(w io.Writer) error {
+	var b strings.Builder
+	if sr.https {
+		fmt.Fprintf(&b, "# http does not redirect to https\nserver {\n\tlisten 80;\n\tserver_name %s;\n\treturn 301 https://%s$request_uri;\n}\n\n", hostname(sr.host), sr.host)
+	}
+	if sr.alias != "" {
+		fmt.Fprintf(&b, "# %s does not redirect to %s\nserver {\n\tserver_name %s;\n\treturn 301 $scheme://%s$request_uri;\n}\n\n", sr.alias, sr.host, hostname(sr.alias), sr.host)
+	}
+	if len(sr.rewrites) > 0 {
+		b.WriteString("# inside the server block of " + sr.host + "\n")
+	}
+	for _, r := range sr.rewrites {
+		fmt.Fprintf(&b, "# %s\nlocation = %s { return 301 %s; }\n", r.why, r.from, r.to)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeApache writes the rewrites as mod_rewrite rules.
+func (sr *siteRewrites) writeApache(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("RewriteEngine On\n")
+	if sr.https {
+		fmt.Fprintf(&b, "\n# http does not redirect to https\nRewriteCond %%{HTTPS} off\nRewriteRule ^ https://%s%%{REQUEST_URI} [R=301,L]\n", sr.host)
+	}
+	if sr.alias != "" {
+		fmt.Fprintf(&b, "\n# %s does not redirect to %s\nRewriteCond %%{HTTP_HOST} ^%s(:[0-9]+)?$ [NC]\nRewriteRule ^ %%{REQUEST_SCHEME}://%s%%{REQUEST_URI} [R=301,L]\n",
+			sr.alias, sr.host, regexp.QuoteMeta(hostname(sr.alias)), sr.host)
+	}
+	for _, r := range sr.rewrites {
+		fmt.Fprintf(&b, "\n# %s\nRewriteRule ^/?%s$ %s [R=301,L]\n", r.why, regexp.QuoteMeta(strings.TrimPrefix(r.from, "/")), r.to)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// serverRewriters write suggested rewrites for each web server.
+var serverRewriters = map[string]func(sr *siteRewrites, w io.Writer) error{
+	"nginx":  (*siteRewrites).writeNginx,
+	"apache": (*siteRewrites).writeApache,
+}
+
+// writeServerRewrites writes the redirects suggested by the crawl
+// as configuration of server, nginx or apache.
+func (r *report) writeServerRewrites(w io.Writer, server string) error {
+	sr := suggestRewrites(r)
+	if sr == nil {
+		return nil
+	}
+	return serverRewriters[server](sr, w)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// richResult is whether a page can get a type of rich result.
+type richResult struct {
+	Type     string
+	Problems []string // why it cannot, empty if it can
+}
+
+// ldObjects returns the objects of a JSON-LD property value,
+// which can be a single object or a list.
+func ldObjects(v interface{}) []map[string]interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{t}
+	case []interface{}:
+		var os []map[string]interface{}
+		for _, e := range t {
+			if o, ok := e.(map[string]interface{}); ok {
+				os = append(os, o)
+			}
+		}
+		return os
+	}
+	return nil
+}
+
+// richChecks return the problems that keep a JSON-LD object of
+// their types from a rich result, following Google's guidelines.
+var richChecks = []struct {
+	name  string
+	types []string
+	check func(o map[string]interface{}) []string
+}{
+	{"FAQ", []string{"FAQPage"}, func(o map[string]interface{}) []string {
+		qs := ldObjects(o["mainEntity"])
+		if len(qs) == 0 {
+			return []string{"no questions in mainEntity"}
+		}
+		var ps []string
+		for i, q := range qs {
+			if !hasProperty(q, "name") {
+				ps = append(ps, fmt.Sprintf("question %d has no name", i+1))
+			}
+			as := ldObjects(q["acceptedAnswer"])
+			if len(as) == 0 || !hasProperty(as[0], "text") {
+				ps = append(ps, fmt.Sprintf("question %d has no acceptedAnswer text", i+1))
+			}
+		}
+		return ps
+	}},
+	{"HowTo", []string{"HowTo"}, func(o map[string]interface{}) []string {
+		var ps []string
+		if !hasProperty(o, "name") {
+			ps = append(ps, "no name")
+		}
+		steps := ldObjects(o["step"])
+		if len(steps) == 0 {
+			ps = append(ps, "no steps")
+		}
+		for i, s := range steps {
+			if !hasProperty(s, "text|itemListElement") {
+				ps = append(ps, fmt.Sprintf("step %d has no text", i+1))
+			}
+		}
+		return ps
+	}},
+	{"Product", []string{"Product"}, func(o map[string]interface{}) []string {
+		var ps []string
+		if !hasProperty(o, "name") {
+			ps = append(ps, "no name")
+		}
+		if !hasProperty(o, "offers|review|aggregateRating") {
+			ps = append(ps, "none of offers, review or aggregateRating")
+		}
+		for i, of := range ldObjects(o["offers"]) {
+			if !hasProperty(of, "price|lowPrice|priceSpecification") {
+				ps = append(ps, fmt.Sprintf("offer %d has no price", i+1))
+			}
+		}
+		for _, r := range ldObjects(o["aggregateRating"]) {
+			if !hasProperty(r, "ratingValue") || !hasProperty(r, "ratingCount|reviewCount") {
+				ps = append(ps, "aggregateRating needs ratingValue and ratingCount or reviewCount")
+			}
+		}
+		return ps
+	}},
+	{"Review", []string{"Review"}, func(o map[string]interface{}) []string {
+		var ps []string
+		if !hasProperty(o, "author") {
+			ps = append(ps, "no author")
+		}
+		rs := ldObjects(o["reviewRating"])
+		if len(rs) == 0 || !hasProperty(rs[0], "ratingValue") {
+			ps = append(ps, "no reviewRating ratingValue")
+		}
+		return ps
+	}},
+}
+
+// richResults returns the rich results the JSON-LD values ld
+// declare, each with what keeps it from being shown.
+func richResults(ld []interface{}) []richResult {
+	var rrs []richResult
+	for _, rc := range richChecks {
+		found := false
+		var ps []string
+		for _, v := range ld {
+			findTyped(v, func(o map[string]interface{}) {
+				found = true
+				ps = append(ps, rc.check(o)...)
+			}, rc.types...)
+		}
+		if found {
+			rrs = append(rrs, richResult{Type: rc.name, Problems: ps})
+		}
+	}
+	return rrs
+}
+
+// checkRichResults adds a "rich-result-ineligible" finding for
+// each rich result a page declares but does not qualify for.
+func checkRichResults(rep *report) {
+	for _, res := range rep.sorted() {
+		for _, rr := range res.RichResults {
+			if len(rr.Problems) > 0 {
+				rep.add("rich-result-ineligible", res.URL, rr.Type+": "+strings.Join(rr.Problems, "; "))
+			}
+		}
+	}
+}
+
+// writeRichResults prints for each URL template and type of rich
+// result how many of its pages are eligible and how many not.
+func (r *report) writeRichResults(w io.Writer) error {
+	list := r.sorted()
+	paths := make([]string, len(list))
+	for i, res := range list {
+		paths[i] = urlPath(res.URL)
+	}
+	type count struct{ ok, broken int }
+	counts := make(map[string]*count)
+	var keys []string
+	for i, tmpl := range pathTemplates(paths) {
+		for _, rr := range list[i].RichResults {
+			k := tmpl + "\t" + rr.Type
+			c, found := counts[k]
+			if !found {
+				c = &count{}
+				counts[k] = c
+				keys = append(keys, k)
+			}
+			if len(rr.Problems) == 0 {
+				c.ok++
+			} else {
+				c.broken++
+			}
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		c := counts[k]
+		if _, err := fmt.Fprintf(w, "%s\t%d eligible\t%d broken\n", k, c.ok, c.broken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	nurl "net/url"
+	"os"
+	"strings"
+)
+
+// robotsRule is an Allow or Disallow line of robots.txt.
+type robotsRule struct {
+	allow   bool
+	pattern string
+	line    int
+}
+
+func (r *robotsRule) String() string {
+	verb := "Disallow"
+	if r.allow {
+		verb = "Allow"
+	}
+	return fmt.Sprintf("line %d: %s: %s", r.line, verb, r.pattern)
+}
+
+// match returns true if the rule pattern matches path. The
+// pattern can contain * wildcards and end with a $ anchor.
+func (r *robotsRule) match(path string) bool {
+	return robotsMatch(r.pattern, path)
+}
+
+func robotsMatch(pattern, path string) bool {
+	if pattern == "" {
+		return false
+	}
+	if strings.HasSuffix(pattern, "$") {
+		return wildcardMatch(pattern[:len(pattern)-1], path, true)
+	}
+	return wildcardMatch(pattern, path, false)
+}
+
+// escapeRobots percent-encodes the bytes of s that are not ASCII
+// and writes the hex digits of escapes in upper case, so that
+// patterns and paths written either way compare equal.
+func escapeRobots(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 0x80 || c <= ' ':
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		case c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+			i += 2
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// wildcardMatch matches path against a pattern with * wildcards;
+// when anchored the pattern must cover the whole path, otherwise
+// a match of a prefix of path is enough.
+func wildcardMatch(pattern, path string, anchored bool) bool {
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 && anchored {
+			return strings.HasSuffix(path[pos:], part)
+		}
+		j := strings.Index(path[pos:], part)
+		if j < 0 {
+			return false
+		}
+		pos += j + len(part)
+	}
+	return !anchored || pos == len(path)
+}
+
+type robotsGroup struct {
+	agents []string
+	rules  []robotsRule
+}
+
+// robotsTxt is a parsed robots.txt file.
+type robotsTxt struct {
+	groups   []*robotsGroup
+	sitemaps []string
+	// allowAll and disallowAll are set when the file could not
+	// be fetched: missing files allow all, server errors nothing.
+	allowAll    bool
+	disallowAll bool
+}
+
+// parseRobots reads robots.txt following the rules used by Google:
+// consecutive user-agent lines start a group, rules belong to the
+// last group and unknown lines are ignored.
+func parseRobots(r io.Reader) *robotsTxt {
+	rt := &robotsTxt{}
+	var (
+		cur       *robotsGroup
+		lastAgent bool
+		n         int
+	)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		n++
+		line := sc.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		val := strings.TrimSpace(line[i+1:])
+		switch key {
+		case "user-agent":
+			if cur == nil || !lastAgent {
+				cur = &robotsGroup{}
+				rt.groups = append(rt.groups, cur)
+			}
+			cur.agents = append(cur.agents, strings.ToLower(val))
+			lastAgent = true
+			continue
+		case "allow", "disallow":
+			if cur != nil {
+				cur.rules = append(cur.rules, robotsRule{allow: key == "allow", pattern: escapeRobots(val), line: n})
+			}
+		case "sitemap":
+			rt.sitemaps = append(rt.sitemaps, val)
+		}
+		lastAgent = false
+	}
+	return rt
+}
+
+// rules returns the rules that apply to agent: those of the groups
+// with the most specific matching user-agent, or of the * groups.
+func (rt *robotsTxt) rules(agent string) []robotsRule {
+	agent = strings.ToLower(agent)
+	var (
+		best  int = -1
+		rules []robotsRule
+	)
+	for _, g := range rt.groups {
+		for _, a := range g.agents {
+			score := -1
+			switch {
+			case a == "*":
+				score = 0
+			case strings.HasPrefix(agent, a):
+				score = len(a)
+			}
+			if score < 0 || score < best {
+				continue
+			}
+			if score > best {
+				best = score
+				rules = nil
+			}
+			rules = append(rules, g.rules...)
+		}
+	}
+	return rules
+}
+
+// test returns whether agent may fetch url and the rule that
+// decided it, nil if no rule matched. The longest matching
+// pattern wins; on equal length Allow wins.
+func (rt *robotsTxt) test(agent, url string) (bool, *robotsRule) {
+	if rt.allowAll {
+		return true, nil
+	}
+	if rt.disallowAll {
+		return false, nil
+	}
+	path := "/"
+	if u, err := nurl.Parse(url); err == nil {
+		path = u.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		if u.RawQuery != "" {
+			path += "?" + u.RawQuery
+		}
+		path = escapeRobots(path)
+	}
+	var match *robotsRule
+	rules := rt.rules(agent)
+	for i := range rules {
+		r := &rules[i]
+		if !r.match(path) {
+			continue
+		}
+		if match == nil || len(r.pattern) > len(match.pattern) ||
+			(len(r.pattern) == len(match.pattern) && r.allow && !match.allow) {
+			match = r
+		}
+	}
+	if match == nil {
+		return true, nil
+	}
+	return match.allow, match
+}
+
+// robotsURL returns the location of robots.txt for the site of u.
+func robotsURL(u *nurl.URL) string {
+	return u.Scheme + "://" + u.Host + "/robots.txt"
+}
+
+// fetchRobots downloads and parses robots.txt for the site of u.
+func fetchRobots(ctx context.Context, fetch *fetcher, u *nurl.URL) (*robotsTxt, int, error) {
+	resp, err := fetch.get(ctx, robotsURL(u))
+	if err != nil {
+		return nil, 0, err
+	}
+	switch {
+	case resp.status >= 500:
+		return &robotsTxt{disallowAll: true}, resp.status, nil
+	case resp.status >= 400:
+		return &robotsTxt{allowAll: true}, resp.status, nil
+	}
+	return parseRobots(bytes.NewReader(resp.body)), resp.status, nil
+}
+
+// robotsCmd implements the "robots" subcommand.
+func robotsCmd(opts *options, args []string) int {
+	fs := flag.NewFlagSet("robots", flag.ContinueOnError)
+	var agents stringList
+	fs.Var(&agents, "agent", "user-agent to test (repeatable, default *)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: seopeo [flags] robots [-agent NAME]... SITE URL...\n")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return 2
+	}
+	if len(agents) == 0 {
+		agents = stringList{"*"}
+	}
+	site, err := nurl.Parse(fs.Arg(0))
+	if err != nil || site.Host == "" {
+		slog.Error("not an absolute URL", "url", fs.Arg(0))
+		return 2
+	}
+	fetch, err := optionsFetcher(opts)
+	if err != nil {
+		slog.Error("cannot start fetcher", "err", err)
+		return 1
+	}
+	rt, status, err := fetchRobots(context.Background(), fetch, site)
+	if err != nil {
+		slog.Error("cannot fetch robots.txt", "err", err)
+		return 1
+	}
+	fmt.Printf("%s\tstatus %d\n", robotsURL(site), status)
+	switch {
+	case rt.allowAll:
+		fmt.Printf("\tno robots.txt, everything is allowed\n")
+	case rt.disallowAll:
+		fmt.Printf("\tserver error, everything is disallowed\n")
+	}
+	for _, url := range fs.Args()[1:] {
+		ref, err := nurl.Parse(url)
+		if err != nil {
+			slog.Error("invalid URL", "url", url, "err", err)
+			continue
+		}
+		url = site.ResolveReference(ref).String()
+		for _, agent := range agents {
+			allowed, rule := rt.test(agent, url)
+			verdict := "allowed"
+			if !allowed {
+				verdict = "disallowed"
+			}
+			matched := "no matching rule"
+			if rule != nil {
+				matched = rule.String()
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\n", url, agent, verdict, matched)
+		}
+	}
+	return 0
+}
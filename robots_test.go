@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// The cases follow the examples of how Google interprets the
+// robots.txt specification.
+
+func TestRobotsMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{"/", []string{"/", "/page.html", "/a/b?c=d"}, nil},
+		{"/*", []string{"/", "/page.html", "/a/b?c=d"}, nil},
+		{"/$", []string{"/"}, []string{"/page.html", "/?a=b"}},
+		{"/fish",
+			[]string{"/fish", "/fish.html", "/fish/salmon.html", "/fishheads", "/fishheads/yummy.html", "/fish.php?id=anything"},
+			[]string{"/Fish.asp", "/catfish", "/?id=fish", "/desert/fish"}},
+		{"/fish*",
+			[]string{"/fish", "/fish.html", "/fish/salmon.html", "/fishheads", "/fishheads/yummy.html", "/fish.php?id=anything"},
+			[]string{"/Fish.asp", "/catfish", "/?id=fish", "/desert/fish"}},
+		{"/fish/",
+			[]string{"/fish/", "/fish/?id=anything", "/fish/salmon.htm"},
+			[]string{"/fish", "/fish.html", "/animals/fish/", "/Fish/Salmon.asp"}},
+		{"/*.php",
+			[]string{"/index.php", "/filename.php", "/folder/filename.php", "/folder/filename.php?parameters", "/folder/any.php.file.html", "/filename.php/"},
+			[]string{"/", "/windows.PHP"}},
+		{"/*.php$",
+			[]string{"/filename.php", "/folder/filename.php"},
+			[]string{"/filename.php?parameters", "/filename.php/", "/filename.php5", "/windows.PHP"}},
+		{"/fish*.php",
+			[]string{"/fish.php", "/fishheads/catfish.php?parameters"},
+			[]string{"/Fish.PHP"}},
+		{"/a*b*c$", []string{"/abc", "/a/b/c", "/abcbc"}, []string{"/abcd", "/ac"}},
+		{"", nil, []string{"/", "/a"}},
+	}
+	for _, tt := range tests {
+		for _, path := range tt.match {
+			if !robotsMatch(tt.pattern, path) {
+				t.Errorf("%q does not match %q", tt.pattern, path)
+			}
+		}
+		for _, path := range tt.noMatch {
+			if robotsMatch(tt.pattern, path) {
+				t.Errorf("%q matches %q", tt.pattern, path)
+			}
+		}
+	}
+}
+
+func TestRobotsTest(t *testing.T) {
+	tests := []struct {
+		name  string
+		txt   string
+		agent string
+		url   string
+		allow bool
+		line  int // of the deciding rule, 0 for none
+	}{
+		{"longest match", "user-agent: *\nallow: /p\ndisallow: /\n", "bot", "https://example.com/page", true, 2},
+		{"tie goes to allow", "user-agent: *\nallow: /folder\ndisallow: /folder\n", "bot", "https://example.com/folder/page", true, 2},
+		{"wildcard is longer", "user-agent: *\nallow: /page\ndisallow: /*.htm\n", "bot", "https://example.com/page.htm", false, 3},
+		{"wildcard tie", "user-agent: *\nallow: /page\ndisallow: /*.ph\n", "bot", "https://example.com/page.php", true, 2},
+		{"anchored root", "user-agent: *\nallow: /$\ndisallow: /\n", "bot", "https://example.com/", true, 2},
+		{"anchored root, page", "user-agent: *\nallow: /$\ndisallow: /\n", "bot", "https://example.com/page.htm", false, 3},
+		{"empty path is root", "user-agent: *\nallow: /$\ndisallow: /\n", "bot", "https://example.com", true, 2},
+		{"no rule", "user-agent: *\ndisallow: /private\n", "bot", "https://example.com/public", true, 0},
+		{"empty disallow", "user-agent: *\ndisallow:\n", "bot", "https://example.com/a", true, 0},
+		{"query", "user-agent: *\ndisallow: /*?sort=\n", "bot", "https://example.com/list?sort=asc", false, 2},
+		{"comments", "user-agent: * # all\ndisallow: /a # not a\n", "bot", "https://example.com/a", false, 2},
+
+		// Escaped paths.
+		{"unicode pattern", "user-agent: *\ndisallow: /foo/bar/ツ\n", "bot", "https://example.com/foo/bar/%E3%83%84", false, 2},
+		{"unicode url", "user-agent: *\ndisallow: /foo/bar/%E3%83%84\n", "bot", "https://example.com/foo/bar/ツ", false, 2},
+		{"escape case", "user-agent: *\ndisallow: /foo/bar/%e3%83%84\n", "bot", "https://example.com/foo/bar/%E3%83%84", false, 2},
+		{"escaped letters", "user-agent: *\ndisallow: /foo/bar/%62%61%7A\n", "bot", "https://example.com/foo/bar/%62%61%7A", false, 2},
+		{"escaped query", "user-agent: *\ndisallow: /foo/bar?baz=https%3A%2F%2Ffoo.bar\n", "bot", "https://example.com/foo/bar?baz=https%3A%2F%2Ffoo.bar", false, 2},
+
+		// Groups of user agents.
+		{"specific agent", groups, "Googlebot-News", "https://example.com/fish", false, 2},
+		{"specific agent, merged groups", groups, "Googlebot-News", "https://example.com/shrimp", false, 8},
+		{"specific agent, not in its groups", groups, "Googlebot-News", "https://example.com/carrots", true, 0},
+		{"agent prefix", groups, "Googlebot-Image", "https://example.com/ham", false, 6},
+		{"agent case", groups, "GOOGLEBOT", "https://example.com/fish", true, 0},
+		{"other agent", groups, "Otherbot", "https://example.com/carrots", false, 4},
+		{"other agent, specific rules", groups, "Otherbot", "https://example.com/fish", true, 0},
+		{"shared group", "user-agent: a\nuser-agent: b\ndisallow: /x\n", "b", "https://example.com/x", false, 3},
+		{"no group", "user-agent: a\ndisallow: /x\n", "b", "https://example.com/x", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := parseRobots(strings.NewReader(tt.txt))
+			allow, rule := rt.test(tt.agent, tt.url)
+			if allow != tt.allow {
+				t.Errorf("allow %v, want %v (rule %v)", allow, tt.allow, rule)
+			}
+			line := 0
+			if rule != nil {
+				line = rule.line
+			}
+			if line != tt.line {
+				t.Errorf("decided by line %d, want %d", line, tt.line)
+			}
+		})
+	}
+}
+
+const groups = `user-agent: googlebot-news
+disallow: /fish
+user-agent: *
+disallow: /carrots
+user-agent: googlebot
+disallow: /ham
+user-agent: googlebot-news
+disallow: /shrimp
+`
+
+func TestRobotsUnavailable(t *testing.T) {
+	if allow, _ := (&robotsTxt{allowAll: true}).test("bot", "https://example.com/a"); !allow {
+		t.Error("missing robots.txt disallows")
+	}
+	if allow, _ := (&robotsTxt{disallowAll: true}).test("bot", "https://example.com/a"); allow {
+		t.Error("unreachable robots.txt allows")
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	nurl "net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Storage writes objects to an S3 compatible bucket (AWS,
+// MinIO or GCS with HMAC keys) using signature version 4.
+//
+// Credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+// and optionally AWS_SESSION_TOKEN. AWS_REGION selects the region and
+// AWS_ENDPOINT_URL a custom server, like a MinIO instance.
+type s3Storage struct {
+	endpoint *nurl.URL
+	bucket   string
+	prefix   string
+	region   string
+	key      string
+	secret   string
+	token    string
+	// pathStyle puts the bucket in the path instead of the host name.
+	pathStyle bool
+	client    *http.Client
+}
+
+func newS3Storage(uri string) (*s3Storage, error) {
+	u, err := nurl.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("no bucket in %s", uri)
+	}
+	s := &s3Storage{
+		bucket: u.Host,
+		prefix: strings.Trim(u.Path, "/"),
+		region: os.Getenv("AWS_REGION"),
+		key:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secret: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:  os.Getenv("AWS_SESSION_TOKEN"),
+		client: http.DefaultClient,
+	}
+	if s.key == "" || s.secret == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to write to %s", uri)
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL")
+	switch {
+	case endpoint != "":
+		s.pathStyle = true
+	case u.Scheme == "gs":
+		endpoint = "https://storage.googleapis.com"
+		s.pathStyle = true
+		if s.region == "" {
+			s.region = "auto"
+		}
+	default:
+		if s.region == "" {
+			s.region = "us-east-1"
+		}
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", s.region)
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	if s.endpoint, err = nurl.Parse(endpoint); err != nil {
+		return nil, fmt.Errorf("invalid endpoint %s: %s", endpoint, err)
+	}
+	return s, nil
+}
+
+// objectURL returns the URL of the object called name.
+func (s *s3Storage) objectURL(name string) *nurl.URL {
+	key := name
+	if s.prefix != "" {
+		key = s.prefix + "/" + name
+	}
+	u := *s.endpoint
+	if s.pathStyle {
+		u.Path = "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	return &u
+}
+
+func (s *s3Storage) put(name string, data []byte) error {
+	u := s.objectURL(name)
+	req, err := http.NewRequest("PUT", u.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	s.sign(req, u, data, time.Now().UTC())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot upload %s: %s", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("cannot upload %s: %s: %s", name, resp.Status, msg)
+	}
+	return nil
+}
+
+// sign adds a signature version 4 Authorization header to req,
+// signing the host and all headers already set.
+func (s *s3Storage) sign(req *http.Request, u *nurl.URL, payload []byte, now time.Time) {
+	amzdate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	phash := sha256hex(payload)
+	req.Header.Set("x-amz-date", amzdate)
+	req.Header.Set("x-amz-content-sha256", phash)
+	if s.token != "" {
+		req.Header.Set("x-amz-security-token", s.token)
+	}
+	headers := []string{"host"}
+	for h := range req.Header {
+		headers = append(headers, strings.ToLower(h))
+	}
+	sort.Strings(headers)
+	var canon bytes.Buffer
+	for _, h := range headers {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = u.Host
+		}
+		fmt.Fprintf(&canon, "%s:%s\n", h, strings.TrimSpace(v))
+	}
+	signed := strings.Join(headers, ";")
+	creq := strings.Join([]string{
+		req.Method,
+		u.EscapedPath(),
+		s3CanonicalQuery(u.Query()),
+		canon.String(),
+		signed,
+		phash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	tosign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzdate,
+		scope,
+		sha256hex([]byte(creq)),
+	}, "\n")
+	key := hmacSHA256([]byte("AWS4"+s.secret), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, tosign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.key, scope, signed, sig))
+}
+
+// s3CanonicalQuery returns the query parameters q sorted and
+// escaped as required by signature version 4.
+func s3CanonicalQuery(q nurl.Values) string {
+	var params [][2]string
+	for k, vs := range q {
+		for _, v := range vs {
+			params = append(params, [2]string{s3Escape(k, false), s3Escape(v, false)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+		return params[i][1] < params[j][1]
+	})
+	list := make([]string, len(params))
+	for i, p := range params {
+		list[i] = p[0] + "=" + p[1]
+	}
+	return strings.Join(list, "&")
+}
+
+// s3EscapePath escapes all but unreserved characters, as
+// required by signature version 4; slashes are kept.
+func s3EscapePath(p string) string {
+	return s3Escape(p, true)
+}
+
+// s3Escape escapes all but unreserved characters of p and, if
+// slash is true, slashes.
+func s3Escape(p string, slash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && slash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
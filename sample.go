@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"sort"
+	"strings"
+)
+
+// sampler limits the crawl to a sample of the URLs of each
+// pattern, so that large templated sites are audited at a
+// fraction of the cost. Patterns are those of urlPattern, with
+// the last path segment also taken for a {slug} once it has had
+// slugThreshold different values under the same parent. URLs
+// that are not an instance of a pattern, like section and
+// category pages, are hubs and always crawled.
+type sampler struct {
+	size    int
+	values  map[string]map[string]bool // last segments by parent
+	counts  map[string]int             // URLs sampled per pattern
+	skipped map[string]int
+	samples map[string]string // first URL skipped per pattern
+}
+
+func newSampler(size int) *sampler {
+	return &sampler{
+		size:    size,
+		values:  make(map[string]map[string]bool),
+		counts:  make(map[string]int),
+		skipped: make(map[string]int),
+		samples: make(map[string]string),
+	}
+}
+
+// pattern returns the pattern of url, or an empty string if url
+// is a hub.
+func (s *sampler) pattern(url string) string {
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return ""
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	// Segments with digits are already generalized by urlPattern.
+	if i := strings.LastIndex(path, "/"); i >= 0 && i < len(path)-1 && !strings.ContainsAny(path[i:], "0123456789") {
+		parent, last := u.Host+path[:i], path[i+1:]
+		if s.values[parent] == nil {
+			s.values[parent] = make(map[string]bool)
+		}
+		s.values[parent][last] = true
+		if len(s.values[parent]) >= slugThreshold {
+			g := *u
+			g.Path = path[:i] + "/{slug}" + strings.TrimPrefix(u.Path, path)
+			u = &g
+		}
+	}
+	p := urlPattern(u.String())
+	if !strings.Contains(p, "{") && !strings.Contains(p, "?") {
+		return ""
+	}
+	return p
+}
+
+// allow returns false if the newly discovered url is an instance
+// of a pattern that already has its sample.
+func (s *sampler) allow(url string) bool {
+	p := s.pattern(url)
+	if p == "" {
+		return true
+	}
+	if s.counts[p] >= s.size {
+		if s.skipped[p] == 0 {
+			s.samples[p] = url
+		}
+		s.skipped[p]++
+		return false
+	}
+	s.counts[p]++
+	return true
+}
+
+// report adds a "crawl-sampled" finding for each pattern of which
+// only a sample was crawled, about the first URL left out.
+func (s *sampler) report(rep *report) {
+	patterns := make([]string, 0, len(s.skipped))
+	for p := range s.skipped {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	for _, p := range patterns {
+		rep.add("crawl-sampled", s.samples[p], fmt.Sprintf("%s: %d URLs crawled, %d not", p, s.counts[p], s.skipped[p]))
+	}
+}
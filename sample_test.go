@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSamplerPattern(t *testing.T) {
+	s := newSampler(1)
+	tests := []struct {
+		url, want string
+	}{
+		{"http://example.test/", ""},
+		{"http://example.test/about", ""},
+		{"http://example.test/p/123", "example.test/p/{n}"},
+		{"http://example.test/list?page=2", "example.test/list?page"},
+		{"http://example.test/blog/a", ""},
+		{"http://example.test/blog/b", ""},
+		{"http://example.test/blog/c", ""},
+		{"http://example.test/blog/d", ""},
+		// The fifth value under /blog makes it a slug.
+		{"http://example.test/blog/e", "example.test/blog/{slug}"},
+		{"http://example.test/blog/a", "example.test/blog/{slug}"},
+		{"http://example.test/blog/f/", "example.test/blog/{slug}/"},
+		{"http://other.test/blog/a", ""},
+	}
+	for _, tt := range tests {
+		if got := s.pattern(tt.url); got != tt.want {
+			t.Errorf("pattern(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestSamplerAllow(t *testing.T) {
+	urls := []string{
+		"/p/1", "/p/2", "/about", "/p/3", "/list?page=1", "/list?page=2", "/list?page=3",
+		"/blog/a", "/blog/b", "/blog/c", "/blog/d", "/blog/e", "/blog/f", "/blog/g", "/p/4",
+	}
+	want := []string{
+		"/p/1", "/p/2", "/about", "/list?page=1", "/list?page=2",
+		"/blog/a", "/blog/b", "/blog/c", "/blog/d", "/blog/e", "/blog/f",
+	}
+	sample := func() ([]string, *report) {
+		s := newSampler(2)
+		var allowed []string
+		for _, u := range urls {
+			if s.allow("http://example.test" + u) {
+				allowed = append(allowed, u)
+			}
+		}
+		rep := newReport("http://example.test/", nil)
+		s.report(rep)
+		return allowed, rep
+	}
+	got, rep := sample()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	wantFindings := []finding{
+		{Kind: "crawl-sampled", URL: "http://example.test/blog/g", Detail: "example.test/blog/{slug}: 2 URLs crawled, 1 not"},
+		{Kind: "crawl-sampled", URL: "http://example.test/list?page=3", Detail: "example.test/list?page: 2 URLs crawled, 1 not"},
+		{Kind: "crawl-sampled", URL: "http://example.test/p/3", Detail: "example.test/p/{n}: 2 URLs crawled, 2 not"},
+	}
+	if !reflect.DeepEqual(rep.findings, wantFindings) {
+		t.Errorf("got findings %v, want %v", rep.findings, wantFindings)
+	}
+	// The same URLs give the same sample.
+	for i := 0; i < 5; i++ {
+		if again, _ := sample(); !reflect.DeepEqual(again, got) {
+			t.Fatalf("sample %v differs from %v", again, got)
+		}
+	}
+}
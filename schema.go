@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+// SchemaVersion is the version of the JSON format of the results.
+// Fields may be added within a version; it changes only when
+// fields are removed or change meaning.
+const SchemaVersion = 1
+
+// Finding is an issue found about URL, as exported.
+type Finding struct {
+	Kind   string `json:"kind"`
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Edge is a link from the page at From to the URL To.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Result is the exported outcome of crawling a URL.
+type Result struct {
+	URL         string    `json:"url"`
+	Status      int       `json:"status,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Depth       int       `json:"depth"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Lang        string    `json:"lang,omitempty"`
+	Canonical   string    `json:"canonical,omitempty"`
+	Robots      string    `json:"robots,omitempty"`
+	XRobotsTag  string    `json:"x_robots_tag,omitempty"`
+	Hash        string    `json:"hash,omitempty"`
+	Protocol    string    `json:"protocol,omitempty"`
+	RemoteAddr  string    `json:"remote_addr,omitempty"`
+	IPFamily    string    `json:"ip_family,omitempty"`
+	Redirects   int       `json:"redirects,omitempty"`
+	Findings    []Finding `json:"findings"`
+}
+
+// Report is the exported outcome of a crawl.
+type Report struct {
+	SchemaVersion int       `json:"schema_version"`
+	Base          string    `json:"base"`
+	CrawledAt     time.Time `json:"crawled_at"`
+	// Truncated is set when a budget ended the crawl early.
+	Truncated string `json:"truncated,omitempty"`
+	// Interrupted is set when a signal stopped the crawl.
+	Interrupted bool      `json:"interrupted,omitempty"`
+	Results     []Result  `json:"results"`
+	Findings    []Finding `json:"findings"`
+	Edges       []Edge    `json:"edges"`
+}
+
+// partial returns true if the crawl did not cover the whole site.
+func (r *Report) partial() bool {
+	return r.Truncated != "" || r.Interrupted
+}
+
+func exportFindings(fs []finding) []Finding {
+	list := make([]Finding, 0, len(fs))
+	for _, f := range fs {
+		list = append(list, Finding{Kind: f.Kind, URL: f.URL, Detail: f.Detail})
+	}
+	return list
+}
+
+// newResult exports res.
+func newResult(res *result) Result {
+	r := Result{
+		URL:         res.URL,
+		Status:      res.Status,
+		Depth:       res.Depth,
+		Title:       res.Title,
+		Description: res.Description,
+		Lang:        res.Lang,
+		Canonical:   res.Canonical,
+		Robots:      res.Robots,
+		XRobotsTag:  res.XRobotsTag,
+		Hash:        res.Hash,
+		Protocol:    res.Proto,
+		RemoteAddr:  res.RemoteAddr,
+		IPFamily:    ipFamily(res.RemoteAddr),
+		Redirects:   len(res.Redirects),
+		Findings:    exportFindings(res.Findings),
+	}
+	if res.Err != nil {
+		r.Error = res.Err.Error()
+	}
+	return r
+}
+
+// export returns the results, findings and internal links of
+// the report in their exported form.
+func (r *report) export() *Report {
+	e := &Report{
+		SchemaVersion: SchemaVersion,
+		Base:          r.base,
+		CrawledAt:     now().UTC(),
+		Truncated:     r.truncated,
+		Interrupted:   r.interrupted,
+		Results:       make([]Result, 0, len(r.results)),
+		Findings:      exportFindings(r.findings),
+		Edges:         make([]Edge, 0),
+	}
+	for _, res := range r.sorted() {
+		e.Results = append(e.Results, newResult(res))
+		links := append([]string{}, res.Links...)
+		sort.Strings(links)
+		for i, l := range links {
+			if i > 0 && l == links[i-1] {
+				continue
+			}
+			e.Edges = append(e.Edges, Edge{From: res.URL, To: l})
+		}
+	}
+	return e
+}
+
+// writeJSON writes the exported report as indented JSON.
+func (r *report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.export())
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ldRule lists the properties a schema.org type must have and
+// those it should have. "a|b" is satisfied by either property.
+type ldRule struct {
+	required    []string
+	recommended []string
+}
+
+// ldRules follow what search engines expect for rich results.
+var ldRules = map[string]ldRule{
+	"Product": {
+		required:    []string{"name", "offers|review|aggregateRating"},
+		recommended: []string{"image", "description", "brand", "sku"},
+	},
+	"Offer": {
+		required:    []string{"price|priceSpecification"},
+		recommended: []string{"priceCurrency|priceSpecification", "availability", "url"},
+	},
+	"AggregateRating": {
+		required: []string{"ratingValue", "ratingCount|reviewCount"},
+	},
+	"Review": {
+		required:    []string{"author", "reviewRating"},
+		recommended: []string{"datePublished"},
+	},
+	"Article": {
+		required:    []string{"headline"},
+		recommended: []string{"image", "datePublished", "dateModified", "author"},
+	},
+	"NewsArticle": {
+		required:    []string{"headline"},
+		recommended: []string{"image", "datePublished", "dateModified", "author"},
+	},
+	"BlogPosting": {
+		required:    []string{"headline"},
+		recommended: []string{"image", "datePublished", "dateModified", "author"},
+	},
+	"BreadcrumbList": {
+		required: []string{"itemListElement"},
+	},
+	"ListItem": {
+		required:    []string{"position"},
+		recommended: []string{"name|item"},
+	},
+	"Organization": {
+		recommended: []string{"name", "url", "logo"},
+	},
+	"LocalBusiness": {
+		required:    []string{"name", "address"},
+		recommended: []string{"telephone", "openingHoursSpecification|openingHours", "geo", "url"},
+	},
+	"Event": {
+		required:    []string{"name", "startDate", "location"},
+		recommended: []string{"endDate", "image", "description", "offers", "organizer"},
+	},
+	"Recipe": {
+		required:    []string{"name", "image"},
+		recommended: []string{"recipeIngredient", "recipeInstructions", "author", "totalTime"},
+	},
+	"FAQPage": {
+		required: []string{"mainEntity"},
+	},
+	"Question": {
+		required: []string{"name", "acceptedAnswer|suggestedAnswer"},
+	},
+	"VideoObject": {
+		required:    []string{"name", "thumbnailUrl", "uploadDate"},
+		recommended: []string{"description", "contentUrl|embedUrl", "duration"},
+	},
+}
+
+// hasProperty returns true if o has a non-empty value for one
+// of the alternatives of prop.
+func hasProperty(o map[string]interface{}, prop string) bool {
+	for _, p := range strings.Split(prop, "|") {
+		switch v := o[p].(type) {
+		case nil:
+		case string:
+			if strings.TrimSpace(v) != "" {
+				return true
+			}
+		case []interface{}:
+			if len(v) > 0 {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// ldTypes returns the types of the JSON-LD object o.
+func ldTypes(o map[string]interface{}) []string {
+	switch t := o["@type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var ts []string
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				ts = append(ts, s)
+			}
+		}
+		return ts
+	}
+	return nil
+}
+
+// validateLD checks all typed objects in v, also nested ones,
+// against ldRules and adds what they miss to fs.
+func validateLD(v interface{}, fs []finding) []finding {
+	switch t := v.(type) {
+	case []interface{}:
+		for _, e := range t {
+			fs = validateLD(e, fs)
+		}
+	case map[string]interface{}:
+		for _, typ := range ldTypes(t) {
+			rule, ok := ldRules[strings.TrimPrefix(typ, "schema:")]
+			if !ok {
+				continue
+			}
+			for _, p := range rule.required {
+				if !hasProperty(t, p) {
+					fs = append(fs, finding{Kind: "ld-missing-required", Detail: typ + "." + p})
+				}
+			}
+			for _, p := range rule.recommended {
+				if !hasProperty(t, p) {
+					fs = append(fs, finding{Kind: "ld-missing-recommended", Detail: typ + "." + p})
+				}
+			}
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fs = validateLD(t[k], fs)
+		}
+	}
+	return fs
+}
+
+// ldContext returns an issue if the top-level JSON-LD value v
+// does not declare schema.org as its context.
+func ldContext(v interface{}) *finding {
+	o, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if _, ok := o["@graph"]; !ok && o["@type"] == nil {
+		return nil
+	}
+	if ctx := jsonString(o["@context"]); !strings.Contains(ctx, "schema.org") {
+		return &finding{Kind: "ld-missing-context", Detail: fmt.Sprintf("context %q", ctx)}
+	}
+	return nil
+}
+
+// checkStructuredData adds the structured data problems found
+// on each page, grouped by type of problem.
+func checkStructuredData(rep *report) {
+	for _, res := range rep.sorted() {
+		if res.page() {
+			rep.addIssues(res.URL, res.Structured)
+		}
+	}
+}
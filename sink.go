@@ -0,0 +1,99 @@
+package main
+
+import (
+	nurl "net/url"
+	"time"
+)
+
+// sink is a destination for crawl results, like a
+// database or a search index.
+type sink interface {
+	// put stores the result of a single URL.
+	put(res *result) error
+	// close flushes buffered results and releases resources.
+	close() error
+}
+
+// drain writes all results of rep to each sink and closes them.
+func drain(rep *report, sinks []sink) error {
+	for _, s := range sinks {
+		for _, res := range rep.sorted() {
+			if err := s.put(res); err != nil {
+				return err
+			}
+		}
+		if err := s.close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type docCrux struct {
+	LCP    float64 `json:"lcp"`
+	CLS    float64 `json:"cls"`
+	INP    float64 `json:"inp"`
+	Origin bool    `json:"origin"`
+}
+
+type docGsc struct {
+	Clicks      float64 `json:"clicks"`
+	Impressions float64 `json:"impressions"`
+	Coverage    string  `json:"coverage,omitempty"`
+	Verdict     string  `json:"verdict,omitempty"`
+}
+
+type docReadability struct {
+	Words     int     `json:"words"`
+	Sentences int     `json:"sentences"`
+	Ease      float64 `json:"ease"`
+	Grade     float64 `json:"grade"`
+}
+
+type docFinding struct {
+	Kind   string `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// doc is the flat, per-URL record that sinks store: the exported
+// result with the details only sinks need.
+type doc struct {
+	Result
+	SchemaVersion int             `json:"schema_version"`
+	Host          string          `json:"host"`
+	Path          string          `json:"path"`
+	CrawledAt     time.Time       `json:"crawled_at"`
+	Outlinks      int             `json:"outlinks"`
+	CrUX          *docCrux        `json:"crux,omitempty"`
+	GSC           *docGsc         `json:"gsc,omitempty"`
+	Readability   *docReadability `json:"readability,omitempty"`
+	// Findings replaces those of Result, which repeat the URL.
+	Findings []docFinding `json:"findings"`
+}
+
+func newDoc(res *result, crawledAt time.Time) *doc {
+	d := &doc{
+		Result:        newResult(res),
+		SchemaVersion: SchemaVersion,
+		CrawledAt:     crawledAt,
+		Outlinks:      len(res.Links),
+		Findings:      make([]docFinding, 0, len(res.Findings)),
+	}
+	if u, err := nurl.Parse(res.URL); err == nil {
+		d.Host = u.Host
+		d.Path = u.Path
+	}
+	if r := res.CrUX; r != nil {
+		d.CrUX = &docCrux{LCP: r.LCP, CLS: r.CLS, INP: r.INP, Origin: r.Origin}
+	}
+	if g := res.GSC; g != nil {
+		d.GSC = &docGsc{Clicks: g.Clicks, Impressions: g.Impressions, Coverage: g.Coverage, Verdict: g.Verdict}
+	}
+	if r := res.Readability; r != nil && r.Words > 0 {
+		d.Readability = &docReadability{Words: r.Words, Sentences: r.Sentences, Ease: r.Ease, Grade: r.Grade}
+	}
+	for _, f := range res.Findings {
+		d.Findings = append(d.Findings, docFinding{Kind: f.Kind, Detail: f.Detail})
+	}
+	return d
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+)
+
+// maxSitemapURLs is the limit of URLs in a single sitemap file.
+const maxSitemapURLs = 50000
+
+const (
+	sitemapNS      = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	sitemapImageNS = "http://www.google.com/schemas/sitemap-image/1.1"
+	sitemapVideoNS = "http://www.google.com/schemas/sitemap-video/1.1"
+	sitemapXHTMLNS = "http://www.w3.org/1999/xhtml"
+)
+
+// maxVideoDescription is the longest video description allowed.
+const maxVideoDescription = 2048
+
+type sitemapImage struct {
+	Loc string `xml:"image:loc"`
+}
+
+type sitemapVideo struct {
+	ThumbnailLoc    string `xml:"video:thumbnail_loc"`
+	Title           string `xml:"video:title"`
+	Description     string `xml:"video:description"`
+	ContentLoc      string `xml:"video:content_loc,omitempty"`
+	PlayerLoc       string `xml:"video:player_loc,omitempty"`
+	Duration        int    `xml:"video:duration,omitempty"`
+	PublicationDate string `xml:"video:publication_date,omitempty"`
+}
+
+type sitemapLink struct {
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+type sitemapURL struct {
+	Loc    string         `xml:"loc"`
+	Links  []sitemapLink  `xml:"xhtml:link,omitempty"`
+	Images []sitemapImage `xml:"image:image,omitempty"`
+	Videos []sitemapVideo `xml:"video:video,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	NS      string       `xml:"xmlns,attr"`
+	ImageNS string       `xml:"xmlns:image,attr,omitempty"`
+	VideoNS string       `xml:"xmlns:video,attr,omitempty"`
+	XHTMLNS string       `xml:"xmlns:xhtml,attr,omitempty"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// indexable returns true if res is a page that search engines
+// should index: it loaded without redirects, is not noindex and is
+// its own canonical.
+func (res *result) indexable() bool {
+	return res.loaded() && !res.noindex() &&
+		(res.Canonical == "" || sameURL(res.Canonical, res.URL))
+}
+
+// sitemapVideos returns the videos of res that have what a video
+// sitemap requires: a thumbnail and the video or its player. The
+// title and description of the page stand in for missing ones.
+func sitemapVideos(res *result) []sitemapVideo {
+	var vs []sitemapVideo
+	for _, v := range res.Videos {
+		if v.ThumbnailURL == "" || (v.ContentURL == "" && v.PlayerURL == "") {
+			slog.Debug("video left out of sitemap", "url", res.URL, "video", v.ContentURL+v.PlayerURL)
+			continue
+		}
+		sv := sitemapVideo{
+			ThumbnailLoc:    v.ThumbnailURL,
+			Title:           v.Title,
+			Description:     v.Description,
+			ContentLoc:      v.ContentURL,
+			PlayerLoc:       v.PlayerURL,
+			Duration:        v.Duration,
+			PublicationDate: v.UploadDate,
+		}
+		if sv.Title == "" {
+			sv.Title = res.Title
+		}
+		if sv.Description == "" {
+			sv.Description = res.Description
+		}
+		if d := []rune(sv.Description); len(d) > maxVideoDescription {
+			sv.Description = string(d[:maxVideoDescription])
+		}
+		vs = append(vs, sv)
+	}
+	return vs
+}
+
+// sitemapOptions choose what is added to each page of a sitemap.
+type sitemapOptions struct {
+	images    bool
+	videos    bool
+	hreflangs bool
+}
+
+// sitemapURLs returns an entry for each indexable page, with
+// what else so chooses.
+func (r *report) sitemapURLs(so sitemapOptions) []sitemapURL {
+	urls := make([]sitemapURL, 0)
+	for _, res := range r.sorted() {
+		if !res.indexable() {
+			continue
+		}
+		u := sitemapURL{Loc: res.URL}
+		if so.hreflangs {
+			for _, h := range res.Hreflangs {
+				u.Links = append(u.Links, sitemapLink{Rel: "alternate", Hreflang: h.Lang, Href: h.URL})
+			}
+		}
+		if so.images {
+			seen := make(map[string]bool)
+			for _, img := range res.Images {
+				if !seen[img.URL] {
+					seen[img.URL] = true
+					u.Images = append(u.Images, sitemapImage{Loc: img.URL})
+				}
+			}
+		}
+		if so.videos {
+			u.Videos = sitemapVideos(res)
+		}
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// writeSitemaps stores sitemap.xml with the indexable pages; more
+// than maxSitemapURLs are split over sitemap-2.xml and following.
+func (r *report) writeSitemaps(st storage, so sitemapOptions) error {
+	urls := r.sitemapURLs(so)
+	for i := 0; i == 0 || i*maxSitemapURLs < len(urls); i++ {
+		end := (i + 1) * maxSitemapURLs
+		if end > len(urls) {
+			end = len(urls)
+		}
+		set := sitemapURLSet{NS: sitemapNS, URLs: urls[i*maxSitemapURLs : end]}
+		if so.images {
+			set.ImageNS = sitemapImageNS
+		}
+		if so.videos {
+			set.VideoNS = sitemapVideoNS
+		}
+		if so.hreflangs {
+			set.XHTMLNS = sitemapXHTMLNS
+		}
+		var buf bytes.Buffer
+		buf.WriteString(xml.Header)
+		enc := xml.NewEncoder(&buf)
+		enc.Indent("", "  ")
+		if err := enc.Encode(set); err != nil {
+			return fmt.Errorf("cannot encode sitemap: %s", err)
+		}
+		buf.WriteString("\n")
+		name := "sitemap.xml"
+		if i > 0 {
+			name = fmt.Sprintf("sitemap-%d.xml", i+1)
+		}
+		if err := st.put(name, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
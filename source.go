@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// dialTimeout is the connection timeout and keep-alive interval
+// of connections from a source IP, as in http.DefaultTransport.
+const dialTimeout = 30 * time.Second
+
+// httpTransport returns a copy of the transport of f, or of the
+// default one, to be changed and set with setTransport.
+func (f *fetcher) httpTransport() *http.Transport {
+	if t, ok := f.transport.(*http.Transport); ok {
+		return t.Clone()
+	}
+	return http.DefaultTransport.(*http.Transport).Clone()
+}
+
+// sourceIPs hands out local addresses in turn.
+type sourceIPs struct {
+	mux  sync.Mutex
+	ips  []net.IP
+	next int
+}
+
+func (s *sourceIPs) take() net.IP {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	ip := s.ips[s.next]
+	s.next = (s.next + 1) % len(s.ips)
+	return ip
+}
+
+// ipFamilies are the networks to dial for each IP family:
+// "4" and "6" only, or "dual" for both.
+var ipFamilies = map[string]string{
+	"4":    "tcp4",
+	"6":    "tcp6",
+	"dual": "tcp",
+}
+
+// setDialing makes f open its connections only over the IP
+// family given, a key of ipFamilies, and from the local addresses
+// ips, taking them in turn for each new connection.
+func (f *fetcher) setDialing(family string, ips []string) error {
+	network, ok := ipFamilies[family]
+	if !ok {
+		return fmt.Errorf("unknown IP family %s", family)
+	}
+	src := &sourceIPs{}
+	for _, s := range ips {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return fmt.Errorf("%s is not an IP address", s)
+		}
+		src.ips = append(src.ips, ip)
+	}
+	if len(src.ips) == 0 && network == "tcp" {
+		return nil
+	}
+	t := f.httpTransport()
+	t.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		d := &net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: dialTimeout,
+		}
+		if len(src.ips) > 0 {
+			d.LocalAddr = &net.TCPAddr{IP: src.take()}
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+	f.setTransport(t)
+	return nil
+}
+
+// ipFamily returns "4" or "6" for the family of addr, a host
+// and port, or an empty string if it is not an IP address.
+func ipFamily(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return "4"
+	}
+	return "6"
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// maxMisspellings is how many misspelled words a finding lists.
+const maxMisspellings = 20
+
+// speller checks words against per-language dictionaries.
+type speller struct {
+	dicts  map[string]map[string]bool
+	def    string // language of pages that declare none we know
+	ignore map[string]bool
+}
+
+// readWords reads a word list, one word per line. Hunspell .dic
+// files are accepted: the leading count and the /FLAGS suffixes
+// are skipped. Lines starting with # are comments.
+func readWords(name string) (map[string]bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	words := make(map[string]bool)
+	sc := bufio.NewScanner(f)
+	first := true
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if first && strings.Trim(line, "0123456789") == "" {
+			first = false
+			continue
+		}
+		first = false
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.Index(line, "/"); i >= 0 {
+			line = line[:i]
+		}
+		words[strings.ToLower(line)] = true
+	}
+	return words, sc.Err()
+}
+
+// newSpeller loads the dictionaries given as LANG=FILE; the first
+// language is used for pages in other languages. ignore is an
+// optional file of words that are always correct.
+func newSpeller(dicts []string, ignore string) (*speller, error) {
+	sp := &speller{dicts: make(map[string]map[string]bool)}
+	for _, d := range dicts {
+		i := strings.Index(d, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("dictionary %q is not LANG=FILE", d)
+		}
+		lang := strings.ToLower(d[:i])
+		words, err := readWords(d[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("cannot read dictionary: %s", err)
+		}
+		sp.dicts[lang] = words
+		if sp.def == "" {
+			sp.def = lang
+		}
+	}
+	if ignore != "" {
+		words, err := readWords(ignore)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read ignore list: %s", err)
+		}
+		sp.ignore = words
+	}
+	return sp, nil
+}
+
+// checkable returns false for words a dictionary cannot be
+// expected to have: short ones, acronyms and those with digits.
+func checkable(word string) bool {
+	if len([]rune(word)) < 3 || strings.ToUpper(word) == word {
+		return false
+	}
+	for _, r := range word {
+		if !unicode.IsLetter(r) && r != '\'' {
+			return false
+		}
+	}
+	return true
+}
+
+// misspelled returns the words of text, in order of appearance
+// and only once, that are not in the dictionary for lang.
+func (sp *speller) misspelled(text, lang string) []string {
+	dict, ok := sp.dicts[newsLanguage(lang)]
+	if !ok {
+		dict = sp.dicts[sp.def]
+	}
+	seen := make(map[string]bool)
+	var bad []string
+	for _, f := range strings.Fields(text) {
+		for _, w := range strings.FieldsFunc(f, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+		}) {
+			w = strings.TrimSuffix(strings.Trim(w, "'"), "'s")
+			lw := strings.ToLower(w)
+			if seen[lw] || !checkable(w) || dict[lw] || sp.ignore[lw] {
+				continue
+			}
+			seen[lw] = true
+			bad = append(bad, w)
+		}
+	}
+	return bad
+}
+
+// spellAnalyzer finds the misspelled words of the visible text
+// of a page.
+type spellAnalyzer struct {
+	sp   *speller
+	text textAnalyzer
+}
+
+func (a *spellAnalyzer) token(t *html.Token) {
+	a.text.token(t)
+}
+
+func (a *spellAnalyzer) finish(res *result) {
+	res.Misspelled = a.sp.misspelled(a.text.text.String(), res.Lang)
+}
+
+// checkSpelling adds a "misspelling" finding for each page with
+// words that are not in its dictionary.
+func checkSpelling(rep *report) {
+	for _, res := range rep.sorted() {
+		if len(res.Misspelled) == 0 {
+			continue
+		}
+		words := append([]string{}, res.Misspelled...)
+		sort.Strings(words)
+		detail := fmt.Sprintf("%d: ", len(words))
+		if len(words) > maxMisspellings {
+			words = words[:maxMisspellings]
+			detail += strings.Join(words, ", ") + ", ..."
+		} else {
+			detail += strings.Join(words, ", ")
+		}
+		rep.add("misspelling", res.URL, detail)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"sort"
+)
+
+// checkSRI adds a "missing-sri" finding for each script and
+// stylesheet loaded from another site without an integrity hash,
+// and a "sri-no-crossorigin" finding for those with a hash but no
+// crossorigin attribute, which browsers then refuse to load.
+func checkSRI(rep *report) {
+	base, err := nurl.Parse(rep.base)
+	if err != nil {
+		return
+	}
+	own := provider(base.Hostname())
+	type use struct {
+		kind  string
+		pages []string
+	}
+	missing := make(map[string]*use)
+	nocors := make(map[string]*use)
+	for _, res := range rep.sorted() {
+		if !res.page() {
+			continue
+		}
+		for _, r := range res.Resources {
+			u, err := nurl.Parse(r.URL)
+			if err != nil || u.Hostname() == "" || provider(u.Hostname()) == own {
+				continue
+			}
+			var m map[string]*use
+			switch {
+			case r.Integrity == "":
+				m = missing
+			case !r.CrossOrigin:
+				m = nocors
+			default:
+				continue
+			}
+			if m[r.URL] == nil {
+				m[r.URL] = &use{kind: r.Kind}
+			}
+			if p := m[r.URL].pages; len(p) == 0 || p[len(p)-1] != res.URL {
+				m[r.URL].pages = append(p, res.URL)
+			}
+		}
+	}
+	for _, kind := range []string{"missing-sri", "sri-no-crossorigin"} {
+		m := missing
+		if kind == "sri-no-crossorigin" {
+			m = nocors
+		}
+		urls := make([]string, 0, len(m))
+		for url := range m {
+			urls = append(urls, url)
+		}
+		sort.Strings(urls)
+		for _, url := range urls {
+			what := "script"
+			if m[url].kind == "css" {
+				what = "stylesheet"
+			}
+			rep.add(kind, url, fmt.Sprintf("%s on %s", what, pageList(m[url].pages)))
+		}
+	}
+}
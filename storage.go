@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// storage is a place where output files, like results,
+// are written to. Names are slash separated.
+type storage interface {
+	put(name string, data []byte) error
+}
+
+// openStorage returns the storage for uri: s3:// and gs://
+// URIs refer to buckets, anything else is a local directory.
+// An empty uri is the current directory.
+func openStorage(uri string) (storage, error) {
+	switch {
+	case uri == "":
+		return newDirStorage(".")
+	case strings.HasPrefix(uri, "s3://"), strings.HasPrefix(uri, "gs://"):
+		return newS3Storage(uri)
+	}
+	return newDirStorage(uri)
+}
+
+type dirStorage struct {
+	dir string
+}
+
+func newDirStorage(dir string) (*dirStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &dirStorage{dir: dir}, nil
+}
+
+func (d *dirStorage) put(name string, data []byte) error {
+	fname := filepath.Join(d.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fname, data, 0644)
+}
+
+// writeFile creates the local file name and fills it with write.
+func writeFile(name string, write func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// tagSignature recognizes an analytics or advertising tag by the
+// URL of the script or pixel that loads it, also when the URL is
+// built by an inline snippet, and its account in the URL or in
+// the snippet.
+type tagSignature struct {
+	name string
+	url  *regexp.Regexp
+	// id matches the account; its first group, if any, is it.
+	id *regexp.Regexp
+}
+
+var tagSignatures = []tagSignature{
+	{"google-tag-manager", regexp.MustCompile(`googletagmanager\.com/gtm\.js`), regexp.MustCompile(`GTM-[A-Z0-9]+`)},
+	{"google-tag", regexp.MustCompile(`googletagmanager\.com/gtag/js`), regexp.MustCompile(`\b(?:G|AW|DC)-[A-Z0-9]+|UA-\d+-\d+`)},
+	{"google-analytics", regexp.MustCompile(`google-analytics\.com/(?:analytics|ga)\.js`), regexp.MustCompile(`UA-\d+-\d+`)},
+	{"facebook-pixel", regexp.MustCompile(`connect\.facebook\.net/[^/]+/fbevents\.js|facebook\.com/tr\b`), regexp.MustCompile(`fbq\(\s*['"]init['"]\s*,\s*['"](\d+)|[?&]id=(\d+)`)},
+	{"linkedin-insight", regexp.MustCompile(`snap\.licdn\.com/li\.lms-analytics|px\.ads\.linkedin\.com`), regexp.MustCompile(`_linkedin_partner_id\s*=\s*['"]?(\d+)|[?&]pid=(\d+)`)},
+	{"bing-uet", regexp.MustCompile(`bat\.bing\.com/(?:bat\.js|action)`), regexp.MustCompile(`\bti\s*:\s*['"](\d+)|[?&]ti=(\d+)`)},
+	{"hotjar", regexp.MustCompile(`static\.hotjar\.com/c/hotjar-`), regexp.MustCompile(`hjid\s*:\s*(\d+)|hotjar-(\d+)`)},
+	{"matomo", regexp.MustCompile(`/(?:matomo|piwik)\.(?:js|php)\b`), regexp.MustCompile(`setSiteId['"]\s*,\s*['"]?(\d+)|[?&]idsite=(\d+)`)},
+	{"plausible", regexp.MustCompile(`plausible\.io/js/`), nil},
+}
+
+// tag is an analytics tag or tracking pixel loaded by a page.
+type tag struct {
+	Name string
+	ID   string // account, if known
+}
+
+func (t tag) String() string {
+	if t.ID == "" {
+		return t.Name
+	}
+	return t.Name + " " + t.ID
+}
+
+// matchTag returns the tag loaded by the URL or inline snippet s.
+func matchTag(s string) (tag, bool) {
+	for _, sig := range tagSignatures {
+		if !sig.url.MatchString(s) {
+			continue
+		}
+		t := tag{Name: sig.name}
+		if sig.id != nil {
+			for i, m := range sig.id.FindStringSubmatch(s) {
+				if m != "" && (i > 0 || sig.id.NumSubexp() == 0) {
+					t.ID = m
+					break
+				}
+			}
+		}
+		return t, true
+	}
+	return tag{}, false
+}
+
+// tagAnalyzer finds the tags loaded by the scripts, inline or
+// not, and the images of the page, once for each script or image.
+// Fallbacks inside noscript are not seen.
+type tagAnalyzer struct {
+	script textCapture
+	tags   []tag
+}
+
+func (a *tagAnalyzer) add(s string) {
+	if t, ok := matchTag(s); ok {
+		a.tags = append(a.tags, t)
+	}
+}
+
+func (a *tagAnalyzer) token(t *html.Token) {
+	if a.script.active() {
+		if a.script.token(t) {
+			a.add(a.script.text.String())
+		}
+		return
+	}
+	if t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken {
+		return
+	}
+	switch t.Data {
+	case "script":
+		if src, ok := attr(t, "src"); ok {
+			a.add(src)
+		} else if t.Type == html.StartTagToken {
+			a.script.start("script")
+		}
+	case "img":
+		src, _ := attr(t, "src")
+		a.add(src)
+	}
+}
+
+func (a *tagAnalyzer) finish(res *result) {
+	res.Tags = a.tags
+}
+
+// requiredTag is a tag every page must load, of any account if
+// ID is empty.
+type requiredTag tag
+
+// newRequiredTag parses a tag as "NAME" or "NAME=ID".
+func newRequiredTag(s string) (requiredTag, error) {
+	name, id := s, ""
+	if i := strings.Index(s, "="); i >= 0 {
+		name, id = s[:i], s[i+1:]
+	}
+	for _, sig := range tagSignatures {
+		if sig.name == name {
+			return requiredTag{Name: name, ID: id}, nil
+		}
+	}
+	names := make([]string, len(tagSignatures))
+	for i, sig := range tagSignatures {
+		names[i] = sig.name
+	}
+	return requiredTag{}, fmt.Errorf("unknown tag %q, known are: %s", name, strings.Join(names, ", "))
+}
+
+// checkTags adds a "tag-duplicate" finding for each page loading
+// the same tag more than once, which counts each visit twice, and
+// a "tag-missing" one for each page lacking one of required.
+func checkTags(rep *report, required []requiredTag) {
+	for _, res := range rep.sorted() {
+		if !res.page() {
+			continue
+		}
+		counts := make(map[tag]int)
+		var loaded []tag
+		for _, t := range res.Tags {
+			if counts[t] == 0 {
+				loaded = append(loaded, t)
+			}
+			counts[t]++
+		}
+		for _, t := range loaded {
+			if n := counts[t]; n > 1 {
+				rep.add("tag-duplicate", res.URL, fmt.Sprintf("%s loaded %d times", t, n))
+			}
+		}
+		for _, req := range required {
+			found := false
+			for _, t := range loaded {
+				if t.Name == req.Name && (req.ID == "" || t.ID == req.ID) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				rep.add("tag-missing", res.URL, tag(req).String())
+			}
+		}
+	}
+}
+
+// writeTags prints each tag found with the number of pages
+// loading it.
+func (r *report) writeTags(w io.Writer) error {
+	pages := make(map[tag]int)
+	for _, res := range r.sorted() {
+		seen := make(map[tag]bool)
+		for _, t := range res.Tags {
+			if !seen[t] {
+				seen[t] = true
+				pages[t]++
+			}
+		}
+	}
+	tags := make([]tag, 0, len(pages))
+	for t := range pages {
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Name != tags[j].Name {
+			return tags[i].Name < tags[j].Name
+		}
+		return tags[i].ID < tags[j].ID
+	})
+	for _, t := range tags {
+		id := t.ID
+		if id == "" {
+			id = "-"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%d pages\n", t.Name, id, pages[t]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
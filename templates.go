@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	htemplate "html/template"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	ttemplate "text/template"
+)
+
+// templateFuncs are available to user report templates.
+var templateFuncs = map[string]interface{}{
+	"section":   section,
+	"directory": directory,
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"join":      strings.Join,
+}
+
+// templateOutput returns the name of the file rendered from
+// template name: its base name without the .tmpl extension.
+func templateOutput(name string) string {
+	return strings.TrimSuffix(filepath.Base(name), ".tmpl")
+}
+
+// writeTemplate renders the template file name with the
+// report model and stores the result in st. Templates that
+// produce HTML files are parsed with html/template to get
+// contextual escaping, all others with text/template.
+func (r *report) writeTemplate(name string, st storage) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	out := templateOutput(name)
+	model := newReportModel(r)
+	var buf bytes.Buffer
+	switch strings.ToLower(filepath.Ext(out)) {
+	case ".html", ".htm":
+		t, err := htemplate.New(out).Funcs(templateFuncs).Parse(string(data))
+		if err != nil {
+			return err
+		}
+		if err := t.Execute(&buf, model); err != nil {
+			return err
+		}
+	default:
+		t, err := ttemplate.New(out).Funcs(templateFuncs).Parse(string(data))
+		if err != nil {
+			return err
+		}
+		if err := t.Execute(&buf, model); err != nil {
+			return err
+		}
+	}
+	return st.put(out, buf.Bytes())
+}
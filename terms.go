@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxPageTerms is how many terms and phrases are kept per page.
+	maxPageTerms = 30
+	// reportTerms is how many terms are listed per page and site.
+	reportTerms = 10
+	// minTermLength is the shortest word counted as a term.
+	minTermLength = 3
+)
+
+// stopwords are the most common words of some languages, which
+// say nothing about what a page is about.
+var stopwords = map[string][]string{
+	"en": strings.Fields(`the and for are but not you all any can her was one our out has have had
+		his how its may new now old see two way who did get him let say she too use that with this
+		from they will would there their what about which when your were been into more some than
+		them then these also only over such just like very here after most other where while should`),
+	"de": strings.Fields(`der die das und ist nicht ein eine einen dem den des sie ich wir ihr mit
+		von auf für aus bei nach wie als auch noch nur oder aber wenn dass sich sind war hat haben
+		wird werden kann mehr sehr schon über unter zum zur vom hier dort diese dieser dieses`),
+	"fr": strings.Fields(`les des une est pas que qui dans pour par sur avec son ses aux ont elle
+		ils nous vous leur mais comme plus tout cette ces sont été être avoir fait peut aussi bien
+		très sans sous entre dont même`),
+	"es": strings.Fields(`los las una por con para del que como más pero sus les este esta estos
+		esto son fue ser han hay muy sin sobre entre cuando también todo todos desde donde ella
+		ellos nos`),
+	"it": strings.Fields(`gli una per con del della dei delle che come più non sono suo sua nel
+		nella alla alle anche ma era essere hanno questo questa quello tra fra dove quando tutto
+		molto senza sul sulla`),
+}
+
+// stopwordSet returns the stopwords of the language of tag,
+// English when there is none, plus extra.
+func stopwordSet(tag string, extra map[string]bool) map[string]bool {
+	lang := newsLanguage(tag)
+	words, ok := stopwords[lang]
+	if !ok {
+		words = stopwords["en"]
+	}
+	set := make(map[string]bool, len(words)+len(extra))
+	for _, w := range words {
+		set[w] = true
+	}
+	for w := range extra {
+		set[w] = true
+	}
+	return set
+}
+
+// loadStopwords reads a file of stopwords, one per line; lines
+// starting with # are comments.
+func loadStopwords(name string) (map[string]bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	set := make(map[string]bool)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		w := strings.ToLower(strings.TrimSpace(sc.Text()))
+		if w != "" && !strings.HasPrefix(w, "#") {
+			set[w] = true
+		}
+	}
+	return set, sc.Err()
+}
+
+// term is a word or a two word phrase and how often it appears.
+type term struct {
+	Text  string
+	Count int
+}
+
+// topTerms counts the words and two word phrases of text that
+// are not stopwords and returns the n most frequent. Phrases do
+// not span punctuation.
+func topTerms(text string, stop map[string]bool, n int) []term {
+	counts := make(map[string]int)
+	prev := ""
+	for _, f := range strings.Fields(strings.ToLower(text)) {
+		for _, w := range strings.FieldsFunc(f, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+		}) {
+			w = strings.Trim(w, "-")
+			if len([]rune(w)) < minTermLength || stop[w] || strings.Trim(w, "0123456789") == "" {
+				prev = ""
+				continue
+			}
+			counts[w]++
+			if prev != "" {
+				counts[prev+" "+w]++
+			}
+			prev = w
+		}
+		if strings.ContainsAny(f[len(f)-1:], ".,;:!?") {
+			prev = ""
+		}
+	}
+	terms := make([]term, 0, len(counts))
+	for t, c := range counts {
+		// A phrase seen once is no phrase.
+		if c > 1 || !strings.Contains(t, " ") {
+			terms = append(terms, term{Text: t, Count: c})
+		}
+	}
+	sortTerms(terms)
+	if len(terms) > n {
+		terms = terms[:n]
+	}
+	return terms
+}
+
+func sortTerms(terms []term) {
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Count != terms[j].Count {
+			return terms[i].Count > terms[j].Count
+		}
+		return terms[i].Text < terms[j].Text
+	})
+}
+
+// pageTerms returns the terms of res without those in stop.
+func pageTerms(res *result, stop map[string]bool) []term {
+	var ts []term
+	for _, t := range res.Terms {
+		drop := false
+		for _, w := range strings.Fields(t.Text) {
+			drop = drop || stop[w]
+		}
+		if !drop {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+// writeTerms prints the top terms of the site, counted once per
+// page that has them among its own top terms, then the top terms
+// of each page.
+func (r *report) writeTerms(w io.Writer, stop map[string]bool) error {
+	pages := make(map[string]int)
+	list := r.sorted()
+	for _, res := range list {
+		for _, t := range pageTerms(res, stop) {
+			pages[t.Text]++
+		}
+	}
+	site := make([]term, 0, len(pages))
+	for t, n := range pages {
+		site = append(site, term{Text: t, Count: n})
+	}
+	sortTerms(site)
+	if len(site) > reportTerms {
+		site = site[:reportTerms]
+	}
+	if _, err := fmt.Fprintf(w, "site\n"); err != nil {
+		return err
+	}
+	for _, t := range site {
+		if _, err := fmt.Fprintf(w, "\t%s\t%d pages\n", t.Text, t.Count); err != nil {
+			return err
+		}
+	}
+	for _, res := range list {
+		ts := pageTerms(res, stop)
+		if len(ts) == 0 {
+			continue
+		}
+		if len(ts) > reportTerms {
+			ts = ts[:reportTerms]
+		}
+		words := make([]string, len(ts))
+		for i, t := range ts {
+			words[i] = fmt.Sprintf("%s (%d)", t.Text, t.Count)
+		}
+		if _, err := fmt.Fprintf(w, "%s\n\t%s\n", res.URL, strings.Join(words, ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCannibalization adds a "shared-top-term" finding for each
+// term that is the most frequent of more than one page, as those
+// pages compete for the same searches.
+func checkCannibalization(rep *report, stop map[string]bool) {
+	tops := make(map[string][]string)
+	names := make([]string, 0)
+	for _, res := range rep.sorted() {
+		if !res.page() || !res.indexable() {
+			continue
+		}
+		ts := pageTerms(res, stop)
+		if len(ts) == 0 {
+			continue
+		}
+		if _, ok := tops[ts[0].Text]; !ok {
+			names = append(names, ts[0].Text)
+		}
+		tops[ts[0].Text] = append(tops[ts[0].Text], res.URL)
+	}
+	for _, t := range names {
+		if urls := tops[t]; len(urls) > 1 {
+			rep.add("shared-top-term", urls[0], fmt.Sprintf("%q is also the top term of %s", t, pageList(urls[1:])))
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	nurl "net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// provider returns the registrable domain of host, which names
+// who serves it: "www.googletagmanager.com" and
+// "ssl.googletagmanager.com" are both "googletagmanager.com".
+func provider(host string) string {
+	if p, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return p
+	}
+	return host
+}
+
+// thirdParty is a provider embedded in the crawled pages.
+type thirdParty struct {
+	name    string
+	origins map[string]bool
+	scripts map[string]bool
+	frames  map[string]bool
+	// pages embedding the provider, by URL template.
+	templates map[string][]string
+	npages    int
+}
+
+// thirdParties returns the providers of the scripts and frames
+// of the pages that are not the crawled site itself, embedded by
+// the most pages first.
+func (r *report) thirdParties() []*thirdParty {
+	base, err := nurl.Parse(r.base)
+	if err != nil {
+		return nil
+	}
+	own := provider(base.Hostname())
+	list := r.sorted()
+	paths := make([]string, len(list))
+	for i, res := range list {
+		paths[i] = urlPath(res.URL)
+	}
+	byName := make(map[string]*thirdParty)
+	var tps []*thirdParty
+	for i, tmpl := range pathTemplates(paths) {
+		res := list[i]
+		if !res.page() {
+			continue
+		}
+		seen := make(map[string]bool)
+		embed := func(url string, frame bool) {
+			u, err := nurl.Parse(url)
+			if err != nil || u.Hostname() == "" || u.Host == base.Host {
+				return
+			}
+			name := provider(u.Hostname())
+			if name == own {
+				return
+			}
+			tp, ok := byName[name]
+			if !ok {
+				tp = &thirdParty{
+					name:      name,
+					origins:   make(map[string]bool),
+					scripts:   make(map[string]bool),
+					frames:    make(map[string]bool),
+					templates: make(map[string][]string),
+				}
+				byName[name] = tp
+				tps = append(tps, tp)
+			}
+			tp.origins[u.Scheme+"://"+u.Host] = true
+			if frame {
+				tp.frames[url] = true
+			} else {
+				tp.scripts[url] = true
+			}
+			if !seen[name] {
+				seen[name] = true
+				tp.npages++
+				tp.templates[tmpl] = append(tp.templates[tmpl], res.URL)
+			}
+		}
+		for _, rs := range res.Resources {
+			if rs.Kind == "js" {
+				embed(rs.URL, false)
+			}
+		}
+		for _, f := range res.Frames {
+			embed(f, true)
+		}
+	}
+	sort.SliceStable(tps, func(i, j int) bool {
+		if tps[i].npages != tps[j].npages {
+			return tps[i].npages > tps[j].npages
+		}
+		return tps[i].name < tps[j].name
+	})
+	return tps
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeThirdParties prints each third party provider with the
+// number of pages embedding it, its origins and how many scripts
+// and frames it serves, followed by the URL templates of those
+// pages, each with its number of pages and an example.
+func (r *report) writeThirdParties(w io.Writer) error {
+	for _, tp := range r.thirdParties() {
+		_, err := fmt.Fprintf(w, "%s\t%d pages\t%d scripts, %d frames\t%s\n",
+			tp.name, tp.npages, len(tp.scripts), len(tp.frames), strings.Join(sortedKeys(tp.origins), " "))
+		if err != nil {
+			return err
+		}
+		tmpls := make([]string, 0, len(tp.templates))
+		for t := range tp.templates {
+			tmpls = append(tmpls, t)
+		}
+		sort.Strings(tmpls)
+		for _, t := range tmpls {
+			pages := tp.templates[t]
+			if _, err := fmt.Fprintf(w, "\t%s\t%d pages\t%s\n", t, len(pages), pages[0]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// topPages returns the n fetched pages with the highest key,
+// highest first.
+func (r *report) topPages(n int, key func(*result) int64) []*result {
+	list := make([]*result, 0, len(r.results))
+	for _, res := range r.sorted() {
+		if key(res) > 0 {
+			list = append(list, res)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return key(list[i]) > key(list[j])
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+func elapsedKey(res *result) int64 { return int64(res.Elapsed) }
+func sizeKey(res *result) int64    { return int64(res.Size) }
+
+func domKey(res *result) int64 {
+	if res.DOM == nil {
+		return 0
+	}
+	return int64(res.DOM.Nodes)
+}
+
+func markupKey(res *result) int64 { return int64(len(res.Markup)) }
+
+// writeTop prints the n slowest, unless timings are left out, and
+// the n heaviest pages, those with the most elements and those with
+// the most broken markup.
+func (r *report) writeTop(w io.Writer, n int) error {
+	if r.timings {
+		if _, err := fmt.Fprintf(w, "slowest pages\n"); err != nil {
+			return err
+		}
+		for _, res := range r.topPages(n, elapsedKey) {
+			if _, err := fmt.Fprintf(w, "\t%s\t%s\n", res.Elapsed.Round(time.Millisecond), res.URL); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "\n"); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "heaviest pages\n"); err != nil {
+		return err
+	}
+	for _, res := range r.topPages(n, sizeKey) {
+		if _, err := fmt.Fprintf(w, "\t%d bytes\t%s\n", res.Size, res.URL); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "\nlargest DOM\n"); err != nil {
+		return err
+	}
+	for _, res := range r.topPages(n, domKey) {
+		if _, err := fmt.Fprintf(w, "\t%d elements, depth %d, %.1f%% text\t%s\n", res.DOM.Nodes, res.DOM.Depth, 100*res.textRatio(), res.URL); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "\nmost markup errors\n"); err != nil {
+		return err
+	}
+	for _, res := range r.topPages(n, markupKey) {
+		if _, err := fmt.Fprintf(w, "\t%d errors\t%s\n", len(res.Markup), res.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPerformance adds a "slow-page" finding for pages that took
+// longer than slow to download and a "heavy-page" finding for
+// pages larger than heavy bytes. Zero disables either check, as
+// leaving out timings disables the first.
+func checkPerformance(rep *report, slow time.Duration, heavy int64) {
+	for _, res := range rep.sorted() {
+		if slow > 0 && rep.timings && res.Elapsed > slow {
+			rep.add("slow-page", res.URL, res.Elapsed.Round(time.Millisecond).String())
+		}
+		if heavy > 0 && int64(res.Size) > heavy {
+			rep.add("heavy-page", res.URL, fmt.Sprintf("%d bytes", res.Size))
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"sort"
+	"strings"
+)
+
+// maxSegmentRepeat is how many times the same path segment can
+// appear in a URL before it is taken for a trap (/a/b/a/b/a/b).
+const maxSegmentRepeat = 3
+
+// trapDetector notices URL spaces that explode, such as faceted
+// navigation, calendars or links that keep growing their path,
+// and stops following them.
+type trapDetector struct {
+	limit   int            // URLs allowed per pattern
+	counts  map[string]int // URLs seen per pattern
+	dropped map[string]int // URLs not followed per trap
+	reasons map[string]string
+	samples map[string]string
+}
+
+func newTrapDetector(limit int) *trapDetector {
+	return &trapDetector{
+		limit:   limit,
+		counts:  make(map[string]int),
+		dropped: make(map[string]int),
+		reasons: make(map[string]string),
+		samples: make(map[string]string),
+	}
+}
+
+// urlPattern generalizes url: path segments with digits become
+// {n} and only the names of the query parameters are kept.
+func urlPattern(url string) string {
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return url
+	}
+	segs := strings.Split(u.Path, "/")
+	for i, s := range segs {
+		if strings.ContainsAny(s, "0123456789") {
+			segs[i] = "{n}"
+		}
+	}
+	p := u.Host + strings.Join(segs, "/")
+	if u.RawQuery == "" {
+		return p
+	}
+	names := make([]string, 0)
+	for k := range u.Query() {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return p + "?" + strings.Join(names, "&")
+}
+
+// repeatedSegment returns a path segment of url that appears
+// too often, or an empty string.
+func repeatedSegment(url string) string {
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return ""
+	}
+	seen := make(map[string]int)
+	for _, s := range strings.Split(u.Path, "/") {
+		if s == "" {
+			continue
+		}
+		seen[s]++
+		if seen[s] >= maxSegmentRepeat {
+			return s
+		}
+	}
+	return ""
+}
+
+// allow returns false if the newly discovered url looks like
+// it belongs to a trap and should not be crawled.
+func (t *trapDetector) allow(url string) bool {
+	if seg := repeatedSegment(url); seg != "" {
+		t.drop(urlPattern(url), url, fmt.Sprintf("path segment %q repeated", seg))
+		return false
+	}
+	p := urlPattern(url)
+	if t.limit > 0 && t.counts[p] >= t.limit {
+		t.drop(p, url, fmt.Sprintf("more than %d URLs", t.limit))
+		return false
+	}
+	t.counts[p]++
+	return true
+}
+
+func (t *trapDetector) drop(pattern, url, reason string) {
+	if _, ok := t.dropped[pattern]; !ok {
+		t.reasons[pattern] = reason
+		t.samples[pattern] = url
+	}
+	t.dropped[pattern]++
+}
+
+// report adds a "crawl-trap" finding for each pattern that
+// was cut off, about the first URL that was not followed.
+func (t *trapDetector) report(rep *report) {
+	patterns := make([]string, 0, len(t.dropped))
+	for p := range t.dropped {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	for _, p := range patterns {
+		rep.add("crawl-trap", t.samples[p],
+			fmt.Sprintf("%s: %s, %d URLs not followed", p, t.reasons[p], t.dropped[p]))
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestURLPattern(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"http://example.test/", "example.test/"},
+		{"http://example.test/about", "example.test/about"},
+		{"http://example.test/p/123", "example.test/p/{n}"},
+		{"http://example.test/2024/05/post-1", "example.test/{n}/{n}/{n}"},
+		{"http://example.test/list?page=2", "example.test/list?page"},
+		{"http://example.test/list?sort=asc&page=2&color=red", "example.test/list?color&page&sort"},
+		{"http://example.test/list?page=2&page=3", "example.test/list?page"},
+		{"http://other.test/p/1", "other.test/p/{n}"},
+	}
+	for _, tt := range tests {
+		if got := urlPattern(tt.url); got != tt.want {
+			t.Errorf("urlPattern(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestRepeatedSegment(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"http://example.test/a/b/c", ""},
+		{"http://example.test/a/b/a/b", ""},
+		{"http://example.test/a/b/a/b/a/b", "a"},
+		{"http://example.test/x/a/y/a/z/a", "a"},
+		{"http://example.test/a//a//", ""},
+		{"http://example.test/?a=a&b=a&c=a", ""},
+	}
+	for _, tt := range tests {
+		if got := repeatedSegment(tt.url); got != tt.want {
+			t.Errorf("repeatedSegment(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestTrapDetector(t *testing.T) {
+	d := newTrapDetector(3)
+	for i := 0; i < 5; i++ {
+		url := fmt.Sprintf("http://example.test/p/%d", i)
+		if got, want := d.allow(url), i < 3; got != want {
+			t.Errorf("allow(%q) = %v, want %v", url, got, want)
+		}
+	}
+	// Other patterns have their own count.
+	if !d.allow("http://example.test/q/1") || !d.allow("http://example.test/p/1?page=2") {
+		t.Error("URL of another pattern not allowed")
+	}
+	if d.allow("http://example.test/a/b/a/b/a/b") {
+		t.Error("URL repeating a segment allowed")
+	}
+	rep := newReport("http://example.test/", nil)
+	d.report(rep)
+	want := []finding{
+		{Kind: "crawl-trap", URL: "http://example.test/a/b/a/b/a/b", Detail: `example.test/a/b/a/b/a/b: path segment "a" repeated, 1 URLs not followed`},
+		{Kind: "crawl-trap", URL: "http://example.test/p/3", Detail: "example.test/p/{n}: more than 3 URLs, 2 URLs not followed"},
+	}
+	if len(rep.findings) != len(want) {
+		t.Fatalf("got findings %v, want %v", rep.findings, want)
+	}
+	for i := range want {
+		if rep.findings[i] != want[i] {
+			t.Errorf("got finding %v, want %v", rep.findings[i], want[i])
+		}
+	}
+
+	// Without a limit, only repeated segments are traps.
+	d = newTrapDetector(0)
+	for i := 0; i < 5; i++ {
+		if !d.allow(fmt.Sprintf("http://example.test/p/%d", i)) {
+			t.Errorf("URL %d not allowed without a limit", i)
+		}
+	}
+}
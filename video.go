@@ -0,0 +1,179 @@
+package main
+
+import (
+	nurl "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// video is a video embedded in a page, with what is known of it.
+type video struct {
+	ContentURL   string
+	PlayerURL    string
+	ThumbnailURL string
+	Title        string
+	Description  string
+	Duration     int // seconds
+	UploadDate   string
+}
+
+// videoPlayers are the hosts and paths of common embedded players.
+var videoPlayers = []string{
+	"www.youtube.com/embed/",
+	"youtube.com/embed/",
+	"www.youtube-nocookie.com/embed/",
+	"player.vimeo.com/video/",
+	"www.dailymotion.com/embed/",
+	"fast.wistia.net/embed/",
+}
+
+// isVideoPlayer returns true if url is an embedded video player.
+func isVideoPlayer(url string) bool {
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return false
+	}
+	for _, p := range videoPlayers {
+		if strings.HasPrefix(u.Host+u.Path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// youtubeThumbnail returns the thumbnail of an embedded YouTube
+// video, which is not in the page, or an empty string.
+func youtubeThumbnail(player string) string {
+	u, err := nurl.Parse(player)
+	if err != nil || !strings.Contains(u.Host, "youtube") {
+		return ""
+	}
+	id := strings.TrimPrefix(u.Path, "/embed/")
+	if id == "" || strings.Contains(id, "/") {
+		return ""
+	}
+	return "https://i.ytimg.com/vi/" + id + "/hqdefault.jpg"
+}
+
+var isoDuration = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+
+// parseDuration converts an ISO 8601 duration like PT1M30S to
+// seconds; it returns 0 if d is not in that form.
+func parseDuration(d string) int {
+	m := isoDuration.FindStringSubmatch(d)
+	if m == nil {
+		return 0
+	}
+	secs := 0
+	for i, mult := range []int{3600, 60, 1} {
+		n, _ := strconv.Atoi(m[i+1])
+		secs += n * mult
+	}
+	return secs
+}
+
+// videoAnalyzer collects the videos of a page from video
+// elements, iframes of known players and VideoObject
+// structured data.
+type videoAnalyzer struct {
+	p      *page
+	videos []video
+	cur    *video // open video element
+}
+
+func (a *videoAnalyzer) token(t *html.Token) {
+	if a.cur != nil && t.Type == html.EndTagToken && t.Data == "video" {
+		if a.cur.ContentURL != "" {
+			a.videos = append(a.videos, *a.cur)
+		}
+		a.cur = nil
+		return
+	}
+	if t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken {
+		return
+	}
+	switch t.Data {
+	case "video":
+		v := &video{}
+		if src, ok := attr(t, "src"); ok && src != "" {
+			v.ContentURL = a.p.resolve(src)
+		}
+		if poster, ok := attr(t, "poster"); ok && poster != "" {
+			v.ThumbnailURL = a.p.resolve(poster)
+		}
+		v.Title, _ = attr(t, "title")
+		a.cur = v
+	case "source":
+		if src, ok := attr(t, "src"); ok && a.cur != nil && a.cur.ContentURL == "" {
+			a.cur.ContentURL = a.p.resolve(src)
+		}
+	case "iframe":
+		src, _ := attr(t, "src")
+		if src = a.p.resolve(src); isVideoPlayer(src) {
+			title, _ := attr(t, "title")
+			a.videos = append(a.videos, video{
+				PlayerURL:    src,
+				ThumbnailURL: youtubeThumbnail(src),
+				Title:        title,
+			})
+		}
+	}
+}
+
+// structured returns the VideoObjects of the JSON-LD of the page.
+func (a *videoAnalyzer) structured() []video {
+	var vs []video
+	findTyped(a.p.ld, func(o map[string]interface{}) {
+		vs = append(vs, video{
+			ContentURL:   jsonString(o["contentUrl"]),
+			PlayerURL:    jsonString(o["embedUrl"]),
+			ThumbnailURL: jsonString(o["thumbnailUrl"]),
+			Title:        jsonString(o["name"]),
+			Description:  jsonString(o["description"]),
+			Duration:     parseDuration(jsonString(o["duration"])),
+			UploadDate:   jsonString(o["uploadDate"]),
+		})
+	}, "VideoObject")
+	return vs
+}
+
+// merge fills the fields of v that are empty from o.
+func (v *video) merge(o video) {
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&v.ContentURL, o.ContentURL)
+	fill(&v.PlayerURL, o.PlayerURL)
+	fill(&v.ThumbnailURL, o.ThumbnailURL)
+	fill(&v.Title, o.Title)
+	fill(&v.Description, o.Description)
+	fill(&v.UploadDate, o.UploadDate)
+	if v.Duration == 0 {
+		v.Duration = o.Duration
+	}
+}
+
+// finish merges the structured data into the embedded video it
+// describes, if there is one.
+func (a *videoAnalyzer) finish(res *result) {
+	for _, ld := range a.structured() {
+		merged := false
+		for i := range a.videos {
+			v := &a.videos[i]
+			if (ld.PlayerURL != "" && ld.PlayerURL == v.PlayerURL) || (ld.ContentURL != "" && ld.ContentURL == v.ContentURL) {
+				v.merge(ld)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			a.videos = append(a.videos, ld)
+		}
+	}
+	res.Videos = a.videos
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// minFixedWidth is the smallest width in pixels of an element
+// that does not fit on the screen of a phone.
+const minFixedWidth = 600
+
+// fixedWidthStyle matches widths in pixels in inline styles.
+var fixedWidthStyle = regexp.MustCompile(`(?i)(?:^|[;\s])(?:min-)?width\s*:\s*(\d+)px`)
+
+// viewportAnalyzer detects what makes a page hard to use on a
+// phone: a missing or fixed viewport, disabled zooming and
+// elements wider than the screen.
+type viewportAnalyzer struct {
+	issues   []finding
+	viewport bool
+}
+
+func (a *viewportAnalyzer) add(kind, detail string) {
+	a.issues = append(a.issues, finding{Kind: kind, Detail: detail})
+}
+
+// parseViewport splits the content of a viewport meta tag into
+// its properties.
+func parseViewport(content string) map[string]string {
+	props := make(map[string]string)
+	for _, p := range strings.FieldsFunc(content, func(r rune) bool { return r == ',' || r == ';' }) {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) == 2 {
+			props[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.ToLower(strings.TrimSpace(kv[1]))
+		}
+	}
+	return props
+}
+
+func (a *viewportAnalyzer) meta(content string) {
+	a.viewport = true
+	props := parseViewport(content)
+	if props["width"] != "device-width" {
+		a.add("mobile-fixed-viewport", content)
+	}
+	if s := props["user-scalable"]; s == "no" || s == "0" {
+		a.add("mobile-zoom-disabled", content)
+	} else if max, err := strconv.ParseFloat(props["maximum-scale"], 64); err == nil && max < 2 {
+		a.add("mobile-zoom-disabled", content)
+	}
+}
+
+func (a *viewportAnalyzer) token(t *html.Token) {
+	if t.Type != html.StartTagToken && t.Type != html.SelfClosingTagToken {
+		return
+	}
+	if t.Data == "meta" {
+		if name, _ := attr(t, "name"); strings.EqualFold(name, "viewport") {
+			content, _ := attr(t, "content")
+			a.meta(content)
+		}
+		return
+	}
+	if t.Data == "table" || t.Data == "td" || t.Data == "th" {
+		if w, _ := attr(t, "width"); !strings.HasSuffix(w, "%") {
+			if n, err := strconv.Atoi(strings.TrimSuffix(w, "px")); err == nil && n >= minFixedWidth {
+				a.add("mobile-fixed-width", fmt.Sprintf("<%s width=%s>", t.Data, w))
+			}
+		}
+	}
+	if style, ok := attr(t, "style"); ok && t.Data != "img" {
+		for _, m := range fixedWidthStyle.FindAllStringSubmatch(style, -1) {
+			if n, _ := strconv.Atoi(m[1]); n >= minFixedWidth {
+				a.add("mobile-fixed-width", fmt.Sprintf("<%s style=%q>", t.Data, style))
+				break
+			}
+		}
+	}
+}
+
+func (a *viewportAnalyzer) finish(res *result) {
+	if !a.viewport {
+		a.issues = append([]finding{{Kind: "mobile-no-viewport"}}, a.issues...)
+	}
+	res.Mobile = a.issues
+}
+
+// checkMobileFriendly adds the findings of viewportAnalyzer, one
+// per page and kind.
+func checkMobileFriendly(rep *report) {
+	for _, res := range rep.sorted() {
+		if res.page() {
+			rep.addIssues(res.URL, res.Mobile)
+		}
+	}
+}
+
+// checkMobileResources adds a "mobile-blocked-resources" finding
+// for each page using stylesheets or scripts in blocked, without
+// which search engines cannot tell how the page looks on phones.
+func checkMobileResources(rep *report, blocked map[string]bool) {
+	for _, res := range rep.sorted() {
+		var urls []string
+		for _, r := range res.Resources {
+			if blocked[r.URL] {
+				urls = append(urls, r.URL)
+			}
+		}
+		if len(urls) > 0 {
+			sort.Strings(urls)
+			rep.add("mobile-blocked-resources", res.URL, fmt.Sprintf("%d blocked by robots.txt: %s", len(urls), pageList(urls)))
+		}
+	}
+}